@@ -0,0 +1,119 @@
+// Command apikey manages the hashed API keys kept in the gateway's SQLite
+// database (STORE_SQLITE_PATH).
+//
+//	apikey create -tenant <tenant_id> [-user <user_id>] [-name <name>]
+//	apikey list
+//	apikey revoke <key_id>
+package main
+
+import (
+	"ai-image-microservice/api-gateway/internal/auth"
+	"ai-image-microservice/api-gateway/internal/config"
+	"ai-image-microservice/api-gateway/internal/models"
+	"ai-image-microservice/api-gateway/internal/store"
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+)
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+	}
+
+	if err := config.LoadConfig(); err != nil {
+		logrus.Fatalf("Failed to load config: %v", err)
+	}
+
+	keyStore, err := store.NewSQLiteAPIKeyStore()
+	if err != nil {
+		logrus.Fatalf("Failed to open api key store: %v", err)
+	}
+	defer store.Close()
+
+	ctx := context.Background()
+
+	switch os.Args[1] {
+	case "create":
+		err = create(ctx, keyStore, os.Args[2:])
+	case "list":
+		err = list(ctx, keyStore)
+	case "revoke":
+		if len(os.Args) != 3 {
+			usage()
+		}
+		err = keyStore.Revoke(ctx, os.Args[2])
+		if err == nil {
+			fmt.Printf("Revoked api key %s\n", os.Args[2])
+		}
+	default:
+		usage()
+	}
+
+	if err != nil {
+		logrus.Fatalf("Command failed: %v", err)
+	}
+}
+
+func create(ctx context.Context, keyStore *store.SQLiteAPIKeyStore, args []string) error {
+	flags := flag.NewFlagSet("create", flag.ExitOnError)
+	tenantID := flags.String("tenant", "", "tenant the key belongs to (required)")
+	userID := flags.String("user", "", "user the key acts as; defaults to the tenant")
+	name := flags.String("name", "", "human readable description")
+	flags.Parse(args)
+
+	if *tenantID == "" {
+		return fmt.Errorf("-tenant is required")
+	}
+
+	rawKey, err := auth.GenerateKey()
+	if err != nil {
+		return err
+	}
+
+	key := &models.APIKey{
+		ID:        uuid.New().String(),
+		KeyHash:   auth.HashKey(rawKey),
+		TenantID:  *tenantID,
+		UserID:    *userID,
+		Name:      *name,
+		CreatedAt: time.Now().UTC(),
+	}
+	if err := keyStore.Create(ctx, key); err != nil {
+		return err
+	}
+
+	// The raw key is not stored and cannot be shown again
+	fmt.Printf("ID:  %s\nKey: %s\n", key.ID, rawKey)
+	return nil
+}
+
+func list(ctx context.Context, keyStore *store.SQLiteAPIKeyStore) error {
+	keys, err := keyStore.List(ctx)
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tTENANT\tUSER\tNAME\tCREATED\tREVOKED")
+	for _, key := range keys {
+		revoked := "-"
+		if key.RevokedAt != nil {
+			revoked = key.RevokedAt.Format(time.RFC3339)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n",
+			key.ID, key.TenantID, key.UserID, key.Name, key.CreatedAt.Format(time.RFC3339), revoked)
+	}
+	return w.Flush()
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: apikey create -tenant <tenant_id> [-user <user_id>] [-name <name>] | list | revoke <key_id>")
+	os.Exit(2)
+}
@@ -1,9 +1,17 @@
 package main
 
 import (
+	"ai-image-microservice/api-gateway/internal/auth"
+	"ai-image-microservice/api-gateway/internal/blobstore"
 	"ai-image-microservice/api-gateway/internal/config"
+	"ai-image-microservice/api-gateway/internal/events"
+	"ai-image-microservice/api-gateway/internal/outbox"
 	"ai-image-microservice/api-gateway/internal/rabbitmq"
 	"ai-image-microservice/api-gateway/internal/router"
+	"ai-image-microservice/api-gateway/internal/services"
+	"ai-image-microservice/api-gateway/internal/store"
+	"ai-image-microservice/api-gateway/internal/tracing"
+	"ai-image-microservice/api-gateway/internal/webhook"
 	"context"
 	"errors"
 	"fmt"
@@ -11,6 +19,7 @@ import (
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 
 	"github.com/sirupsen/logrus"
 )
@@ -20,11 +29,36 @@ func main() {
 		logrus.Fatalf("Failed to load config: %v", err)
 	}
 
-	if err := initRabbitMQ(); err != nil {
+	shutdownTracing, err := tracing.Init(context.Background())
+	if err != nil {
+		logrus.Fatalf("Failed to initialize tracing: %v", err)
+	}
+
+	if err := store.Init(); err != nil {
+		logrus.Fatalf("Failed to initialize stores: %v", err)
+	}
+	defer store.Close()
+
+	if err := auth.InitAuthenticators(); err != nil {
+		logrus.Fatalf("Failed to initialize authentication: %v", err)
+	}
+
+	if err := services.InitQuotaService(); err != nil {
+		logrus.Fatalf("Failed to initialize quotas: %v", err)
+	}
+
+	if err := initBlobStore(); err != nil {
+		logrus.Fatalf("Failed to initialize blob store: %v", err)
+	}
+
+	consumer, fanout, err := initRabbitMQ()
+	if err != nil {
 		logrus.Fatalf("Failed to initialize RabbitMQ: %v", err)
 	}
 	defer rabbitmq.GetConnection().Close()
 
+	webhook.GetDispatcher().Start()
+
 	r := router.SetupRouter()
 
 	srv := &http.Server{
@@ -33,6 +67,9 @@ func main() {
 		ReadTimeout:  config.AppConfig.API.RequestTimeout,
 		WriteTimeout: config.AppConfig.API.RequestTimeout,
 	}
+	// Event streams never finish on their own; end them so Shutdown can
+	// return once the other requests are done
+	srv.RegisterOnShutdown(events.GetHub().Close)
 
 	go func() {
 		logrus.Infof("Starting API Gateway on port %s", config.AppConfig.Port)
@@ -47,21 +84,85 @@ func main() {
 
 	logrus.Info("Shutting down server...")
 
-	ctx, cancel := context.WithTimeout(context.Background(), config.AppConfig.API.ShutdownTimeout)
-	defer cancel()
+	// Each step gets its own timeout so a slow one does not leave the
+	// others with an expired context
+	timeout := config.AppConfig.API.ShutdownTimeout
 
-	if err := srv.Shutdown(ctx); err != nil {
-		logrus.Errorf("Server forced to shutdown: %v", err)
+	shutdownStep(timeout, "Server forced to shutdown", srv.Shutdown)
+	shutdownStep(timeout, "Consumer forced to stop", consumer.Stop)
+	shutdownStep(timeout, "Fanout consumer forced to stop", fanout.Stop)
+	if config.AppConfig.Outbox.Enabled {
+		shutdownStep(timeout, "Outbox relay forced to stop", outbox.GetRelay().Shutdown)
 	}
+	shutdownStep(timeout, "Webhook deliveries still in flight at shutdown", webhook.GetDispatcher().Shutdown)
+	shutdownStep(timeout, "Failed to flush traces", shutdownTracing)
 
 	logrus.Info("Server exited")
 }
 
-func initRabbitMQ() error {
-	if err := rabbitmq.InitPublisher(); err != nil {
-		return fmt.Errorf("failed to initialize publisher: %w", err)
+// shutdownStep runs one step of the shutdown with its own timeout and logs
+// its error with msg
+func shutdownStep(timeout time.Duration, msg string, stop func(context.Context) error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	if err := stop(ctx); err != nil {
+		logrus.Errorf("%s: %v", msg, err)
 	}
+}
+
+// initRabbitMQ declares the topology and starts consuming. With the outbox
+// enabled the gateway starts without the broker; setup then happens once
+// the connection is established.
+func initRabbitMQ() (*rabbitmq.Consumer, *rabbitmq.Consumer, error) {
+	jobService := services.NewJobService()
 
-	logrus.Info("RabbitMQ initialized successfully")
-	return nil
+	// Each instance has its own queue of job events for its subscribers,
+	// while worker events are shared out between instances
+	fanout := rabbitmq.NewConsumer()
+	fanout.RegisterHandler(rabbitmq.TopicJobEvent, jobService.HandleJobEvent)
+
+	consumer := rabbitmq.NewConsumer()
+	consumer.RegisterHandler(rabbitmq.TopicFaceRecognition, jobService.HandleFaceRecognition)
+	consumer.RegisterHandler(rabbitmq.TopicDataSaved, jobService.HandleDataSaved)
+
+	err := rabbitmq.GetConnection().WhenConnected(func() error {
+		if err := rabbitmq.InitPublisher(); err != nil {
+			return fmt.Errorf("failed to initialize publisher: %w", err)
+		}
+
+		if err := fanout.StartFanout(rabbitmq.JobEventsExchange); err != nil {
+			return fmt.Errorf("failed to start job event fanout: %w", err)
+		}
+
+		if err := consumer.StartConsuming(
+			config.AppConfig.RabbitMQ.GatewayQueue,
+			[]string{rabbitmq.TopicFaceRecognition, rabbitmq.TopicDataSaved},
+		); err != nil {
+			return fmt.Errorf("failed to start consumer: %w", err)
+		}
+
+		logrus.Info("RabbitMQ initialized successfully")
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if config.AppConfig.Outbox.Enabled {
+		outbox.GetRelay().Start()
+	}
+
+	return consumer, fanout, nil
+}
+
+func initBlobStore() error {
+	switch config.AppConfig.Blob.Transport {
+	case services.TransportInline:
+		return nil
+	case services.TransportClaimCheck:
+		return blobstore.InitBlobStore()
+	default:
+		return fmt.Errorf("unsupported image transport: %s", config.AppConfig.Blob.Transport)
+	}
 }
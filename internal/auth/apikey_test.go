@@ -0,0 +1,110 @@
+package auth
+
+import (
+	"ai-image-microservice/api-gateway/internal/models"
+	"ai-image-microservice/api-gateway/internal/store"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// memoryKeys is a key store of fixed keys by hash
+type memoryKeys map[string]*models.APIKey
+
+func (s memoryKeys) GetByHash(ctx context.Context, keyHash string) (*models.APIKey, error) {
+	if key, ok := s[keyHash]; ok {
+		return key, nil
+	}
+	return nil, store.ErrNotFound
+}
+
+func apiKeyRequest(key string) *http.Request {
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	if key != "" {
+		r.Header.Set(HeaderAPIKey, key)
+	}
+	return r
+}
+
+func TestAPIKeyAuthenticator(t *testing.T) {
+	revokedAt := time.Now()
+	keys := memoryKeys{
+		HashKey("user-key"):    {ID: "k1", TenantID: "acme", UserID: "alice"},
+		HashKey("service-key"): {ID: "k2", TenantID: "acme"},
+		HashKey("revoked-key"): {ID: "k3", TenantID: "acme", RevokedAt: &revokedAt},
+	}
+	a := NewAPIKeyAuthenticator(memoryKeys{}, keys)
+
+	identity, err := a.Authenticate(apiKeyRequest("user-key"))
+	if err != nil {
+		t.Fatalf("Authenticate: %v", err)
+	}
+	if identity.Method != models.AuthMethodAPIKey || identity.UserID != "alice" || identity.TenantID != "acme" || identity.KeyID != "k1" {
+		t.Fatalf("identity = %+v", identity)
+	}
+
+	// Service keys act on behalf of their tenant
+	identity, err = a.Authenticate(apiKeyRequest(" service-key "))
+	if err != nil || identity.UserID != "acme" {
+		t.Fatalf("service key identity = %+v, %v", identity, err)
+	}
+
+	if _, err := a.Authenticate(apiKeyRequest("")); !errors.Is(err, ErrNoCredentials) {
+		t.Fatalf("no key = %v, want ErrNoCredentials", err)
+	}
+	for _, key := range []string{"revoked-key", "unknown-key"} {
+		if _, err := a.Authenticate(apiKeyRequest(key)); !errors.Is(err, ErrInvalidCredentials) {
+			t.Fatalf("%s = %v, want ErrInvalidCredentials", key, err)
+		}
+	}
+}
+
+func TestFileKeyStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "keys.json")
+	content := `[
+		{"key": "raw-key", "tenant_id": "acme", "user_id": "alice"},
+		{"id": "hashed", "key_hash": "` + strings.ToUpper(HashKey("hashed-key")) + `", "tenant_id": "acme"}
+	]`
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+
+	s, err := NewFileKeyStore(path)
+	if err != nil {
+		t.Fatalf("NewFileKeyStore: %v", err)
+	}
+
+	key, err := s.GetByHash(context.Background(), HashKey("raw-key"))
+	if err != nil || key.ID != "file-0" || key.UserID != "alice" {
+		t.Fatalf("raw key = %+v, %v", key, err)
+	}
+	key, err = s.GetByHash(context.Background(), HashKey("hashed-key"))
+	if err != nil || key.ID != "hashed" {
+		t.Fatalf("hashed key = %+v, %v", key, err)
+	}
+
+	if err := os.WriteFile(path, []byte(`[{"key": "no-tenant"}]`), 0o600); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	if _, err := NewFileKeyStore(path); err == nil {
+		t.Fatal("key without a tenant was accepted")
+	}
+}
+
+func TestGenerateKey(t *testing.T) {
+	first, err := GenerateKey()
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+	second, _ := GenerateKey()
+
+	if !strings.HasPrefix(first, "aig_") || len(first) != 68 || first == second {
+		t.Fatalf("GenerateKey = %q, %q", first, second)
+	}
+}
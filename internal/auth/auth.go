@@ -0,0 +1,103 @@
+package auth
+
+import (
+	"ai-image-microservice/api-gateway/internal/config"
+	"ai-image-microservice/api-gateway/internal/models"
+	"ai-image-microservice/api-gateway/internal/store"
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/sirupsen/logrus"
+)
+
+var (
+	// ErrNoCredentials means the request carries no credentials of the kind
+	// an authenticator handles, so the next authenticator may be tried
+	ErrNoCredentials      = errors.New("no credentials provided")
+	ErrInvalidCredentials = errors.New("invalid credentials")
+)
+
+// Authenticator resolves the caller of a request
+type Authenticator interface {
+	Authenticate(r *http.Request) (*models.Identity, error)
+}
+
+// Challenger is implemented by authenticators that advertise their scheme in
+// the WWW-Authenticate header of 401 responses
+type Challenger interface {
+	Challenge() string
+}
+
+type contextKey struct{}
+
+// WithIdentity returns a copy of ctx carrying the authenticated identity
+func WithIdentity(ctx context.Context, identity *models.Identity) context.Context {
+	return context.WithValue(ctx, contextKey{}, identity)
+}
+
+// IdentityFromContext returns the authenticated identity, if any
+func IdentityFromContext(ctx context.Context) (*models.Identity, bool) {
+	identity, ok := ctx.Value(contextKey{}).(*models.Identity)
+	return identity, ok && identity != nil
+}
+
+var authenticators []Authenticator
+
+// InitAuthenticators builds the configured authenticators
+func InitAuthenticators() error {
+	cfg := config.AppConfig.Auth
+
+	var keyStores []KeyStore
+	if cfg.APIKeysFile != "" {
+		s, err := NewFileKeyStore(cfg.APIKeysFile)
+		if err != nil {
+			return err
+		}
+		keyStores = append(keyStores, s)
+	}
+	if cfg.APIKeysSQLite {
+		s, err := store.NewSQLiteAPIKeyStore()
+		if err != nil {
+			return err
+		}
+		keyStores = append(keyStores, s)
+	}
+
+	var keySets []KeySet
+	if len(cfg.JWT.PublicKeyFiles) > 0 {
+		s, err := NewStaticKeySet(cfg.JWT.PublicKeyFiles)
+		if err != nil {
+			return err
+		}
+		keySets = append(keySets, s)
+	}
+	if cfg.JWT.JWKSURL != "" {
+		keySets = append(keySets, NewJWKSKeySet(cfg.JWT.JWKSURL, cfg.JWT.JWKSRefresh))
+	}
+
+	list := []Authenticator{}
+	if len(keyStores) > 0 {
+		list = append(list, NewAPIKeyAuthenticator(keyStores...))
+	}
+	if len(keySets) > 0 {
+		list = append(list, NewJWTAuthenticator(cfg.JWT, keySets...))
+	}
+
+	if cfg.Enabled && len(list) == 0 {
+		return errors.New("authentication is enabled but no credentials source is configured")
+	}
+
+	authenticators = list
+	logrus.Infof("Authentication initialized (enabled: %t, authenticators: %d)", cfg.Enabled, len(list))
+	return nil
+}
+
+func GetAuthenticators() []Authenticator {
+	if authenticators == nil {
+		if err := InitAuthenticators(); err != nil {
+			logrus.Fatalf("Failed to initialize authentication: %v", err)
+		}
+	}
+	return authenticators
+}
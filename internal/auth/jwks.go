@@ -0,0 +1,329 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"golang.org/x/sync/singleflight"
+)
+
+// ErrKeySetUnavailable means verification keys could not be loaded, as
+// opposed to the token being invalid
+var ErrKeySetUnavailable = errors.New("verification keys unavailable")
+
+// jwksMinRefetch bounds how often an unknown key ID may trigger a refetch
+const jwksMinRefetch = 30 * time.Second
+
+// KeySet provides the public keys JWT signatures are verified with. An
+// empty kid returns every key of the set.
+type KeySet interface {
+	Keys(ctx context.Context, kid string) ([]crypto.PublicKey, error)
+}
+
+// StaticKeySet holds public keys loaded from PEM files. Keys are identified
+// by their file name without extension.
+type StaticKeySet struct {
+	keys map[string]crypto.PublicKey
+}
+
+func NewStaticKeySet(paths []string) (*StaticKeySet, error) {
+	s := &StaticKeySet{keys: make(map[string]crypto.PublicKey)}
+	for _, path := range paths {
+		raw, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read public key: %w", err)
+		}
+
+		key, err := parsePublicKeyPEM(raw)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse public key %s: %w", path, err)
+		}
+
+		kid := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+		s.keys[kid] = key
+	}
+	return s, nil
+}
+
+func (s *StaticKeySet) Keys(ctx context.Context, kid string) ([]crypto.PublicKey, error) {
+	if key, ok := s.keys[kid]; ok {
+		return []crypto.PublicKey{key}, nil
+	}
+
+	// Static keys are often configured without key IDs, so an unknown kid
+	// falls back to trying every key
+	keys := make([]crypto.PublicKey, 0, len(s.keys))
+	for _, key := range s.keys {
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+func parsePublicKeyPEM(raw []byte) (crypto.PublicKey, error) {
+	block, _ := pem.Decode(raw)
+	if block == nil {
+		return nil, errors.New("no PEM block found")
+	}
+
+	switch block.Type {
+	case "PUBLIC KEY":
+		return x509.ParsePKIXPublicKey(block.Bytes)
+	case "RSA PUBLIC KEY":
+		return x509.ParsePKCS1PublicKey(block.Bytes)
+	case "CERTIFICATE":
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		return cert.PublicKey, nil
+	default:
+		return nil, fmt.Errorf("unsupported PEM block type: %s", block.Type)
+	}
+}
+
+// JWKSKeySet serves the keys of a JWKS document read from an http(s) URL or
+// a file. The document is cached and refreshed in the background once it is
+// older than the refresh interval, or refetched when a token names an
+// unknown key ID so rotated keys are picked up without waiting. Concurrent
+// callers share a single fetch, which runs without holding the cache lock.
+type JWKSKeySet struct {
+	source  string
+	refresh time.Duration
+	client  *http.Client
+
+	group      singleflight.Group
+	refreshing atomic.Bool
+
+	mu        sync.RWMutex
+	keys      map[string]crypto.PublicKey
+	fetchedAt time.Time
+}
+
+func NewJWKSKeySet(source string, refresh time.Duration) *JWKSKeySet {
+	return &JWKSKeySet{
+		source:  source,
+		refresh: refresh,
+		client:  &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+func (s *JWKSKeySet) Keys(ctx context.Context, kid string) ([]crypto.PublicKey, error) {
+	s.mu.RLock()
+	keys, fetchedAt := s.keys, s.fetchedAt
+	s.mu.RUnlock()
+
+	switch {
+	case keys == nil:
+		var err error
+		if keys, err = s.fetch(ctx); err != nil {
+			return nil, err
+		}
+		fetchedAt = time.Now()
+	case time.Since(fetchedAt) > s.refresh:
+		// Keep serving the cached keys while they are refreshed
+		s.refreshInBackground()
+	}
+
+	if kid == "" {
+		all := make([]crypto.PublicKey, 0, len(keys))
+		for _, key := range keys {
+			all = append(all, key)
+		}
+		return all, nil
+	}
+
+	if key, ok := keys[kid]; ok {
+		return []crypto.PublicKey{key}, nil
+	}
+
+	if time.Since(fetchedAt) > jwksMinRefetch {
+		keys, err := s.fetch(ctx)
+		if err != nil {
+			logrus.Warnf("Failed to refetch JWKS for unknown key %s: %v", kid, err)
+		} else if key, ok := keys[kid]; ok {
+			return []crypto.PublicKey{key}, nil
+		}
+	}
+
+	return nil, fmt.Errorf("unknown key id: %s", kid)
+}
+
+// refreshInBackground starts a refresh unless one is already running
+func (s *JWKSKeySet) refreshInBackground() {
+	if !s.refreshing.CompareAndSwap(false, true) {
+		return
+	}
+
+	go func() {
+		defer s.refreshing.Store(false)
+		if _, err := s.fetch(context.Background()); err != nil {
+			logrus.Warnf("Failed to refresh JWKS, using cached keys: %v", err)
+		}
+	}()
+}
+
+// fetch loads the document and caches its keys. Concurrent calls share one
+// request; a caller whose ctx ends stops waiting without cancelling it.
+func (s *JWKSKeySet) fetch(ctx context.Context) (map[string]crypto.PublicKey, error) {
+	result := s.group.DoChan(s.source, func() (interface{}, error) {
+		return s.load(context.WithoutCancel(ctx))
+	})
+
+	select {
+	case res := <-result:
+		if res.Err != nil {
+			return nil, res.Err
+		}
+		return res.Val.(map[string]crypto.PublicKey), nil
+	case <-ctx.Done():
+		return nil, fmt.Errorf("%w: %v", ErrKeySetUnavailable, ctx.Err())
+	}
+}
+
+func (s *JWKSKeySet) load(ctx context.Context) (map[string]crypto.PublicKey, error) {
+	raw, err := s.read(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrKeySetUnavailable, err)
+	}
+
+	keys, err := parseJWKS(raw)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrKeySetUnavailable, err)
+	}
+
+	s.mu.Lock()
+	s.keys = keys
+	s.fetchedAt = time.Now()
+	s.mu.Unlock()
+
+	logrus.Debugf("Loaded %d keys from JWKS %s", len(keys), s.source)
+	return keys, nil
+}
+
+func (s *JWKSKeySet) read(ctx context.Context) ([]byte, error) {
+	if !strings.HasPrefix(s.source, "http://") && !strings.HasPrefix(s.source, "https://") {
+		return os.ReadFile(strings.TrimPrefix(s.source, "file://"))
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.source, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+
+	return io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+}
+
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+func parseJWKS(raw []byte) (map[string]crypto.PublicKey, error) {
+	var doc struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.Unmarshal(raw, &doc); err != nil {
+		return nil, fmt.Errorf("failed to parse JWKS: %w", err)
+	}
+
+	keys := make(map[string]crypto.PublicKey)
+	for _, k := range doc.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+
+		key, err := k.publicKey()
+		if err != nil {
+			// One malformed or unsupported key must not disable the others
+			logrus.Warnf("Skipping JWKS key %s: %v", k.Kid, err)
+			continue
+		}
+		keys[k.Kid] = key
+	}
+
+	return keys, nil
+}
+
+func (k jwk) publicKey() (crypto.PublicKey, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeBigInt(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decodeBigInt(k.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve: %s", k.Crv)
+		}
+		x, err := decodeBigInt(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decodeBigInt(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		if !curve.IsOnCurve(x, y) {
+			return nil, errors.New("point is not on curve")
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	default:
+		return nil, fmt.Errorf("unsupported key type: %s", k.Kty)
+	}
+}
+
+func decodeBigInt(value string) (*big.Int, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid base64url value: %w", err)
+	}
+	return new(big.Int).SetBytes(raw), nil
+}
@@ -0,0 +1,211 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// jwksServer serves a JWKS document of the current keys, counting fetches.
+// A fetch blocks while gate is set.
+type jwksServer struct {
+	*httptest.Server
+
+	mu      sync.Mutex
+	keys    map[string]*rsa.PublicKey
+	gate    chan struct{}
+	fetches atomic.Int32
+}
+
+func newJWKSServer(t *testing.T) *jwksServer {
+	t.Helper()
+
+	s := &jwksServer{keys: make(map[string]*rsa.PublicKey)}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.fetches.Add(1)
+
+		s.mu.Lock()
+		gate := s.gate
+		s.mu.Unlock()
+		if gate != nil {
+			<-gate
+		}
+
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		doc := struct {
+			Keys []jwk `json:"keys"`
+		}{}
+		for kid, key := range s.keys {
+			doc.Keys = append(doc.Keys, jwk{
+				Kty: "RSA",
+				Kid: kid,
+				Use: "sig",
+				N:   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+				E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+			})
+		}
+		json.NewEncoder(w).Encode(doc)
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *jwksServer) addKey(t *testing.T, kid string) *rsa.PrivateKey {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.keys[kid] = &key.PublicKey
+	return key
+}
+
+func (s *jwksServer) block() func() {
+	gate := make(chan struct{})
+	s.mu.Lock()
+	s.gate = gate
+	s.mu.Unlock()
+
+	return func() {
+		s.mu.Lock()
+		s.gate = nil
+		s.mu.Unlock()
+		close(gate)
+	}
+}
+
+func TestJWKSKeySetLoadsKeys(t *testing.T) {
+	server := newJWKSServer(t)
+	server.addKey(t, "a")
+	server.addKey(t, "b")
+
+	set := NewJWKSKeySet(server.URL, time.Hour)
+
+	keys, err := set.Keys(context.Background(), "a")
+	if err != nil || len(keys) != 1 {
+		t.Fatalf("Keys(a) = %d keys, %v", len(keys), err)
+	}
+
+	keys, err = set.Keys(context.Background(), "")
+	if err != nil || len(keys) != 2 {
+		t.Fatalf("Keys() = %d keys, %v; want 2", len(keys), err)
+	}
+
+	if n := server.fetches.Load(); n != 1 {
+		t.Fatalf("fetched %d times, want 1", n)
+	}
+}
+
+func TestJWKSKeySetSharesInitialFetch(t *testing.T) {
+	server := newJWKSServer(t)
+	server.addKey(t, "a")
+	release := server.block()
+
+	set := NewJWKSKeySet(server.URL, time.Hour)
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 10)
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := set.Keys(context.Background(), "a")
+			errs <- err
+		}()
+	}
+
+	time.Sleep(50 * time.Millisecond)
+	release()
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			t.Fatalf("Keys: %v", err)
+		}
+	}
+	if n := server.fetches.Load(); n != 1 {
+		t.Fatalf("fetched %d times, want 1", n)
+	}
+}
+
+func TestJWKSKeySetServesCachedKeysDuringRefresh(t *testing.T) {
+	server := newJWKSServer(t)
+	server.addKey(t, "a")
+
+	set := NewJWKSKeySet(server.URL, 10*time.Millisecond)
+	if _, err := set.Keys(context.Background(), "a"); err != nil {
+		t.Fatalf("Keys: %v", err)
+	}
+
+	release := server.block()
+	defer release()
+	time.Sleep(20 * time.Millisecond)
+
+	// The stale keys are served at once while the refresh is held up
+	done := make(chan error, 1)
+	go func() {
+		_, err := set.Keys(context.Background(), "a")
+		done <- err
+	}()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("Keys: %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Keys waited for the refresh")
+	}
+}
+
+func TestJWKSKeySetRefetchesUnknownKey(t *testing.T) {
+	server := newJWKSServer(t)
+	server.addKey(t, "old")
+
+	set := NewJWKSKeySet(server.URL, time.Hour)
+	if _, err := set.Keys(context.Background(), "old"); err != nil {
+		t.Fatalf("Keys: %v", err)
+	}
+
+	server.addKey(t, "new")
+
+	// Within jwksMinRefetch of the last fetch the key stays unknown
+	if _, err := set.Keys(context.Background(), "new"); err == nil {
+		t.Fatal("rotated key found without a refetch")
+	}
+
+	set.mu.Lock()
+	set.fetchedAt = time.Now().Add(-2 * jwksMinRefetch)
+	set.mu.Unlock()
+
+	if _, err := set.Keys(context.Background(), "new"); err != nil {
+		t.Fatalf("Keys(new) after rotation: %v", err)
+	}
+}
+
+func TestJWKSKeySetUnavailable(t *testing.T) {
+	server := newJWKSServer(t)
+	server.Close()
+
+	_, err := NewJWKSKeySet(server.URL, time.Hour).Keys(context.Background(), "a")
+	if !errors.Is(err, ErrKeySetUnavailable) {
+		t.Fatalf("Keys = %v, want ErrKeySetUnavailable", err)
+	}
+}
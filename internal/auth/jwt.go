@@ -0,0 +1,106 @@
+package auth
+
+import (
+	"ai-image-microservice/api-gateway/internal/config"
+	"ai-image-microservice/api-gateway/internal/models"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// JWTAuthenticator authenticates requests carrying an RS256/ES256 (or
+// stronger) bearer token signed by one of the key sets
+type JWTAuthenticator struct {
+	keySets     []KeySet
+	parser      *jwt.Parser
+	tenantClaim string
+}
+
+func NewJWTAuthenticator(cfg config.JWTConfig, keySets ...KeySet) *JWTAuthenticator {
+	options := []jwt.ParserOption{
+		jwt.WithValidMethods([]string{"RS256", "RS384", "RS512", "ES256", "ES384", "ES512"}),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(cfg.Leeway),
+	}
+	if cfg.Issuer != "" {
+		options = append(options, jwt.WithIssuer(cfg.Issuer))
+	}
+	if cfg.Audience != "" {
+		options = append(options, jwt.WithAudience(cfg.Audience))
+	}
+
+	return &JWTAuthenticator{
+		keySets:     keySets,
+		parser:      jwt.NewParser(options...),
+		tenantClaim: cfg.TenantClaim,
+	}
+}
+
+// Challenge is the WWW-Authenticate challenge for bearer tokens
+func (a *JWTAuthenticator) Challenge() string {
+	return "Bearer"
+}
+
+func (a *JWTAuthenticator) Authenticate(r *http.Request) (*models.Identity, error) {
+	header := r.Header.Get("Authorization")
+	scheme, tokenString, found := strings.Cut(header, " ")
+	if !found || !strings.EqualFold(scheme, "Bearer") {
+		return nil, ErrNoCredentials
+	}
+
+	claims := jwt.MapClaims{}
+	token, err := a.parser.ParseWithClaims(strings.TrimSpace(tokenString), claims, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+
+		set := jwt.VerificationKeySet{}
+		var lastErr error
+		for _, keySet := range a.keySets {
+			keys, err := keySet.Keys(r.Context(), kid)
+			if err != nil {
+				// Another key set may still hold the key
+				lastErr = err
+				continue
+			}
+			for _, key := range keys {
+				set.Keys = append(set.Keys, key)
+			}
+		}
+
+		if len(set.Keys) == 0 {
+			if lastErr != nil {
+				return nil, lastErr
+			}
+			return nil, fmt.Errorf("no verification key for kid %q", kid)
+		}
+		return set, nil
+	})
+	if errors.Is(err, ErrKeySetUnavailable) {
+		return nil, err
+	}
+	if err != nil || !token.Valid {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidCredentials, err)
+	}
+
+	subject, _ := claims.GetSubject()
+	if subject == "" {
+		return nil, fmt.Errorf("%w: token has no subject", ErrInvalidCredentials)
+	}
+
+	tenantID, _ := claims[a.tenantClaim].(string)
+	if tenantID == "" {
+		tenantID = subject
+	}
+
+	kid, _ := token.Header["kid"].(string)
+
+	return &models.Identity{
+		Method:   models.AuthMethodJWT,
+		UserID:   subject,
+		TenantID: tenantID,
+		KeyID:    kid,
+		Claims:   claims,
+	}, nil
+}
@@ -0,0 +1,105 @@
+package auth
+
+import (
+	"ai-image-microservice/api-gateway/internal/config"
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// staticKeys is a key set of fixed keys by kid
+type staticKeys map[string]crypto.PublicKey
+
+func (s staticKeys) Keys(ctx context.Context, kid string) ([]crypto.PublicKey, error) {
+	if key, ok := s[kid]; ok {
+		return []crypto.PublicKey{key}, nil
+	}
+	return nil, nil
+}
+
+func signToken(t *testing.T, key *rsa.PrivateKey, kid string, claims jwt.MapClaims) string {
+	t.Helper()
+
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = kid
+	signed, err := token.SignedString(key)
+	if err != nil {
+		t.Fatalf("SignedString: %v", err)
+	}
+	return signed
+}
+
+func bearerRequest(token string) *http.Request {
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	if token != "" {
+		r.Header.Set("Authorization", "Bearer "+token)
+	}
+	return r
+}
+
+func TestJWTAuthenticator(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+	other, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+
+	a := NewJWTAuthenticator(config.JWTConfig{Issuer: "issuer", TenantClaim: "tenant"}, staticKeys{"k1": &key.PublicKey})
+	exp := time.Now().Add(time.Hour).Unix()
+
+	t.Run("valid token", func(t *testing.T) {
+		token := signToken(t, key, "k1", jwt.MapClaims{"sub": "alice", "iss": "issuer", "tenant": "acme", "exp": exp})
+		identity, err := a.Authenticate(bearerRequest(token))
+		if err != nil {
+			t.Fatalf("Authenticate: %v", err)
+		}
+		if identity.UserID != "alice" || identity.TenantID != "acme" || identity.KeyID != "k1" {
+			t.Fatalf("identity = %+v", identity)
+		}
+	})
+
+	t.Run("tenant defaults to subject", func(t *testing.T) {
+		token := signToken(t, key, "k1", jwt.MapClaims{"sub": "alice", "iss": "issuer", "exp": exp})
+		identity, err := a.Authenticate(bearerRequest(token))
+		if err != nil || identity.TenantID != "alice" {
+			t.Fatalf("Authenticate = %+v, %v", identity, err)
+		}
+	})
+
+	t.Run("no credentials", func(t *testing.T) {
+		if _, err := a.Authenticate(bearerRequest("")); !errors.Is(err, ErrNoCredentials) {
+			t.Fatalf("err = %v, want ErrNoCredentials", err)
+		}
+	})
+
+	invalid := []struct {
+		name  string
+		token string
+	}{
+		{name: "wrong key", token: signToken(t, other, "k1", jwt.MapClaims{"sub": "alice", "iss": "issuer", "exp": exp})},
+		{name: "unknown kid", token: signToken(t, key, "k2", jwt.MapClaims{"sub": "alice", "iss": "issuer", "exp": exp})},
+		{name: "expired", token: signToken(t, key, "k1", jwt.MapClaims{"sub": "alice", "iss": "issuer", "exp": time.Now().Add(-time.Hour).Unix()})},
+		{name: "no expiry", token: signToken(t, key, "k1", jwt.MapClaims{"sub": "alice", "iss": "issuer"})},
+		{name: "wrong issuer", token: signToken(t, key, "k1", jwt.MapClaims{"sub": "alice", "iss": "other", "exp": exp})},
+		{name: "no subject", token: signToken(t, key, "k1", jwt.MapClaims{"iss": "issuer", "exp": exp})},
+		{name: "garbage", token: "not-a-token"},
+	}
+	for _, tt := range invalid {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := a.Authenticate(bearerRequest(tt.token)); !errors.Is(err, ErrInvalidCredentials) {
+				t.Fatalf("err = %v, want ErrInvalidCredentials", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,62 @@
+package blobstore
+
+import (
+	"ai-image-microservice/api-gateway/internal/config"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	DriverLocal = "local"
+	DriverS3    = "s3"
+)
+
+var ErrNotFound = errors.New("blob not found")
+
+// BlobStore holds image bytes outside of the message broker. Put returns a
+// reference URI (file://... or s3://bucket/key) that is published in place
+// of the payload.
+type BlobStore interface {
+	Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) (string, error)
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	Delete(ctx context.Context, key string) error
+}
+
+var blobStore BlobStore
+
+func InitBlobStore() error {
+	cfg := config.AppConfig.Blob
+
+	switch cfg.Driver {
+	case DriverLocal:
+		s, err := NewLocalStore(cfg.LocalDir)
+		if err != nil {
+			return err
+		}
+		blobStore = s
+	case DriverS3:
+		s, err := NewS3Store(cfg)
+		if err != nil {
+			return err
+		}
+		blobStore = s
+	default:
+		return fmt.Errorf("unsupported blob store driver: %s", cfg.Driver)
+	}
+
+	logrus.Infof("Blob store initialized (%s)", cfg.Driver)
+	return nil
+}
+
+func GetBlobStore() BlobStore {
+	if blobStore == nil {
+		if err := InitBlobStore(); err != nil {
+			logrus.Fatalf("Failed to initialize blob store: %v", err)
+		}
+	}
+	return blobStore
+}
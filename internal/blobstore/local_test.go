@@ -0,0 +1,67 @@
+package blobstore
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLocalStoreRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	s, err := NewLocalStore(dir)
+	if err != nil {
+		t.Fatalf("NewLocalStore: %v", err)
+	}
+
+	ref, err := s.Put(ctx, "images/abc", strings.NewReader("image bytes"), 11, "image/png")
+	if err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	if want := "file://" + filepath.ToSlash(filepath.Join(dir, "images", "abc")); ref != want {
+		t.Fatalf("ref = %q, want %q", ref, want)
+	}
+
+	r, err := s.Get(ctx, "images/abc")
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	content, _ := io.ReadAll(r)
+	r.Close()
+	if string(content) != "image bytes" {
+		t.Fatalf("content = %q", content)
+	}
+
+	// No temporary files are left behind
+	entries, _ := os.ReadDir(filepath.Join(dir, "images"))
+	if len(entries) != 1 {
+		t.Fatalf("blob directory holds %d entries, want 1", len(entries))
+	}
+
+	if err := s.Delete(ctx, "images/abc"); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if _, err := s.Get(ctx, "images/abc"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Get after Delete = %v, want ErrNotFound", err)
+	}
+	if err := s.Delete(ctx, "images/abc"); err != nil {
+		t.Fatalf("second Delete: %v", err)
+	}
+}
+
+func TestLocalStoreRejectsEscapingKeys(t *testing.T) {
+	s, err := NewLocalStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewLocalStore: %v", err)
+	}
+
+	for _, key := range []string{"../outside", "images/../../outside", ""} {
+		if _, err := s.Put(context.Background(), key, strings.NewReader("x"), 1, "image/png"); err == nil {
+			t.Errorf("Put(%q) succeeded", key)
+		}
+	}
+}
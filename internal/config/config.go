@@ -1,8 +1,11 @@
 package config
 
 import (
+	"fmt"
+	"net"
 	"os"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/joho/godotenv"
@@ -10,11 +13,25 @@ import (
 )
 
 type Config struct {
-	Env      string
-	Port     string
-	LogLevel string
-	RabbitMQ RabbitMQConfig
-	API      APIConfig
+	Env         string
+	Port        string
+	LogLevel    string
+	RabbitMQ    RabbitMQConfig
+	API         APIConfig
+	Store       StoreConfig
+	Events      EventsConfig
+	Webhook     WebhookConfig
+	Blob        BlobConfig
+	Upload      UploadConfig
+	Batch       BatchConfig
+	Fetch       FetchConfig
+	Auth        AuthConfig
+	RateLimit   RateLimitConfig
+	Quota       QuotaConfig
+	Idempotency IdempotencyConfig
+	Outbox      OutboxConfig
+	Metrics     MetricsConfig
+	Tracing     TracingConfig
 }
 
 type RabbitMQConfig struct {
@@ -25,12 +42,181 @@ type RabbitMQConfig struct {
 	ConsumeTimeout time.Duration
 	PrefetchCount  int
 	ReconnectDelay time.Duration
+	GatewayQueue   string
+	// ConsumerWorkers bounds how many deliveries are handled concurrently
+	ConsumerWorkers int
+	// ChannelPoolSize bounds how many channels are open for publishing
+	ChannelPoolSize int
+	// Messages failing MaxDeliveries times, or that cannot be handled at
+	// all, are dead-lettered to <queue>.dlq through DeadLetterExchange
+	DeadLetterExchange string
+	MaxDeliveries      int
+	// RetryDelays are the delays of the retry tiers a failed message goes
+	// through, the last one being reused for any further attempt
+	RetryDelays []time.Duration
 }
 
 type APIConfig struct {
 	MaxUploadSize   int64
 	RequestTimeout  time.Duration
 	ShutdownTimeout time.Duration
+	// AllowedOrigins are the browser origins allowed by CORS and for
+	// WebSocket upgrades. "*" allows any origin for CORS only.
+	AllowedOrigins []string
+	// TrustedProxies are the addresses or CIDRs of reverse proxies whose
+	// X-Forwarded-For header gives the client IP. None are trusted by default.
+	TrustedProxies []string
+}
+
+type EventsConfig struct {
+	BufferSize        int
+	HeartbeatInterval time.Duration
+	WSSendBufferSize  int
+	WSWriteTimeout    time.Duration
+}
+
+// WebhookConfig controls callback deliveries. Callbacks are refused while
+// Secret is empty, since every payload must be signed.
+type WebhookConfig struct {
+	Secret         string
+	MaxAttempts    int
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	Timeout        time.Duration
+	// Allowlist holds host names, IPs or CIDRs that callbacks may target
+	// even though they resolve to private or loopback addresses
+	Allowlist []string
+}
+
+type BlobConfig struct {
+	// Transport is "inline" (base64 image inside the event) or "claim_check"
+	// (image in the blob store, reference inside the event)
+	Transport   string
+	Driver      string
+	LocalDir    string
+	S3Endpoint  string
+	S3Bucket    string
+	S3Region    string
+	S3AccessKey string
+	S3SecretKey string
+	S3UseSSL    bool
+}
+
+type UploadConfig struct {
+	Dir string
+	TTL time.Duration
+}
+
+type BatchConfig struct {
+	MaxItems       int
+	MaxRequestSize int64
+	// ZIP archive limits, guarding against zip bombs
+	ZipMaxEntries          int
+	ZipMaxUncompressedSize int64
+}
+
+type FetchConfig struct {
+	Timeout time.Duration
+	// Allowlist holds host names, IPs or CIDRs that may be fetched even
+	// though they resolve to private or loopback addresses
+	Allowlist []string
+}
+
+type AuthConfig struct {
+	Enabled bool
+	// APIKeysFile is a JSON file of API keys; APIKeysSQLite enables the
+	// hashed keys kept in the store database
+	APIKeysFile   string
+	APIKeysSQLite bool
+	JWT           JWTConfig
+}
+
+type JWTConfig struct {
+	Issuer   string
+	Audience string
+	// PublicKeyFiles are PEM encoded RSA or ECDSA public keys
+	PublicKeyFiles []string
+	// JWKSURL is an http(s) URL or a file path of a JWKS document
+	JWKSURL     string
+	JWKSRefresh time.Duration
+	Leeway      time.Duration
+	TenantClaim string
+}
+
+type RateLimitConfig struct {
+	Enabled bool
+	// Driver is "memory" or "redis"
+	Driver   string
+	RedisURL string
+	// Submit applies to image ingestion routes, Read to every other route
+	// of the face API
+	Submit RateLimitPolicy
+	Read   RateLimitPolicy
+}
+
+// RateLimitPolicy is a token bucket refilled at PerMinute tokens per minute
+// holding up to Burst tokens. KeyBy is "api_key", "user", "ip" or "auto".
+type RateLimitPolicy struct {
+	PerMinute int
+	Burst     int
+	KeyBy     string
+}
+
+func (p RateLimitPolicy) validate(name string) error {
+	if p.PerMinute <= 0 || p.Burst <= 0 {
+		return fmt.Errorf("%s rate limit needs a positive rate and burst, got %d per minute and burst %d",
+			name, p.PerMinute, p.Burst)
+	}
+	return nil
+}
+
+// QuotaConfig holds the default usage quotas of a tenant. Zero means
+// unlimited. TenantsFile is a JSON object of per-tenant overrides.
+type QuotaConfig struct {
+	Enabled       bool
+	DailyImages   int64
+	DailyBytes    int64
+	MonthlyImages int64
+	MonthlyBytes  int64
+	TenantsFile   string
+}
+
+type IdempotencyConfig struct {
+	// TTL is how long responses are kept for replay
+	TTL time.Duration
+}
+
+// OutboxConfig controls the local outbox that holds events until the broker
+// confirms them, so images are accepted while RabbitMQ is unreachable. It is
+// only durable with the SQLite store driver.
+type OutboxConfig struct {
+	Enabled       bool
+	RelayInterval time.Duration
+	BatchSize     int
+	// Retention is how long published events are kept for deduplication
+	Retention time.Duration
+	// MaxPending caps the events held by the memory outbox
+	MaxPending int
+}
+
+type MetricsConfig struct {
+	Enabled bool
+	Path    string
+}
+
+type TracingConfig struct {
+	Enabled bool
+	// Exporter is "otlp", "stdout" or "file"
+	Exporter     string
+	OTLPEndpoint string
+	FilePath     string
+	ServiceName  string
+	SampleRatio  float64
+}
+
+type StoreConfig struct {
+	Driver     string
+	SQLitePath string
 }
 
 var AppConfig *Config
@@ -50,15 +236,164 @@ func LoadConfig() error {
 			ConsumeTimeout: time.Duration(getEnvAsInt("RABBITMQ_CONSUME_TIMEOUT", 30)) * time.Second,
 			PrefetchCount:  getEnvAsInt("RABBITMQ_PREFETCH_COUNT", 10),
 			ReconnectDelay: time.Duration(getEnvAsInt("RABBITMQ_RECONNECT_DELAY", 5)) * time.Second,
+			GatewayQueue:   getEnv("RABBITMQ_GATEWAY_QUEUE", "api_gateway.job_events"),
+
+			ConsumerWorkers:    getEnvAsInt("RABBITMQ_CONSUMER_WORKERS", 10),
+			ChannelPoolSize:    getEnvAsInt("RABBITMQ_CHANNEL_POOL_SIZE", 10),
+			DeadLetterExchange: getEnv("RABBITMQ_DEAD_LETTER_EXCHANGE", "image_processing.dlx"),
+			MaxDeliveries:      getEnvAsInt("RABBITMQ_MAX_DELIVERIES", 5),
+			RetryDelays:        getEnvAsDurations("RABBITMQ_RETRY_TIERS", time.Second, []time.Duration{time.Second, 10 * time.Second, 60 * time.Second}),
 		},
 		API: APIConfig{
 			MaxUploadSize:   getEnvAsInt64("MAX_UPLOAD_SIZE", 10*1024*1024), // 10MB default
 			RequestTimeout:  time.Duration(getEnvAsInt("REQUEST_TIMEOUT", 30)) * time.Second,
 			ShutdownTimeout: time.Duration(getEnvAsInt("SHUTDOWN_TIMEOUT", 10)) * time.Second,
+			AllowedOrigins:  getEnvAsSlice("CORS_ALLOWED_ORIGINS", []string{"*"}),
+			TrustedProxies:  getEnvAsSlice("TRUSTED_PROXIES", nil),
+		},
+		Store: StoreConfig{
+			Driver:     getEnv("STORE_DRIVER", "memory"), // memory or sqlite
+			SQLitePath: getEnv("STORE_SQLITE_PATH", "data/gateway.db"),
+		},
+		Events: EventsConfig{
+			BufferSize:        getEnvAsInt("EVENTS_BUFFER_SIZE", 64),
+			HeartbeatInterval: time.Duration(getEnvAsInt("EVENTS_HEARTBEAT_INTERVAL", 15)) * time.Second,
+			WSSendBufferSize:  getEnvAsInt("EVENTS_WS_SEND_BUFFER", 256),
+			WSWriteTimeout:    time.Duration(getEnvAsInt("EVENTS_WS_WRITE_TIMEOUT", 10)) * time.Second,
+		},
+		Webhook: WebhookConfig{
+			Secret:         getEnv("WEBHOOK_SECRET", ""),
+			MaxAttempts:    getEnvAsInt("WEBHOOK_MAX_ATTEMPTS", 5),
+			InitialBackoff: time.Duration(getEnvAsInt("WEBHOOK_INITIAL_BACKOFF", 1)) * time.Second,
+			MaxBackoff:     time.Duration(getEnvAsInt("WEBHOOK_MAX_BACKOFF", 300)) * time.Second,
+			Timeout:        time.Duration(getEnvAsInt("WEBHOOK_TIMEOUT", 10)) * time.Second,
+			Allowlist:      getEnvAsSlice("WEBHOOK_ALLOWLIST", nil),
+		},
+		Blob: BlobConfig{
+			Transport:   getEnv("IMAGE_TRANSPORT", "inline"),
+			Driver:      getEnv("BLOB_DRIVER", "local"),
+			LocalDir:    getEnv("BLOB_LOCAL_DIR", "data/blobs"),
+			S3Endpoint:  getEnv("BLOB_S3_ENDPOINT", "localhost:9000"),
+			S3Bucket:    getEnv("BLOB_S3_BUCKET", "images"),
+			S3Region:    getEnv("BLOB_S3_REGION", ""),
+			S3AccessKey: getEnv("BLOB_S3_ACCESS_KEY", ""),
+			S3SecretKey: getEnv("BLOB_S3_SECRET_KEY", ""),
+			S3UseSSL:    getEnvAsBool("BLOB_S3_USE_SSL", false),
+		},
+		Upload: UploadConfig{
+			Dir: getEnv("UPLOAD_DIR", "data/uploads"),
+			TTL: time.Duration(getEnvAsInt("UPLOAD_TTL", 24)) * time.Hour,
+		},
+		Batch: BatchConfig{
+			MaxItems:       getEnvAsInt("BATCH_MAX_ITEMS", 500),
+			MaxRequestSize: getEnvAsInt64("BATCH_MAX_REQUEST_SIZE", 1024*1024*1024), // 1GB default
+
+			ZipMaxEntries:          getEnvAsInt("BATCH_ZIP_MAX_ENTRIES", 500),
+			ZipMaxUncompressedSize: getEnvAsInt64("BATCH_ZIP_MAX_UNCOMPRESSED_SIZE", 2*1024*1024*1024), // 2GB default
+		},
+		Fetch: FetchConfig{
+			Timeout:   time.Duration(getEnvAsInt("FETCH_TIMEOUT", 15)) * time.Second,
+			Allowlist: getEnvAsSlice("FETCH_ALLOWLIST", nil),
+		},
+		Auth: AuthConfig{
+			Enabled:       getEnvAsBool("AUTH_ENABLED", false),
+			APIKeysFile:   getEnv("AUTH_API_KEYS_FILE", ""),
+			APIKeysSQLite: getEnvAsBool("AUTH_API_KEYS_SQLITE", false),
+			JWT: JWTConfig{
+				Issuer:         getEnv("AUTH_JWT_ISSUER", ""),
+				Audience:       getEnv("AUTH_JWT_AUDIENCE", ""),
+				PublicKeyFiles: getEnvAsSlice("AUTH_JWT_PUBLIC_KEYS", nil),
+				JWKSURL:        getEnv("AUTH_JWT_JWKS_URL", ""),
+				JWKSRefresh:    time.Duration(getEnvAsInt("AUTH_JWT_JWKS_REFRESH", 300)) * time.Second,
+				Leeway:         time.Duration(getEnvAsInt("AUTH_JWT_LEEWAY", 30)) * time.Second,
+				TenantClaim:    getEnv("AUTH_JWT_TENANT_CLAIM", "tenant_id"),
+			},
+		},
+		RateLimit: RateLimitConfig{
+			Enabled:  getEnvAsBool("RATE_LIMIT_ENABLED", false),
+			Driver:   getEnv("RATE_LIMIT_DRIVER", "memory"),
+			RedisURL: getEnv("RATE_LIMIT_REDIS_URL", "redis://localhost:6379/0"),
+			Submit: RateLimitPolicy{
+				PerMinute: getEnvAsInt("RATE_LIMIT_SUBMIT_PER_MINUTE", 60),
+				Burst:     getEnvAsInt("RATE_LIMIT_SUBMIT_BURST", 20),
+				KeyBy:     getEnv("RATE_LIMIT_SUBMIT_KEY_BY", "auto"),
+			},
+			Read: RateLimitPolicy{
+				PerMinute: getEnvAsInt("RATE_LIMIT_READ_PER_MINUTE", 600),
+				Burst:     getEnvAsInt("RATE_LIMIT_READ_BURST", 100),
+				KeyBy:     getEnv("RATE_LIMIT_READ_KEY_BY", "auto"),
+			},
+		},
+		Quota: QuotaConfig{
+			Enabled:       getEnvAsBool("QUOTA_ENABLED", false),
+			DailyImages:   getEnvAsInt64("QUOTA_DAILY_IMAGES", 0),
+			DailyBytes:    getEnvAsInt64("QUOTA_DAILY_BYTES", 0),
+			MonthlyImages: getEnvAsInt64("QUOTA_MONTHLY_IMAGES", 0),
+			MonthlyBytes:  getEnvAsInt64("QUOTA_MONTHLY_BYTES", 0),
+			TenantsFile:   getEnv("QUOTA_TENANTS_FILE", ""),
+		},
+		Idempotency: IdempotencyConfig{
+			TTL: time.Duration(getEnvAsInt("IDEMPOTENCY_TTL", 24)) * time.Hour,
+		},
+		Outbox: OutboxConfig{
+			Enabled:       getEnvAsBool("OUTBOX_ENABLED", false),
+			RelayInterval: time.Duration(getEnvAsInt("OUTBOX_RELAY_INTERVAL", 1)) * time.Second,
+			BatchSize:     getEnvAsInt("OUTBOX_BATCH_SIZE", 100),
+			Retention:     time.Duration(getEnvAsInt("OUTBOX_RETENTION", 24)) * time.Hour,
+			MaxPending:    getEnvAsInt("OUTBOX_MAX_PENDING", 10000),
+		},
+		Metrics: MetricsConfig{
+			Enabled: getEnvAsBool("METRICS_ENABLED", true),
+			Path:    getEnv("METRICS_PATH", "/metrics"),
+		},
+		Tracing: TracingConfig{
+			Enabled:      getEnvAsBool("TRACING_ENABLED", false),
+			Exporter:     getEnv("TRACING_EXPORTER", "stdout"),
+			OTLPEndpoint: getEnv("TRACING_OTLP_ENDPOINT", ""),
+			FilePath:     getEnv("TRACING_FILE_PATH", "data/traces.json"),
+			ServiceName:  getEnv("TRACING_SERVICE_NAME", "api-gateway"),
+			SampleRatio:  getEnvAsFloat("TRACING_SAMPLE_RATIO", 1),
 		},
 	}
 
 	setLogLevel(AppConfig.LogLevel)
+	return AppConfig.Validate()
+}
+
+// placeholderSecret is the example value of secrets in older .env files
+const placeholderSecret = "change-me"
+
+// Validate rejects settings the gateway cannot run with
+func (c *Config) Validate() error {
+	for _, delay := range c.RabbitMQ.RetryDelays {
+		// Retry queues are named and expire messages in milliseconds
+		if delay < time.Millisecond {
+			return fmt.Errorf("retry tier delay %s is shorter than 1ms", delay)
+		}
+	}
+
+	for _, proxy := range c.API.TrustedProxies {
+		if net.ParseIP(proxy) == nil {
+			if _, _, err := net.ParseCIDR(proxy); err != nil {
+				return fmt.Errorf("invalid trusted proxy %q", proxy)
+			}
+		}
+	}
+
+	// Callbacks signed with a published secret could be forged by anyone
+	if c.Webhook.Secret == placeholderSecret {
+		return fmt.Errorf("WEBHOOK_SECRET is set to the placeholder %q, set a random secret or leave it empty", placeholderSecret)
+	}
+
+	if c.RateLimit.Enabled {
+		if err := c.RateLimit.Submit.validate("submit"); err != nil {
+			return err
+		}
+		if err := c.RateLimit.Read.validate("read"); err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
@@ -85,6 +420,37 @@ func getEnvAsInt64(key string, defaultValue int64) int64 {
 	return defaultValue
 }
 
+func getEnvAsFloat(key string, defaultValue float64) float64 {
+	valueStr := getEnv(key, "")
+	if value, err := strconv.ParseFloat(valueStr, 64); err == nil {
+		return value
+	}
+	return defaultValue
+}
+
+func getEnvAsBool(key string, defaultValue bool) bool {
+	valueStr := getEnv(key, "")
+	if value, err := strconv.ParseBool(valueStr); err == nil {
+		return value
+	}
+	return defaultValue
+}
+
+func getEnvAsSlice(key string, defaultValue []string) []string {
+	valueStr := getEnv(key, "")
+	if valueStr == "" {
+		return defaultValue
+	}
+
+	var values []string
+	for _, value := range strings.Split(valueStr, ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
 func setLogLevel(level string) {
 	switch level {
 	case "debug":
@@ -99,3 +465,22 @@ func setLogLevel(level string) {
 		logrus.SetLevel(logrus.InfoLevel)
 	}
 }
+
+// getEnvAsDurations parses a comma separated list of integers in the given unit
+func getEnvAsDurations(key string, unit time.Duration, defaultValue []time.Duration) []time.Duration {
+	values := getEnvAsSlice(key, nil)
+	if len(values) == 0 {
+		return defaultValue
+	}
+
+	durations := make([]time.Duration, 0, len(values))
+	for _, value := range values {
+		n, err := strconv.Atoi(value)
+		if err != nil || n <= 0 {
+			logrus.Warnf("Invalid value %q in %s, using defaults", value, key)
+			return defaultValue
+		}
+		durations = append(durations, time.Duration(n)*unit)
+	}
+	return durations
+}
@@ -0,0 +1,94 @@
+package config
+
+import (
+	"testing"
+	"time"
+)
+
+func TestValidateRetryDelays(t *testing.T) {
+	tests := []struct {
+		name    string
+		delays  []time.Duration
+		wantErr bool
+	}{
+		{name: "no tiers", delays: nil},
+		{name: "whole seconds", delays: []time.Duration{time.Second, 10 * time.Second}},
+		{name: "one millisecond", delays: []time.Duration{time.Millisecond}},
+		{name: "under a millisecond", delays: []time.Duration{time.Second, 500 * time.Microsecond}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &Config{RabbitMQ: RabbitMQConfig{RetryDelays: tt.delays}}
+			if err := c.Validate(); (err != nil) != tt.wantErr {
+				t.Fatalf("Validate() = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateRateLimits(t *testing.T) {
+	valid := RateLimitPolicy{PerMinute: 60, Burst: 10}
+
+	tests := []struct {
+		name    string
+		cfg     RateLimitConfig
+		wantErr bool
+	}{
+		{name: "valid", cfg: RateLimitConfig{Enabled: true, Submit: valid, Read: valid}},
+		{name: "disabled", cfg: RateLimitConfig{Enabled: false}},
+		{name: "zero rate", cfg: RateLimitConfig{Enabled: true, Submit: RateLimitPolicy{Burst: 10}, Read: valid}, wantErr: true},
+		{name: "zero burst", cfg: RateLimitConfig{Enabled: true, Submit: valid, Read: RateLimitPolicy{PerMinute: 60}}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &Config{RateLimit: tt.cfg}
+			if err := c.Validate(); (err != nil) != tt.wantErr {
+				t.Fatalf("Validate() = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateTrustedProxies(t *testing.T) {
+	tests := []struct {
+		name    string
+		proxies []string
+		wantErr bool
+	}{
+		{name: "none", proxies: nil},
+		{name: "address and CIDR", proxies: []string{"10.0.0.1", "172.16.0.0/12", "::1"}},
+		{name: "hostname", proxies: []string{"proxy.internal"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &Config{API: APIConfig{TrustedProxies: tt.proxies}}
+			if err := c.Validate(); (err != nil) != tt.wantErr {
+				t.Fatalf("Validate() = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateWebhookSecret(t *testing.T) {
+	tests := []struct {
+		name    string
+		secret  string
+		wantErr bool
+	}{
+		{name: "unset", secret: ""},
+		{name: "random", secret: "8f14e45fceea167a5a36dedd4bea2543"},
+		{name: "placeholder", secret: "change-me", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &Config{Webhook: WebhookConfig{Secret: tt.secret}}
+			if err := c.Validate(); (err != nil) != tt.wantErr {
+				t.Fatalf("Validate() = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,190 @@
+package events
+
+import (
+	"ai-image-microservice/api-gateway/internal/config"
+	"ai-image-microservice/api-gateway/internal/models"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Hub fans job events out to in-process subscribers such as SSE streams.
+// Each subscription has a bounded buffer; a subscriber that falls behind is
+// dropped instead of blocking the publisher.
+type Hub struct {
+	mu          sync.RWMutex
+	subscribers map[string]map[*Subscription]struct{}
+	bufferSize  int
+	closed      bool
+}
+
+type Subscription struct {
+	hub    *Hub
+	key    string
+	events chan models.JobEvent
+	once   sync.Once
+	lagged bool
+}
+
+var (
+	hub     *Hub
+	hubOnce sync.Once
+)
+
+func GetHub() *Hub {
+	hubOnce.Do(func() {
+		hub = NewHub(config.AppConfig.Events.BufferSize)
+	})
+	return hub
+}
+
+func NewHub(bufferSize int) *Hub {
+	if bufferSize <= 0 {
+		bufferSize = 1
+	}
+	return &Hub{
+		subscribers: make(map[string]map[*Subscription]struct{}),
+		bufferSize:  bufferSize,
+	}
+}
+
+// ImageKey is the subscription key for events of a single image
+func ImageKey(imageID string) string {
+	return "image:" + imageID
+}
+
+// UserKey is the subscription key for events of all images of a user. User
+// IDs are only unique within a tenant.
+func UserKey(tenantID, userID string) string {
+	return "user:" + tenantID + ":" + userID
+}
+
+func (h *Hub) Subscribe(key string) *Subscription {
+	sub := &Subscription{
+		hub:    h,
+		key:    key,
+		events: make(chan models.JobEvent, h.bufferSize),
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.closed {
+		// Ends right away so streams opened during shutdown return
+		sub.once.Do(func() { close(sub.events) })
+		return sub
+	}
+
+	if h.subscribers[key] == nil {
+		h.subscribers[key] = make(map[*Subscription]struct{})
+	}
+	h.subscribers[key][sub] = struct{}{}
+
+	return sub
+}
+
+// Publish delivers the event to subscribers of its image and of its user
+func (h *Hub) Publish(event models.JobEvent) {
+	keys := []string{ImageKey(event.ImageID)}
+	if event.UserID != "" {
+		keys = append(keys, UserKey(event.TenantID, event.UserID))
+	}
+
+	var lagging []*Subscription
+
+	h.mu.RLock()
+	for _, key := range keys {
+		for sub := range h.subscribers[key] {
+			select {
+			case sub.events <- event:
+			default:
+				lagging = append(lagging, sub)
+			}
+		}
+	}
+	h.mu.RUnlock()
+
+	for _, sub := range lagging {
+		logrus.Warnf("Dropping slow event subscriber for %s", sub.key)
+		sub.close(true)
+	}
+}
+
+// Close ends every subscription, so that event streams return and the
+// server can shut down. Later subscriptions end right away.
+func (h *Hub) Close() {
+	h.mu.Lock()
+	h.closed = true
+	var subs []*Subscription
+	for _, keySubs := range h.subscribers {
+		for sub := range keySubs {
+			subs = append(subs, sub)
+		}
+	}
+	h.mu.Unlock()
+
+	for _, sub := range subs {
+		sub.close(false)
+	}
+}
+
+// Closed reports whether the hub was closed for shutdown
+func (h *Hub) Closed() bool {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return h.closed
+}
+
+// Events returns the channel of events; it is closed when the subscription ends
+func (s *Subscription) Events() <-chan models.JobEvent {
+	return s.events
+}
+
+// Lagged reports whether the subscription was dropped for falling behind
+func (s *Subscription) Lagged() bool {
+	s.hub.mu.RLock()
+	defer s.hub.mu.RUnlock()
+	return s.lagged
+}
+
+func (s *Subscription) Close() {
+	s.close(false)
+}
+
+func (s *Subscription) close(lagged bool) {
+	s.once.Do(func() {
+		s.hub.mu.Lock()
+		defer s.hub.mu.Unlock()
+
+		s.lagged = lagged
+		delete(s.hub.subscribers[s.key], s)
+		if len(s.hub.subscribers[s.key]) == 0 {
+			delete(s.hub.subscribers, s.key)
+		}
+		close(s.events)
+	})
+}
+
+// FromStatus builds the event describing the current state of a job
+func FromStatus(status *models.JobStatus) models.JobEvent {
+	eventType := models.JobEventQueued
+	switch status.Status {
+	case models.JobStateProcessing:
+		eventType = models.JobEventRecognized
+	case models.JobStateCompleted:
+		eventType = models.JobEventSaved
+	case models.JobStateFailed:
+		eventType = models.JobEventFailed
+	}
+
+	return models.JobEvent{
+		Type:      eventType,
+		ImageID:   status.ImageID,
+		TenantID:  status.TenantID,
+		UserID:    status.UserID,
+		Status:    status.Status,
+		Message:   status.Message,
+		Error:     status.Error,
+		Timestamp: status.UpdatedAt,
+	}
+}
@@ -0,0 +1,89 @@
+package events
+
+import (
+	"ai-image-microservice/api-gateway/internal/models"
+	"testing"
+	"time"
+)
+
+func receive(t *testing.T, sub *Subscription) (models.JobEvent, bool) {
+	t.Helper()
+
+	select {
+	case event, ok := <-sub.Events():
+		return event, ok
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for the subscription")
+		return models.JobEvent{}, false
+	}
+}
+
+func TestHubPublishesToImageAndUser(t *testing.T) {
+	h := NewHub(4)
+	image := h.Subscribe(ImageKey("image-1"))
+	user := h.Subscribe(UserKey("tenant-1", "user-1"))
+	otherImage := h.Subscribe(ImageKey("image-2"))
+	// The same user ID in another tenant is another user
+	otherTenant := h.Subscribe(UserKey("tenant-2", "user-1"))
+
+	h.Publish(models.JobEvent{Type: models.JobEventQueued, ImageID: "image-1", TenantID: "tenant-1", UserID: "user-1"})
+
+	for _, sub := range []*Subscription{image, user} {
+		event, ok := receive(t, sub)
+		if !ok || event.ImageID != "image-1" {
+			t.Fatalf("received %+v, %v; want the event of image-1", event, ok)
+		}
+	}
+
+	for _, sub := range []*Subscription{otherImage, otherTenant} {
+		select {
+		case event := <-sub.Events():
+			t.Fatalf("unrelated subscription received %+v", event)
+		default:
+		}
+	}
+}
+
+func TestHubDropsLaggingSubscriber(t *testing.T) {
+	h := NewHub(1)
+	sub := h.Subscribe(ImageKey("image-1"))
+
+	h.Publish(models.JobEvent{ImageID: "image-1"})
+	h.Publish(models.JobEvent{ImageID: "image-1"})
+
+	if _, ok := receive(t, sub); !ok {
+		t.Fatal("buffered event was lost")
+	}
+	if _, ok := receive(t, sub); ok {
+		t.Fatal("subscription still open after falling behind")
+	}
+	if !sub.Lagged() {
+		t.Fatal("Lagged = false, want true")
+	}
+}
+
+func TestHubCloseEndsSubscriptions(t *testing.T) {
+	h := NewHub(1)
+	sub := h.Subscribe(UserKey("tenant-1", "user-1"))
+
+	h.Close()
+
+	if _, ok := receive(t, sub); ok {
+		t.Fatal("subscription still open after Close")
+	}
+	if sub.Lagged() {
+		t.Fatal("Lagged = true after Close")
+	}
+	if !h.Closed() {
+		t.Fatal("Closed = false after Close")
+	}
+
+	late := h.Subscribe(UserKey("tenant-1", "user-1"))
+	if _, ok := receive(t, late); ok {
+		t.Fatal("subscription made after Close is open")
+	}
+
+	// Closing an ended subscription is a no-op
+	sub.Close()
+	late.Close()
+}
@@ -0,0 +1,158 @@
+package events
+
+import (
+	"ai-image-microservice/api-gateway/internal/config"
+	"ai-image-microservice/api-gateway/internal/models"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	MessageTypeSubscribed = "subscribed"
+	MessageTypeEvent      = "event"
+)
+
+const maxClientMessageSize = 4096
+
+// ServerMessage is a message pushed to a WebSocket client
+type ServerMessage struct {
+	Type   string           `json:"type"`
+	UserID string           `json:"user_id,omitempty"`
+	Event  *models.JobEvent `json:"event,omitempty"`
+}
+
+// Session relays the job events of a user over a WebSocket connection.
+// Outgoing messages are queued in a bounded buffer and the connection is
+// closed when a client cannot keep up.
+type Session struct {
+	conn         *websocket.Conn
+	hub          *Hub
+	tenantID     string
+	userID       string
+	send         chan ServerMessage
+	done         chan struct{}
+	closeOnce    sync.Once
+	pingInterval time.Duration
+	writeTimeout time.Duration
+}
+
+// NewSession returns a session carrying the events of every image of the
+// user of a tenant
+func NewSession(conn *websocket.Conn, hub *Hub, tenantID, userID string) *Session {
+	return &Session{
+		conn:         conn,
+		hub:          hub,
+		tenantID:     tenantID,
+		userID:       userID,
+		send:         make(chan ServerMessage, config.AppConfig.Events.WSSendBufferSize),
+		done:         make(chan struct{}),
+		pingInterval: config.AppConfig.Events.HeartbeatInterval,
+		writeTimeout: config.AppConfig.Events.WSWriteTimeout,
+	}
+}
+
+// Run serves the session until the client disconnects or is dropped
+func (s *Session) Run() {
+	sub := s.hub.Subscribe(UserKey(s.tenantID, s.userID))
+	defer sub.Close()
+
+	go s.writeLoop()
+	s.enqueue(ServerMessage{Type: MessageTypeSubscribed, UserID: s.userID})
+	go s.forward(sub)
+	s.readLoop()
+
+	s.close(websocket.CloseNormalClosure, "")
+}
+
+func (s *Session) forward(sub *Subscription) {
+	for event := range sub.Events() {
+		event := event
+		s.enqueue(ServerMessage{Type: MessageTypeEvent, UserID: s.userID, Event: &event})
+	}
+
+	switch {
+	case sub.Lagged():
+		s.close(websocket.ClosePolicyViolation, "client too slow")
+	case s.hub.Closed():
+		s.close(websocket.CloseGoingAway, "server shutting down")
+	}
+}
+
+func (s *Session) enqueue(msg ServerMessage) {
+	select {
+	case <-s.done:
+	case s.send <- msg:
+	default:
+		logrus.Warn("WebSocket send buffer full, closing connection")
+		s.close(websocket.ClosePolicyViolation, "client too slow")
+	}
+}
+
+// readLoop keeps the read deadline going and notices when the client goes
+// away. Clients have nothing to send, so their messages are discarded.
+func (s *Session) readLoop() {
+	s.conn.SetReadLimit(maxClientMessageSize)
+	s.resetReadDeadline()
+	s.conn.SetPongHandler(func(string) error {
+		s.resetReadDeadline()
+		return nil
+	})
+
+	for {
+		if _, _, err := s.conn.NextReader(); err != nil {
+			if websocket.IsUnexpectedCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
+				logrus.Debugf("WebSocket read error: %v", err)
+			}
+			return
+		}
+		s.resetReadDeadline()
+	}
+}
+
+func (s *Session) writeLoop() {
+	ticker := time.NewTicker(s.pingInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.done:
+			return
+		case msg := <-s.send:
+			s.conn.SetWriteDeadline(time.Now().Add(s.writeTimeout))
+			if err := s.conn.WriteJSON(msg); err != nil {
+				logrus.Debugf("WebSocket write error: %v", err)
+				s.close(websocket.CloseAbnormalClosure, "")
+				return
+			}
+		case <-ticker.C:
+			s.conn.SetWriteDeadline(time.Now().Add(s.writeTimeout))
+			if err := s.conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				logrus.Debugf("WebSocket ping error: %v", err)
+				s.close(websocket.CloseAbnormalClosure, "")
+				return
+			}
+		}
+	}
+}
+
+// resetReadDeadline expects some frame (a pong at least) every two heartbeats
+func (s *Session) resetReadDeadline() {
+	s.conn.SetReadDeadline(time.Now().Add(2 * s.pingInterval))
+}
+
+func (s *Session) close(code int, reason string) {
+	s.closeOnce.Do(func() {
+		close(s.done)
+		if code != websocket.CloseAbnormalClosure {
+			_ = s.conn.WriteControl(
+				websocket.CloseMessage,
+				websocket.FormatCloseMessage(code, reason),
+				time.Now().Add(s.writeTimeout),
+			)
+		}
+		s.conn.Close()
+	})
+}
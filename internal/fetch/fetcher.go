@@ -0,0 +1,154 @@
+package fetch
+
+import (
+	"ai-image-microservice/api-gateway/internal/config"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	ErrInvalidURL  = errors.New("invalid image URL")
+	ErrBlockedHost = errors.New("destination address is not allowed")
+	ErrTooLarge    = errors.New("remote image exceeds the size limit")
+	ErrUpstream    = errors.New("remote server returned an error")
+	ErrTooManyHops = errors.New("too many redirects")
+)
+
+const maxRedirects = 5
+
+// Image is a remote image fetched into memory
+type Image struct {
+	Content     []byte
+	ContentType string
+	FileName    string
+}
+
+// Fetcher downloads images over HTTP(S) with a size limit, a timeout and a
+// guard against server-side request forgery: connections to private,
+// loopback and link-local addresses are refused unless allowlisted. The
+// check runs on the resolved address at dial time, so it also covers
+// redirects and DNS rebinding.
+type Fetcher struct {
+	client  *http.Client
+	maxSize int64
+	guard   *Guard
+}
+
+var (
+	fetcher     *Fetcher
+	fetcherOnce sync.Once
+)
+
+func GetFetcher() *Fetcher {
+	fetcherOnce.Do(func() {
+		fetcher = NewFetcher(config.AppConfig.Fetch, config.AppConfig.API.MaxUploadSize)
+	})
+	return fetcher
+}
+
+func NewFetcher(cfg config.FetchConfig, maxSize int64) *Fetcher {
+	f := &Fetcher{
+		maxSize: maxSize,
+		guard:   NewGuard(cfg.Allowlist),
+	}
+
+	f.client = &http.Client{
+		Timeout: cfg.Timeout,
+		Transport: &http.Transport{
+			// Proxies would hide the real destination from the guard
+			Proxy:                 nil,
+			DialContext:           f.guard.DialContext(cfg.Timeout),
+			TLSHandshakeTimeout:   cfg.Timeout,
+			ResponseHeaderTimeout: cfg.Timeout,
+			MaxIdleConns:          10,
+			IdleConnTimeout:       90 * time.Second,
+		},
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= maxRedirects {
+				return ErrTooManyHops
+			}
+			if req.URL.Scheme != "http" && req.URL.Scheme != "https" {
+				return ErrInvalidURL
+			}
+			return nil
+		},
+	}
+
+	return f
+}
+
+// Fetch downloads the image at rawURL
+func (f *Fetcher) Fetch(ctx context.Context, rawURL string) (*Image, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Hostname() == "" {
+		return nil, ErrInvalidURL
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, ErrInvalidURL
+	}
+	req.Header.Set("Accept", "image/jpeg, image/png")
+
+	resp, err := f.client.Do(req)
+	if err != nil {
+		if errors.Is(err, ErrBlockedHost) || errors.Is(err, ErrTooManyHops) || errors.Is(err, ErrInvalidURL) {
+			return nil, unwrapGuardError(err)
+		}
+		return nil, fmt.Errorf("failed to fetch image: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("%w: status %d", ErrUpstream, resp.StatusCode)
+	}
+
+	if resp.ContentLength > f.maxSize {
+		return nil, ErrTooLarge
+	}
+
+	content, err := io.ReadAll(io.LimitReader(resp.Body, f.maxSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read image: %w", err)
+	}
+	if int64(len(content)) > f.maxSize {
+		return nil, ErrTooLarge
+	}
+
+	// Trust the bytes rather than the declared Content-Type
+	contentType := http.DetectContentType(content)
+
+	return &Image{
+		Content:     content,
+		ContentType: contentType,
+		FileName:    fileName(resp.Request.URL, resp.Header.Get("Content-Disposition")),
+	}, nil
+}
+
+func unwrapGuardError(err error) error {
+	for _, target := range []error{ErrBlockedHost, ErrTooManyHops, ErrInvalidURL} {
+		if errors.Is(err, target) {
+			return target
+		}
+	}
+	return err
+}
+
+func fileName(u *url.URL, disposition string) string {
+	if _, params, err := mime.ParseMediaType(disposition); err == nil && params["filename"] != "" {
+		return params["filename"]
+	}
+
+	if i := strings.LastIndex(u.Path, "/"); i >= 0 && i < len(u.Path)-1 {
+		return u.Path[i+1:]
+	}
+	return "image"
+}
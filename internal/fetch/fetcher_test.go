@@ -0,0 +1,140 @@
+package fetch
+
+import (
+	"ai-image-microservice/api-gateway/internal/config"
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+// pngHeader is enough for content sniffing to report image/png
+var pngHeader = []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR")
+
+func TestGuardCheckHost(t *testing.T) {
+	g := NewGuard([]string{"10.1.0.0/16", "192.168.1.10", "Internal.Example"})
+
+	tests := []struct {
+		host    string
+		blocked bool
+	}{
+		{host: "127.0.0.1", blocked: true},
+		{host: "::1", blocked: true},
+		{host: "::ffff:127.0.0.1", blocked: true},
+		{host: "169.254.169.254", blocked: true},
+		{host: "10.2.0.1", blocked: true},
+		{host: "fd00::1", blocked: true},
+		{host: "10.1.2.3"},
+		{host: "192.168.1.10"},
+		{host: "internal.example"},
+		{host: "93.184.215.14"},
+		{host: "2606:4700::1111"},
+	}
+
+	for _, tt := range tests {
+		err := g.CheckHost(context.Background(), tt.host)
+		if blocked := errors.Is(err, ErrBlockedHost); blocked != tt.blocked {
+			t.Errorf("CheckHost(%s) = %v, want blocked %v", tt.host, err, tt.blocked)
+		}
+	}
+}
+
+func newTestFetcher(allowlist []string, maxSize int64) *Fetcher {
+	return NewFetcher(config.FetchConfig{Timeout: 5 * time.Second, Allowlist: allowlist}, maxSize)
+}
+
+func TestFetcherBlocksLoopback(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("blocked server was reached")
+	}))
+	defer server.Close()
+
+	_, err := newTestFetcher(nil, 1024).Fetch(context.Background(), server.URL+"/a.png")
+	if !errors.Is(err, ErrBlockedHost) {
+		t.Fatalf("Fetch = %v, want ErrBlockedHost", err)
+	}
+}
+
+func TestFetcherFetchesAllowlistedHost(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		w.Write(pngHeader)
+	}))
+	defer server.Close()
+
+	image, err := newTestFetcher([]string{"127.0.0.1"}, 1024).Fetch(context.Background(), server.URL+"/photos/face.png")
+	if err != nil {
+		t.Fatalf("Fetch: %v", err)
+	}
+	if !bytes.Equal(image.Content, pngHeader) {
+		t.Fatalf("content = %q", image.Content)
+	}
+	// The declared type is ignored in favour of the content
+	if image.ContentType != "image/png" || image.FileName != "face.png" {
+		t.Fatalf("image = %s %q, want image/png face.png", image.ContentType, image.FileName)
+	}
+}
+
+func TestFetcherBlocksRedirectToPrivateAddress(t *testing.T) {
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("redirect target was reached")
+	}))
+	defer target.Close()
+
+	redirector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, target.URL, http.StatusFound)
+	}))
+	defer redirector.Close()
+
+	// Only the first hop is allowlisted, by name
+	u, _ := url.Parse(redirector.URL)
+	u.Host = "localhost:" + u.Port()
+
+	_, err := newTestFetcher([]string{"localhost"}, 1024).Fetch(context.Background(), u.String())
+	if !errors.Is(err, ErrBlockedHost) {
+		t.Fatalf("Fetch = %v, want ErrBlockedHost", err)
+	}
+}
+
+func TestFetcherErrors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/missing":
+			http.NotFound(w, r)
+		case "/large":
+			w.Write(bytes.Repeat([]byte("x"), 2048))
+		}
+	}))
+	defer server.Close()
+
+	f := newTestFetcher([]string{"127.0.0.1"}, 1024)
+	tests := []struct {
+		url  string
+		want error
+	}{
+		{url: server.URL + "/missing", want: ErrUpstream},
+		{url: server.URL + "/large", want: ErrTooLarge},
+		{url: "ftp://example.com/a.png", want: ErrInvalidURL},
+		{url: "http:///a.png", want: ErrInvalidURL},
+	}
+
+	for _, tt := range tests {
+		if _, err := f.Fetch(context.Background(), tt.url); !errors.Is(err, tt.want) {
+			t.Errorf("Fetch(%s) = %v, want %v", tt.url, err, tt.want)
+		}
+	}
+}
+
+func TestFileName(t *testing.T) {
+	u, _ := url.Parse("https://example.com/images/")
+	if got := fileName(u, ""); got != "image" {
+		t.Fatalf("fileName without a path = %q, want image", got)
+	}
+	if got := fileName(u, `attachment; filename="face.jpg"`); got != "face.jpg" {
+		t.Fatalf("fileName from Content-Disposition = %q, want face.jpg", got)
+	}
+}
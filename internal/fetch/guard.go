@@ -0,0 +1,146 @@
+package fetch
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"syscall"
+	"time"
+)
+
+var blockedNetworks = mustParseCIDRs(
+	"0.0.0.0/8",      // "this" network
+	"10.0.0.0/8",     // private
+	"100.64.0.0/10",  // carrier-grade NAT
+	"127.0.0.0/8",    // loopback
+	"169.254.0.0/16", // link-local, cloud metadata endpoints
+	"172.16.0.0/12",  // private
+	"192.0.0.0/24",   // IETF protocol assignments
+	"192.168.0.0/16", // private
+	"198.18.0.0/15",  // benchmarking
+	"224.0.0.0/4",    // multicast
+	"240.0.0.0/4",    // reserved
+	"::/128",         // unspecified
+	"::1/128",        // loopback
+	"fc00::/7",       // unique local
+	"fe80::/10",      // link-local
+	"ff00::/8",       // multicast
+	"64:ff9b::/96",   // NAT64, may embed private IPv4 addresses
+)
+
+// Guard refuses connections to private, loopback and link-local addresses
+// unless they are allowlisted. It is shared by every client that connects to
+// caller supplied URLs.
+type Guard struct {
+	allowedNets  []*net.IPNet
+	allowedHosts map[string]bool
+}
+
+// NewGuard parses an allowlist of host names, IPs and CIDRs
+func NewGuard(allowlist []string) *Guard {
+	g := &Guard{allowedHosts: make(map[string]bool)}
+
+	for _, entry := range allowlist {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		if _, network, err := net.ParseCIDR(entry); err == nil {
+			g.allowedNets = append(g.allowedNets, network)
+			continue
+		}
+		if ip := net.ParseIP(entry); ip != nil {
+			g.allowedNets = append(g.allowedNets, &net.IPNet{IP: ip, Mask: net.CIDRMask(len(ip)*8, len(ip)*8)})
+			continue
+		}
+		g.allowedHosts[strings.ToLower(entry)] = true
+	}
+
+	return g
+}
+
+// DialContext returns a dial function that checks the resolved address right
+// before connecting, so it also covers redirects and DNS rebinding
+func (g *Guard) DialContext(timeout time.Duration) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	dialer := &net.Dialer{
+		Timeout: timeout,
+		Control: g.checkAddress,
+	}
+
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		host, _, err := net.SplitHostPort(addr)
+		if err == nil && g.allowedHosts[strings.ToLower(host)] {
+			// Allowlisted host names skip the address check
+			return (&net.Dialer{Timeout: timeout}).DialContext(ctx, network, addr)
+		}
+		return dialer.DialContext(ctx, network, addr)
+	}
+}
+
+// CheckHost resolves host and fails with ErrBlockedHost when any of its
+// addresses is not allowed. It lets callers reject a URL up front; the
+// dial-time check still applies when connecting.
+func (g *Guard) CheckHost(ctx context.Context, host string) error {
+	if g.allowedHosts[strings.ToLower(host)] {
+		return nil
+	}
+
+	if ip := net.ParseIP(host); ip != nil {
+		return g.checkIP(ip)
+	}
+
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidURL, err)
+	}
+	for _, addr := range addrs {
+		if err := g.checkIP(addr.IP); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkAddress runs right before connecting, after DNS resolution
+func (g *Guard) checkAddress(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return ErrBlockedHost
+	}
+
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return ErrBlockedHost
+	}
+
+	return g.checkIP(ip)
+}
+
+func (g *Guard) checkIP(ip net.IP) error {
+	for _, network := range g.allowedNets {
+		if network.Contains(ip) {
+			return nil
+		}
+	}
+
+	for _, network := range blockedNetworks {
+		if network.Contains(ip) {
+			return fmt.Errorf("%w: %s", ErrBlockedHost, ip)
+		}
+	}
+
+	return nil
+}
+
+func mustParseCIDRs(cidrs ...string) []*net.IPNet {
+	networks := make([]*net.IPNet, 0, len(cidrs))
+	for _, cidr := range cidrs {
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			panic(err)
+		}
+		networks = append(networks, network)
+	}
+	return networks
+}
@@ -0,0 +1,332 @@
+package handlers
+
+import (
+	"ai-image-microservice/api-gateway/internal/config"
+	"ai-image-microservice/api-gateway/internal/middleware"
+	"ai-image-microservice/api-gateway/internal/models"
+	"ai-image-microservice/api-gateway/internal/services"
+	"ai-image-microservice/api-gateway/internal/store"
+	"ai-image-microservice/api-gateway/pkg/logger"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+type BatchHandler struct {
+	faceService  imageProcessor
+	batchService *services.BatchService
+}
+
+func NewBatchHandler(faceService *services.FaceService, batchService *services.BatchService) *BatchHandler {
+	return &BatchHandler{
+		faceService:  faceService,
+		batchService: batchService,
+	}
+}
+
+// batchEntry is one image of a batch. Content is loaded lazily so large
+// batches are never held in memory all at once.
+type batchEntry struct {
+	fileName string
+	load     func() (imageSubmission, *submissionError)
+}
+
+// SubmitBatch accepts several images, either as repeated "image" multipart
+// parts or as a JSON list of base64 items, and queues each one individually
+func (h *BatchHandler) SubmitBatch(c *gin.Context) {
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, config.AppConfig.Batch.MaxRequestSize)
+
+	var (
+		entries []batchEntry
+		userID  string
+		err     error
+	)
+
+	if c.ContentType() == "application/json" {
+		entries, userID, err = jsonBatchEntries(c)
+	} else {
+		entries, userID, err = multipartBatchEntries(c)
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.BatchResponse{
+			Success: false,
+			Message: "Invalid request",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	h.submitBatch(c, userID, entries, 0)
+}
+
+// GetBatchStatus aggregates the processing state of every image in a batch
+func (h *BatchHandler) GetBatchStatus(c *gin.Context) {
+	batchID := c.Param("batch_id")
+
+	status, err := h.batchService.GetBatchStatus(c.Request.Context(), batchID)
+	if err == nil && !canAccess(c, status.TenantID, status.UserID) {
+		err = store.ErrNotFound
+	}
+	if errors.Is(err, store.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{
+			"success": false,
+			"error":   "Batch not found",
+		})
+		return
+	}
+	if err != nil {
+		logger.FromContext(c.Request.Context()).Errorf("Failed to get batch status: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"error":   "Failed to get batch status",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success":    true,
+		"batch_id":   status.BatchID,
+		"state":      status.State,
+		"total":      status.Total,
+		"counts":     status.Counts,
+		"items":      status.Items,
+		"created_at": status.CreatedAt,
+	})
+}
+
+// submitBatch queues every entry under a new batch ID. Rejected images are
+// reported per item and do not fail the rest of the batch.
+func (h *BatchHandler) submitBatch(c *gin.Context, userID string, entries []batchEntry, skipped int) {
+	if len(entries) == 0 {
+		c.JSON(http.StatusBadRequest, models.BatchResponse{
+			Success: false,
+			Message: "Invalid request",
+			Error:   "The batch contains no images",
+		})
+		return
+	}
+
+	if len(entries) > config.AppConfig.Batch.MaxItems {
+		c.JSON(http.StatusBadRequest, models.BatchResponse{
+			Success: false,
+			Message: "Batch too large",
+			Error:   fmt.Sprintf("A batch may contain at most %d images", config.AppConfig.Batch.MaxItems),
+		})
+		return
+	}
+
+	ctx := c.Request.Context()
+	batch := &models.Batch{
+		ID:        newID(ctx, "batch"),
+		TenantID:  callerTenantID(c),
+		UserID:    callerUserID(c, userID),
+		Items:     make([]models.BatchItem, len(entries)),
+		CreatedAt: time.Now().UTC(),
+	}
+
+	// Items are published concurrently, at most one per publishing channel.
+	// Each in-flight item holds its image in memory.
+	sem := make(chan struct{}, max(config.AppConfig.RabbitMQ.ChannelPoolSize, 1))
+	var wg sync.WaitGroup
+	for i, entry := range entries {
+		sem <- struct{}{}
+		wg.Add(1)
+		go func() {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+
+			item := models.BatchItem{
+				Index:    i,
+				FileName: entry.fileName,
+			}
+
+			imageID, subErr := h.submitEntry(itemContext(ctx, i), batch.ID, entry)
+			if subErr != nil {
+				item.State = models.BatchItemRejected
+				item.Error = subErr.Err
+			} else {
+				item.State = models.BatchItemAccepted
+				item.ImageID = imageID
+			}
+
+			batch.Items[i] = item
+		}()
+	}
+	wg.Wait()
+
+	accepted := 0
+	for _, item := range batch.Items {
+		if item.State == models.BatchItemAccepted {
+			accepted++
+		}
+	}
+
+	if err := h.batchService.SaveBatch(c.Request.Context(), batch); err != nil {
+		logger.FromContext(c.Request.Context()).Errorf("Failed to save batch %s: %v", batch.ID, err)
+	}
+
+	logger.FromContext(c.Request.Context()).WithFields(logrus.Fields{
+		"batch_id": batch.ID,
+		"accepted": accepted,
+		"rejected": len(entries) - accepted,
+		"skipped":  skipped,
+	}).Info("Batch received")
+
+	status := http.StatusAccepted
+	message := fmt.Sprintf("%d of %d images queued for processing", accepted, len(entries))
+	if accepted == 0 {
+		status = http.StatusBadRequest
+		message = "No image in the batch was accepted"
+	}
+
+	c.JSON(status, models.BatchResponse{
+		Success:  accepted > 0,
+		Message:  message,
+		BatchID:  batch.ID,
+		Accepted: accepted,
+		Rejected: len(entries) - accepted,
+		Skipped:  skipped,
+		Items:    batch.Items,
+	})
+}
+
+// itemContext scopes the idempotency key of the request, if any, to one
+// item of the batch
+func itemContext(ctx context.Context, index int) context.Context {
+	key := middleware.IdempotencyKeyFromContext(ctx)
+	if key == "" {
+		return ctx
+	}
+	return middleware.WithIdempotencyKey(ctx, fmt.Sprintf("%s#%d", key, index))
+}
+
+func (h *BatchHandler) submitEntry(ctx context.Context, batchID string, entry batchEntry) (string, *submissionError) {
+	sub, subErr := entry.load()
+	if subErr != nil {
+		return "", subErr
+	}
+
+	sub.BatchID = batchID
+	return submitImage(ctx, h.faceService, sub)
+}
+
+func jsonBatchEntries(c *gin.Context) ([]batchEntry, string, error) {
+	var req models.BatchRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		return nil, "", err
+	}
+
+	entries := make([]batchEntry, 0, len(req.Items))
+	for _, item := range req.Items {
+		item := item
+		entries = append(entries, batchEntry{
+			fileName: item.FileName,
+			load: func() (imageSubmission, *submissionError) {
+				content, err := base64.StdEncoding.DecodeString(item.ImageData)
+				if err != nil {
+					return imageSubmission{}, &submissionError{
+						Status:  http.StatusBadRequest,
+						Message: "Invalid image data",
+						Err:     "image_data must be base64 encoded",
+					}
+				}
+
+				var metadata string
+				if item.Metadata != nil {
+					raw, _ := json.Marshal(item.Metadata)
+					metadata = string(raw)
+				}
+
+				return imageSubmission{
+					FileName:    item.FileName,
+					MimeType:    item.MimeType,
+					Content:     content,
+					Name:        item.Name,
+					UserID:      req.UserID,
+					Metadata:    metadata,
+					CallbackURL: req.CallbackURL,
+				}, nil
+			},
+		})
+	}
+
+	return entries, req.UserID, nil
+}
+
+func multipartBatchEntries(c *gin.Context) ([]batchEntry, string, error) {
+	form, err := c.MultipartForm()
+	if err != nil {
+		return nil, "", err
+	}
+
+	// Fields other than the images apply to the whole batch
+	name := formValue(form, "name")
+	userID := formValue(form, "user_id")
+	metadata := formValue(form, "metadata")
+	callbackURL := formValue(form, "callback_url")
+
+	files := form.File["image"]
+	entries := make([]batchEntry, 0, len(files))
+	for _, file := range files {
+		file := file
+		entries = append(entries, batchEntry{
+			fileName: file.Filename,
+			load: func() (imageSubmission, *submissionError) {
+				if subErr := checkImageSize(file.Size); subErr != nil {
+					return imageSubmission{}, subErr
+				}
+
+				content, err := readFormFile(file)
+				if err != nil {
+					logger.FromContext(c.Request.Context()).Errorf("Failed to read batch file %s: %v", file.Filename, err)
+					return imageSubmission{}, &submissionError{
+						Status:  http.StatusInternalServerError,
+						Message: "Failed to process image",
+						Err:     "Could not read file content",
+					}
+				}
+
+				return imageSubmission{
+					FileName:    file.Filename,
+					MimeType:    file.Header.Get("Content-Type"),
+					Content:     content,
+					Name:        name,
+					UserID:      userID,
+					Metadata:    metadata,
+					CallbackURL: callbackURL,
+				}, nil
+			},
+		})
+	}
+
+	return entries, userID, nil
+}
+
+func readFormFile(header *multipart.FileHeader) ([]byte, error) {
+	file, err := header.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	return io.ReadAll(file)
+}
+
+func formValue(form *multipart.Form, key string) string {
+	if values := form.Value[key]; len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
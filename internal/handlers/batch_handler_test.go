@@ -0,0 +1,147 @@
+package handlers
+
+import (
+	"ai-image-microservice/api-gateway/internal/config"
+	"ai-image-microservice/api-gateway/internal/models"
+	"ai-image-microservice/api-gateway/internal/services"
+	"ai-image-microservice/api-gateway/internal/store"
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// pngHeader is enough for the content type to be sniffed as PNG
+var pngHeader = []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR")
+
+// fakeProcessor queues images in memory, failing those named in fail, and
+// records how many were being queued at once
+type fakeProcessor struct {
+	fail map[string]bool
+
+	mu      sync.Mutex
+	queued  []string
+	running int
+	peak    int
+}
+
+func (p *fakeProcessor) ProcessImage(ctx context.Context, imageData models.ImageReceivedEventData, content []byte) error {
+	p.mu.Lock()
+	p.running++
+	p.peak = max(p.peak, p.running)
+	p.mu.Unlock()
+
+	time.Sleep(5 * time.Millisecond)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.running--
+	if p.fail[imageData.FileName] {
+		return errors.New("broker unavailable")
+	}
+	p.queued = append(p.queued, imageData.FileName)
+	return nil
+}
+
+func newBatchRouter(t *testing.T, processor *fakeProcessor, maxItems int) http.Handler {
+	t.Helper()
+
+	config.AppConfig = &config.Config{
+		API:      config.APIConfig{MaxUploadSize: 1 << 20},
+		Batch:    config.BatchConfig{MaxItems: maxItems, MaxRequestSize: 1 << 20},
+		RabbitMQ: config.RabbitMQConfig{ChannelPoolSize: 3},
+		Store:    config.StoreConfig{Driver: store.DriverMemory},
+	}
+
+	handler := &BatchHandler{faceService: processor, batchService: services.NewBatchService()}
+	router := authenticatedRouter(nil)
+	router.POST("/batch", handler.SubmitBatch)
+	return router
+}
+
+func postBatch(t *testing.T, router http.Handler, count int) (*httptest.ResponseRecorder, models.BatchResponse) {
+	t.Helper()
+
+	req := models.BatchRequest{UserID: "alice"}
+	for i := 0; i < count; i++ {
+		req.Items = append(req.Items, models.BatchRequestItem{
+			ImageData: base64.StdEncoding.EncodeToString(pngHeader),
+			FileName:  fmt.Sprintf("%d.png", i),
+		})
+	}
+	body, _ := json.Marshal(req)
+
+	httpReq := httptest.NewRequest(http.MethodPost, "/batch", bytes.NewReader(body))
+	httpReq.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httpReq)
+
+	var resp models.BatchResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("invalid response %q: %v", rec.Body.String(), err)
+	}
+	return rec, resp
+}
+
+func TestSubmitBatchQueuesEveryItem(t *testing.T) {
+	processor := &fakeProcessor{}
+	router := newBatchRouter(t, processor, 20)
+
+	rec, resp := postBatch(t, router, 12)
+
+	if rec.Code != http.StatusAccepted || resp.Accepted != 12 || resp.Rejected != 0 {
+		t.Fatalf("response = %d %+v, want all 12 items accepted", rec.Code, resp)
+	}
+	for i, item := range resp.Items {
+		if item.Index != i || item.FileName != fmt.Sprintf("%d.png", i) || item.ImageID == "" {
+			t.Fatalf("item %d = %+v, want the items in request order", i, item)
+		}
+	}
+	if len(processor.queued) != 12 {
+		t.Fatalf("queued %d images, want 12", len(processor.queued))
+	}
+	if processor.peak < 2 || processor.peak > 3 {
+		t.Fatalf("peak concurrency = %d, want between 2 and the pool size of 3", processor.peak)
+	}
+}
+
+func TestSubmitBatchReportsFailedItems(t *testing.T) {
+	processor := &fakeProcessor{fail: map[string]bool{"1.png": true, "3.png": true}}
+	router := newBatchRouter(t, processor, 20)
+
+	rec, resp := postBatch(t, router, 5)
+
+	if rec.Code != http.StatusAccepted || resp.Accepted != 3 || resp.Rejected != 2 {
+		t.Fatalf("response = %d %+v, want 3 accepted and 2 rejected", rec.Code, resp)
+	}
+	for i, item := range resp.Items {
+		wantRejected := i == 1 || i == 3
+		if (item.State == models.BatchItemRejected) != wantRejected {
+			t.Fatalf("item %d = %+v, rejected should be %v", i, item, wantRejected)
+		}
+		if wantRejected && (item.ImageID != "" || item.Error == "") {
+			t.Fatalf("rejected item %d = %+v, want an error and no image ID", i, item)
+		}
+	}
+}
+
+func TestSubmitBatchRejectsTooManyItems(t *testing.T) {
+	processor := &fakeProcessor{}
+	router := newBatchRouter(t, processor, 3)
+
+	rec, resp := postBatch(t, router, 4)
+
+	if rec.Code != http.StatusBadRequest || resp.Message != "Batch too large" {
+		t.Fatalf("response = %d %+v, want the batch rejected as too large", rec.Code, resp)
+	}
+	if len(processor.queued) != 0 {
+		t.Fatalf("queued %d images of a rejected batch", len(processor.queued))
+	}
+}
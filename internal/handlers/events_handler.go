@@ -0,0 +1,150 @@
+package handlers
+
+import (
+	"ai-image-microservice/api-gateway/internal/config"
+	"ai-image-microservice/api-gateway/internal/events"
+	"ai-image-microservice/api-gateway/internal/middleware"
+	"ai-image-microservice/api-gateway/internal/services"
+	"ai-image-microservice/api-gateway/internal/store"
+	"ai-image-microservice/api-gateway/pkg/logger"
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+)
+
+var upgrader = websocket.Upgrader{
+	ReadBufferSize:  1024,
+	WriteBufferSize: 1024,
+	CheckOrigin:     middleware.CheckWebSocketOrigin,
+	// Echo the credential marker protocol browsers offer, or they drop the
+	// connection
+	Subprotocols: []string{middleware.WebSocketProtocolBearer, middleware.WebSocketProtocolAPIKey},
+}
+
+type EventsHandler struct {
+	jobService *services.JobService
+	hub        *events.Hub
+}
+
+func NewEventsHandler(jobService *services.JobService) *EventsHandler {
+	return &EventsHandler{
+		jobService: jobService,
+		hub:        events.GetHub(),
+	}
+}
+
+// StreamJobEvents streams the status transitions of an image as Server-Sent Events
+func (h *EventsHandler) StreamJobEvents(c *gin.Context) {
+	imageID := c.Param("image_id")
+
+	if imageID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "Image ID is required",
+		})
+		return
+	}
+
+	// Subscribe before reading the current status so no transition is missed
+	sub := h.hub.Subscribe(events.ImageKey(imageID))
+	defer sub.Close()
+
+	status, err := h.jobService.GetStatus(c.Request.Context(), imageID)
+	if err == nil && !canAccess(c, status.TenantID, status.UserID) {
+		err = store.ErrNotFound
+	}
+	if errors.Is(err, store.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{
+			"success": false,
+			"error":   "Image not found",
+		})
+		return
+	}
+	if err != nil {
+		logger.FromContext(c.Request.Context()).Errorf("Failed to get processing status: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"error":   "Failed to get processing status",
+		})
+		return
+	}
+
+	// The stream outlives the server-wide write timeout
+	if err := http.NewResponseController(c.Writer).SetWriteDeadline(time.Time{}); err != nil {
+		logger.FromContext(c.Request.Context()).Debugf("Failed to clear write deadline for event stream: %v", err)
+	}
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+
+	current := events.FromStatus(status)
+	c.SSEvent(string(current.Type), current)
+	c.Writer.Flush()
+
+	if status.Status.IsTerminal() {
+		return
+	}
+
+	heartbeat := time.NewTicker(config.AppConfig.Events.HeartbeatInterval)
+	defer heartbeat.Stop()
+
+	c.Stream(func(w io.Writer) bool {
+		select {
+		case event, ok := <-sub.Events():
+			if !ok {
+				// Dropped for falling behind; the client reconnects and
+				// receives a fresh snapshot
+				return false
+			}
+			c.SSEvent(string(event.Type), event)
+			return !event.Status.IsTerminal()
+		case <-heartbeat.C:
+			_, err := io.WriteString(w, ": heartbeat\n\n")
+			return err == nil
+		case <-c.Request.Context().Done():
+			return false
+		}
+	})
+}
+
+// SubscribeUserEvents upgrades to a WebSocket carrying the job events of every
+// image of a user. With authentication the user is the caller, who may only
+// name itself in user_id; browsers pass their credentials as a subprotocol
+// (see middleware.WebSocketCredentials). Without authentication the user is
+// the user_id query parameter, like the user_id given on submission.
+func (h *EventsHandler) SubscribeUserEvents(c *gin.Context) {
+	tenantID, userID := "", c.Query("user_id")
+	if identity, ok := middleware.GetIdentity(c); ok {
+		if userID != "" && userID != identity.UserID {
+			c.JSON(http.StatusForbidden, gin.H{
+				"success": false,
+				"error":   "Not allowed to subscribe to this user",
+			})
+			return
+		}
+		tenantID, userID = identity.TenantID, identity.UserID
+	}
+
+	if userID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "user_id is required",
+		})
+		return
+	}
+
+	conn, err := upgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		// The upgrader has already replied with an error status
+		logger.FromContext(c.Request.Context()).Warnf("Failed to upgrade WebSocket connection: %v", err)
+		return
+	}
+
+	events.NewSession(conn, h.hub, tenantID, userID).Run()
+}
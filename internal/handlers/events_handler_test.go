@@ -0,0 +1,126 @@
+package handlers
+
+import (
+	"ai-image-microservice/api-gateway/internal/auth"
+	"ai-image-microservice/api-gateway/internal/config"
+	"ai-image-microservice/api-gateway/internal/events"
+	"ai-image-microservice/api-gateway/internal/middleware"
+	"ai-image-microservice/api-gateway/internal/models"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+)
+
+// keyAuthenticator accepts the API key "secret" as alice of acme
+type keyAuthenticator struct{}
+
+func (keyAuthenticator) Authenticate(r *http.Request) (*models.Identity, error) {
+	switch r.Header.Get(auth.HeaderAPIKey) {
+	case "":
+		return nil, auth.ErrNoCredentials
+	case "secret":
+		return &models.Identity{Method: models.AuthMethodAPIKey, TenantID: "acme", UserID: "alice"}, nil
+	default:
+		return nil, auth.ErrInvalidCredentials
+	}
+}
+
+func newEventsServer(t *testing.T, withAuth bool) (*httptest.Server, *events.Hub) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	config.AppConfig = &config.Config{Events: config.EventsConfig{
+		BufferSize:        8,
+		HeartbeatInterval: time.Minute,
+		WSSendBufferSize:  8,
+		WSWriteTimeout:    time.Second,
+	}}
+
+	hub := events.NewHub(8)
+	router := gin.New()
+	if withAuth {
+		router.Use(middleware.WebSocketCredentials(), middleware.Auth(keyAuthenticator{}))
+	}
+	router.GET("/ws", (&EventsHandler{hub: hub}).SubscribeUserEvents)
+
+	server := httptest.NewServer(router)
+	t.Cleanup(server.Close)
+	return server, hub
+}
+
+func dialEvents(t *testing.T, server *httptest.Server, query string, protocols []string) (*websocket.Conn, *http.Response, error) {
+	t.Helper()
+
+	dialer := websocket.Dialer{Subprotocols: protocols, HandshakeTimeout: time.Second}
+	conn, resp, err := dialer.Dial("ws"+strings.TrimPrefix(server.URL, "http")+"/ws"+query, nil)
+	if conn != nil {
+		t.Cleanup(func() { conn.Close() })
+	}
+	return conn, resp, err
+}
+
+func readMessage(t *testing.T, conn *websocket.Conn) events.ServerMessage {
+	t.Helper()
+
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	var msg events.ServerMessage
+	if err := conn.ReadJSON(&msg); err != nil {
+		t.Fatalf("ReadJSON: %v", err)
+	}
+	return msg
+}
+
+func TestSubscribeUserEventsWithoutAuthentication(t *testing.T) {
+	server, hub := newEventsServer(t, false)
+
+	if _, resp, err := dialEvents(t, server, "", nil); err == nil || resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("dial without user_id = %v, %v; want a 400 response", resp, err)
+	}
+
+	conn, _, err := dialEvents(t, server, "?user_id=alice", nil)
+	if err != nil {
+		t.Fatalf("Dial: %v", err)
+	}
+	if msg := readMessage(t, conn); msg.Type != events.MessageTypeSubscribed || msg.UserID != "alice" {
+		t.Fatalf("first message = %+v, want the subscription of alice", msg)
+	}
+
+	hub.Publish(models.JobEvent{ImageID: "image-1", UserID: "alice", Status: models.JobStateQueued})
+	msg := readMessage(t, conn)
+	if msg.Type != events.MessageTypeEvent || msg.Event == nil || msg.Event.ImageID != "image-1" {
+		t.Fatalf("message = %+v, want the event of image-1", msg)
+	}
+}
+
+func TestSubscribeUserEventsWithSubprotocolCredentials(t *testing.T) {
+	server, hub := newEventsServer(t, true)
+
+	if _, resp, err := dialEvents(t, server, "", nil); err == nil || resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("dial without credentials = %v, %v; want a 401 response", resp, err)
+	}
+	if _, resp, err := dialEvents(t, server, "?user_id=bob", []string{middleware.WebSocketProtocolAPIKey, "secret"}); err == nil || resp.StatusCode != http.StatusForbidden {
+		t.Fatalf("dial for another user = %v, %v; want a 403 response", resp, err)
+	}
+
+	conn, resp, err := dialEvents(t, server, "", []string{middleware.WebSocketProtocolAPIKey, "secret"})
+	if err != nil {
+		t.Fatalf("Dial: %v", err)
+	}
+	// Only the marker protocol is echoed, never the key
+	if got := resp.Header.Get("Sec-WebSocket-Protocol"); got != middleware.WebSocketProtocolAPIKey {
+		t.Fatalf("selected subprotocol = %q, want %q", got, middleware.WebSocketProtocolAPIKey)
+	}
+	readMessage(t, conn)
+
+	// alice of another tenant is another user
+	hub.Publish(models.JobEvent{ImageID: "image-0", TenantID: "globex", UserID: "alice"})
+	hub.Publish(models.JobEvent{ImageID: "image-1", TenantID: "acme", UserID: "alice"})
+	if msg := readMessage(t, conn); msg.Event == nil || msg.Event.ImageID != "image-1" {
+		t.Fatalf("message = %+v, want the event of image-1", msg)
+	}
+}
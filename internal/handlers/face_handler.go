@@ -1,25 +1,32 @@
 package handlers
 
 import (
+	"ai-image-microservice/api-gateway/internal/fetch"
+	"ai-image-microservice/api-gateway/internal/middleware"
 	"ai-image-microservice/api-gateway/internal/models"
 	"ai-image-microservice/api-gateway/internal/services"
-	"encoding/base64"
+	"ai-image-microservice/api-gateway/internal/store"
+	"ai-image-microservice/api-gateway/pkg/logger"
 	"encoding/json"
+	"errors"
 	"io"
+	"net"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
-	"github.com/google/uuid"
-	"github.com/sirupsen/logrus"
 )
 
 type FaceHandler struct {
 	faceService *services.FaceService
+	jobService  *services.JobService
+	fetcher     *fetch.Fetcher
 }
 
-func NewFaceHandler(faceService *services.FaceService) *FaceHandler {
+func NewFaceHandler(faceService *services.FaceService, jobService *services.JobService) *FaceHandler {
 	return &FaceHandler{
 		faceService: faceService,
+		jobService:  jobService,
+		fetcher:     fetch.GetFetcher(),
 	}
 }
 
@@ -37,31 +44,16 @@ func (h *FaceHandler) ProcessImage(c *gin.Context) {
 		return
 	}
 
-	// Validate file size
-	if req.Image.Size > 10*1024*1024 { // 10MB limit
-		c.JSON(http.StatusBadRequest, models.ProcessImageResponse{
-			Success: false,
-			Message: "File too large",
-			Error:   "Maximum file size is 10MB",
-		})
-		return
-	}
-
-	// Validate mime type
-	mimeType := req.Image.Header.Get("Content-Type")
-	if mimeType != "image/jpeg" && mimeType != "image/png" && mimeType != "image/jpg" {
-		c.JSON(http.StatusBadRequest, models.ProcessImageResponse{
-			Success: false,
-			Message: "Invalid file type",
-			Error:   "Only JPEG and PNG images are supported",
-		})
+	// Validate file size before reading the content
+	if subErr := checkImageSize(req.Image.Size); subErr != nil {
+		c.JSON(subErr.Status, subErr.Response())
 		return
 	}
 
 	// Open uploaded file
 	file, err := req.Image.Open()
 	if err != nil {
-		logrus.Errorf("Failed to open uploaded file: %v", err)
+		logger.FromContext(c.Request.Context()).Errorf("Failed to open uploaded file: %v", err)
 		c.JSON(http.StatusInternalServerError, models.ProcessImageResponse{
 			Success: false,
 			Message: "Failed to process image",
@@ -74,7 +66,7 @@ func (h *FaceHandler) ProcessImage(c *gin.Context) {
 	// Read file content
 	imageData, err := io.ReadAll(file)
 	if err != nil {
-		logrus.Errorf("Failed to read file: %v", err)
+		logger.FromContext(c.Request.Context()).Errorf("Failed to read file: %v", err)
 		c.JSON(http.StatusInternalServerError, models.ProcessImageResponse{
 			Success: false,
 			Message: "Failed to process image",
@@ -83,48 +75,95 @@ func (h *FaceHandler) ProcessImage(c *gin.Context) {
 		return
 	}
 
-	// Generate image ID
-	imageID := uuid.New().String()
-
-	// Parse metadata if provided
-	var metadata map[string]interface{}
-	if req.Metadata != "" {
-		if err := json.Unmarshal([]byte(req.Metadata), &metadata); err != nil {
-			logrus.Warnf("Failed to parse metadata: %v", err)
-			// Don't fail the request, just log the warning
-		}
+	imageID, subErr := submitImage(c.Request.Context(), h.faceService, imageSubmission{
+		FileName:    req.Image.Filename,
+		MimeType:    req.Image.Header.Get("Content-Type"),
+		Content:     imageData,
+		Name:        req.Name,
+		UserID:      req.UserID,
+		Metadata:    req.Metadata,
+		CallbackURL: req.CallbackURL,
+	})
+	if subErr != nil {
+		c.JSON(subErr.Status, subErr.Response())
+		return
 	}
 
-	// Create event data
-	eventData := models.ImageReceivedEventData{
-		ImageID:   imageID,
-		ImageData: base64.StdEncoding.EncodeToString(imageData),
-		FileName:  req.Image.Filename,
-		FileSize:  req.Image.Size,
-		MimeType:  mimeType,
-		UserID:    req.UserID,
-		Name:      req.Name,
-		Metadata:  metadata,
+	c.JSON(http.StatusAccepted, models.ProcessImageResponse{
+		Success: true,
+		Message: "Image received and queued for processing",
+		ImageID: imageID,
+	})
+}
+
+// ProcessImageURL fetches an image from a URL and processes it like an upload
+func (h *FaceHandler) ProcessImageURL(c *gin.Context) {
+	var req models.ProcessImageURLRequest
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ProcessImageResponse{
+			Success: false,
+			Message: "Invalid request",
+			Error:   err.Error(),
+		})
+		return
 	}
 
-	// Process image through service
-	if err := h.faceService.ProcessImage(c.Request.Context(), eventData); err != nil {
-		logrus.Errorf("Failed to process image: %v", err)
-		c.JSON(http.StatusInternalServerError, models.ProcessImageResponse{
+	image, err := h.fetcher.Fetch(c.Request.Context(), req.ImageURL)
+	if err != nil {
+		status, message := fetchErrorResponse(err)
+		logger.FromContext(c.Request.Context()).Warnf("Failed to fetch image from %s: %v", req.ImageURL, err)
+		c.JSON(status, models.ProcessImageResponse{
 			Success: false,
-			Message: "Failed to process image",
+			Message: message,
 			Error:   err.Error(),
 		})
 		return
 	}
 
+	var metadata string
+	if req.Metadata != nil {
+		raw, _ := json.Marshal(req.Metadata)
+		metadata = string(raw)
+	}
+
+	imageID, subErr := submitImage(c.Request.Context(), h.faceService, imageSubmission{
+		FileName:    image.FileName,
+		MimeType:    image.ContentType,
+		Content:     image.Content,
+		Name:        req.Name,
+		UserID:      req.UserID,
+		Metadata:    metadata,
+		CallbackURL: req.CallbackURL,
+	})
+	if subErr != nil {
+		c.JSON(subErr.Status, subErr.Response())
+		return
+	}
+
 	c.JSON(http.StatusAccepted, models.ProcessImageResponse{
 		Success: true,
-		Message: "Image received and queued for processing",
+		Message: "Image fetched and queued for processing",
 		ImageID: imageID,
 	})
 }
 
+func fetchErrorResponse(err error) (int, string) {
+	var netErr net.Error
+	switch {
+	case errors.Is(err, fetch.ErrInvalidURL):
+		return http.StatusBadRequest, "Invalid image URL"
+	case errors.Is(err, fetch.ErrBlockedHost):
+		return http.StatusBadRequest, "Image URL is not allowed"
+	case errors.Is(err, fetch.ErrTooLarge):
+		return http.StatusBadRequest, "File too large"
+	case errors.As(err, &netErr) && netErr.Timeout():
+		return http.StatusGatewayTimeout, "Timed out fetching image"
+	default:
+		return http.StatusBadGateway, "Failed to fetch image"
+	}
+}
+
 // GetProcessingStatus gets the processing status of an image
 func (h *FaceHandler) GetProcessingStatus(c *gin.Context) {
 	imageID := c.Param("image_id")
@@ -137,12 +176,147 @@ func (h *FaceHandler) GetProcessingStatus(c *gin.Context) {
 		return
 	}
 
-	// For now, return a mock response
-	// In production, this would query a status store
+	status, err := h.jobService.GetStatus(c.Request.Context(), imageID)
+	if err == nil && !canAccess(c, status.TenantID, status.UserID) {
+		err = store.ErrNotFound
+	}
+	if errors.Is(err, store.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{
+			"success": false,
+			"error":   "Image not found",
+		})
+		return
+	}
+	if err != nil {
+		logger.FromContext(c.Request.Context()).Errorf("Failed to get processing status: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"error":   "Failed to get processing status",
+		})
+		return
+	}
+
 	c.JSON(http.StatusOK, gin.H{
-		"success":  true,
-		"image_id": imageID,
-		"status":   "processing",
-		"message":  "Image is being processed",
+		"success":      true,
+		"image_id":     status.ImageID,
+		"status":       status.Status,
+		"message":      status.Message,
+		"error":        status.Error,
+		"created_at":   status.CreatedAt,
+		"updated_at":   status.UpdatedAt,
+		"completed_at": status.CompletedAt,
 	})
 }
+
+// GetResults returns the face recognition output of a processed image
+func (h *FaceHandler) GetResults(c *gin.Context) {
+	imageID := c.Param("image_id")
+
+	if imageID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "Image ID is required",
+		})
+		return
+	}
+
+	if !h.authorizeImage(c, imageID) {
+		return
+	}
+
+	results, err := h.jobService.GetResults(c.Request.Context(), imageID)
+	if errors.Is(err, store.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{
+			"success": false,
+			"error":   "Results not available",
+		})
+		return
+	}
+	if err != nil {
+		logger.FromContext(c.Request.Context()).Errorf("Failed to get results: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"error":   "Failed to get results",
+		})
+		return
+	}
+
+	faces := results.Results
+	if faces == nil {
+		faces = []models.FaceRecognitionResult{}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success":       true,
+		"image_id":      results.ImageID,
+		"faces_found":   results.FacesFound,
+		"processing_ms": results.ProcessingMs,
+		"results":       faces,
+		"storage_url":   results.StorageURL,
+		"recognized_at": results.RecognizedAt,
+		"saved_at":      results.SavedAt,
+	})
+}
+
+// GetWebhookDeliveries lists the callback deliveries and attempts for an image
+func (h *FaceHandler) GetWebhookDeliveries(c *gin.Context) {
+	imageID := c.Param("image_id")
+
+	if imageID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "Image ID is required",
+		})
+		return
+	}
+
+	if !h.authorizeImage(c, imageID) {
+		return
+	}
+
+	deliveries, err := h.jobService.ListWebhookDeliveries(c.Request.Context(), imageID)
+	if err != nil {
+		logger.FromContext(c.Request.Context()).Errorf("Failed to list webhook deliveries: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"error":   "Failed to list webhook deliveries",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success":    true,
+		"image_id":   imageID,
+		"deliveries": deliveries,
+	})
+}
+
+// authorizeImage checks that an authenticated caller owns the image, replying
+// with 404 otherwise. Unauthenticated routes are not restricted.
+func (h *FaceHandler) authorizeImage(c *gin.Context, imageID string) bool {
+	if _, ok := middleware.GetIdentity(c); !ok {
+		return true
+	}
+
+	status, err := h.jobService.GetStatus(c.Request.Context(), imageID)
+	if err == nil && !canAccess(c, status.TenantID, status.UserID) {
+		err = store.ErrNotFound
+	}
+	if errors.Is(err, store.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{
+			"success": false,
+			"error":   "Image not found",
+		})
+		return false
+	}
+	if err != nil {
+		logger.FromContext(c.Request.Context()).Errorf("Failed to get processing status: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"error":   "Failed to get processing status",
+		})
+		return false
+	}
+
+	return true
+}
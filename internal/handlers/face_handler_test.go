@@ -0,0 +1,112 @@
+package handlers
+
+import (
+	"ai-image-microservice/api-gateway/internal/config"
+	"ai-image-microservice/api-gateway/internal/models"
+	"ai-image-microservice/api-gateway/internal/services"
+	"ai-image-microservice/api-gateway/internal/store"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// saveJob records a job of alice of acme along with its results, if any
+func saveJob(t *testing.T, imageID string, state models.JobState, results *models.ImageResults) {
+	t.Helper()
+	ctx := context.Background()
+
+	now := time.Now().UTC()
+	status := &models.JobStatus{ImageID: imageID, TenantID: "acme", UserID: "alice", Status: state, CreatedAt: now, UpdatedAt: now}
+	if err := store.GetStatusStore().Save(ctx, status); err != nil {
+		t.Fatalf("save status: %v", err)
+	}
+	if results != nil {
+		if err := store.GetResultStore().Save(ctx, results); err != nil {
+			t.Fatalf("save results: %v", err)
+		}
+	}
+}
+
+func getResults(t *testing.T, identity *models.Identity, imageID string) (*httptest.ResponseRecorder, map[string]interface{}) {
+	t.Helper()
+
+	config.AppConfig = &config.Config{Store: config.StoreConfig{Driver: store.DriverMemory}}
+
+	handler := &FaceHandler{jobService: services.NewJobService()}
+	router := authenticatedRouter(identity)
+	router.GET("/results/:image_id", handler.GetResults)
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/results/"+imageID, nil))
+
+	var body map[string]interface{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("invalid response %q: %v", rec.Body.String(), err)
+	}
+	return rec, body
+}
+
+func TestGetResultsUnknownImage(t *testing.T) {
+	for _, identity := range []*models.Identity{nil, {TenantID: "acme", UserID: "alice"}} {
+		rec, _ := getResults(t, identity, "results-unknown")
+		if rec.Code != http.StatusNotFound {
+			t.Fatalf("status = %d, want %d", rec.Code, http.StatusNotFound)
+		}
+	}
+}
+
+func TestGetResultsWhileProcessing(t *testing.T) {
+	config.AppConfig = &config.Config{Store: config.StoreConfig{Driver: store.DriverMemory}}
+
+	recognizedAt := time.Now().UTC()
+	saveJob(t, "results-partial", models.JobStateProcessing, &models.ImageResults{
+		ImageID:      "results-partial",
+		FacesFound:   1,
+		Results:      []models.FaceRecognitionResult{{FaceID: "face-1", Confidence: 0.9}},
+		RecognizedAt: &recognizedAt,
+	})
+
+	rec, body := getResults(t, &models.Identity{TenantID: "acme", UserID: "alice"}, "results-partial")
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d: %v", rec.Code, http.StatusOK, body)
+	}
+	// Recognition output is returned before the results are saved
+	if body["faces_found"] != float64(1) || body["recognized_at"] == nil {
+		t.Fatalf("body = %v, want the recognition results", body)
+	}
+	if body["saved_at"] != nil || body["storage_url"] != "" {
+		t.Fatalf("body = %v, want no storage details yet", body)
+	}
+}
+
+func TestGetResultsChecksOwnership(t *testing.T) {
+	config.AppConfig = &config.Config{Store: config.StoreConfig{Driver: store.DriverMemory}}
+	saveJob(t, "results-owned", models.JobStateCompleted, &models.ImageResults{ImageID: "results-owned"})
+
+	tests := []struct {
+		name     string
+		identity *models.Identity
+		want     int
+	}{
+		{name: "owner", identity: &models.Identity{TenantID: "acme", UserID: "alice"}, want: http.StatusOK},
+		{name: "another user", identity: &models.Identity{TenantID: "acme", UserID: "bob"}, want: http.StatusNotFound},
+		{name: "another tenant", identity: &models.Identity{TenantID: "globex", UserID: "alice"}, want: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec, body := getResults(t, tt.identity, "results-owned")
+			if rec.Code != tt.want {
+				t.Fatalf("status = %d, want %d: %v", rec.Code, tt.want, body)
+			}
+			// Images of others are reported like missing ones
+			if tt.want == http.StatusNotFound && body["error"] != "Image not found" {
+				t.Fatalf("body = %v, want the image reported as not found", body)
+			}
+		})
+	}
+}
@@ -1,8 +1,11 @@
 package handlers
 
 import (
+	"ai-image-microservice/api-gateway/internal/config"
 	"ai-image-microservice/api-gateway/internal/models"
+	"ai-image-microservice/api-gateway/internal/outbox"
 	"ai-image-microservice/api-gateway/internal/rabbitmq"
+	"ai-image-microservice/api-gateway/pkg/logger"
 	"net/http"
 	"time"
 
@@ -19,7 +22,7 @@ func (h *HealthHandler) Health(c *gin.Context) {
 	services := make(map[string]string)
 
 	conn := rabbitmq.GetConnection()
-	if _, err := conn.GetChannel(); err != nil {
+	if !conn.IsConnected() {
 		services["rabbitmq"] = "unhealthy"
 	} else {
 		services["rabbitmq"] = "healthy"
@@ -40,9 +43,11 @@ func (h *HealthHandler) Health(c *gin.Context) {
 	})
 }
 
+// Ready fails while RabbitMQ is down, unless the outbox can hold submitted
+// images until it is back
 func (h *HealthHandler) Ready(c *gin.Context) {
 	conn := rabbitmq.GetConnection()
-	if _, err := conn.GetChannel(); err != nil {
+	if !conn.IsConnected() && !config.AppConfig.Outbox.Enabled {
 		c.JSON(http.StatusServiceUnavailable, gin.H{
 			"ready": false,
 			"error": "RabbitMQ not connected",
@@ -54,3 +59,25 @@ func (h *HealthHandler) Ready(c *gin.Context) {
 		"ready": true,
 	})
 }
+
+// RabbitMQ reports the state of the publishing channel pool and the number
+// of events waiting in the outbox
+func (h *HealthHandler) RabbitMQ(c *gin.Context) {
+	conn := rabbitmq.GetConnection()
+
+	response := gin.H{
+		"connected":    conn.IsConnected(),
+		"channel_pool": conn.PoolStats(),
+	}
+
+	if config.AppConfig.Outbox.Enabled {
+		pending, err := outbox.GetRelay().Pending(c.Request.Context())
+		if err != nil {
+			logger.FromContext(c.Request.Context()).Errorf("Failed to count outbox events: %v", err)
+		} else {
+			response["outbox_pending"] = pending
+		}
+	}
+
+	c.JSON(http.StatusOK, response)
+}
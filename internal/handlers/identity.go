@@ -0,0 +1,36 @@
+package handlers
+
+import (
+	"ai-image-microservice/api-gateway/internal/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+// callerUserID returns the authenticated user, falling back to the user ID
+// supplied by the client on routes without authentication
+func callerUserID(c *gin.Context, claimed string) string {
+	if identity, ok := middleware.GetIdentity(c); ok {
+		return identity.UserID
+	}
+	return claimed
+}
+
+// callerTenantID returns the tenant of the authenticated caller, or "" on
+// routes without authentication
+func callerTenantID(c *gin.Context) string {
+	if identity, ok := middleware.GetIdentity(c); ok {
+		return identity.TenantID
+	}
+	return ""
+}
+
+// canAccess reports whether the caller may see a resource owned by userID
+// of tenantID. User IDs are only unique within a tenant, so both must match.
+// Other users' resources are reported as not found so their IDs don't leak.
+func canAccess(c *gin.Context, tenantID, userID string) bool {
+	identity, ok := middleware.GetIdentity(c)
+	if !ok {
+		return true
+	}
+	return identity.TenantID == tenantID && identity.UserID == userID
+}
@@ -0,0 +1,63 @@
+package handlers
+
+import (
+	"ai-image-microservice/api-gateway/internal/middleware"
+	"ai-image-microservice/api-gateway/internal/models"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// staticAuthenticator authenticates every request as the same identity
+type staticAuthenticator struct {
+	identity *models.Identity
+}
+
+func (a staticAuthenticator) Authenticate(r *http.Request) (*models.Identity, error) {
+	return a.identity, nil
+}
+
+// authenticatedRouter returns a router whose requests are made by identity,
+// or unauthenticated when identity is nil
+func authenticatedRouter(identity *models.Identity) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+
+	router := gin.New()
+	if identity != nil {
+		router.Use(middleware.Auth(staticAuthenticator{identity: identity}))
+	}
+	return router
+}
+
+func TestCanAccessComparesTenantAndUser(t *testing.T) {
+	tests := []struct {
+		name     string
+		identity *models.Identity
+		want     bool
+	}{
+		{name: "owner", identity: &models.Identity{TenantID: "acme", UserID: "alice"}, want: true},
+		{name: "same user ID in another tenant", identity: &models.Identity{TenantID: "globex", UserID: "alice"}},
+		{name: "another user of the tenant", identity: &models.Identity{TenantID: "acme", UserID: "bob"}},
+		// Service keys of a tenant carry the tenant ID as their user ID
+		{name: "service key named after a user", identity: &models.Identity{TenantID: "alice", UserID: "alice"}},
+		{name: "unauthenticated route", want: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got bool
+			router := authenticatedRouter(tt.identity)
+			router.GET("/", func(c *gin.Context) {
+				got = canAccess(c, "acme", "alice")
+			})
+
+			router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+
+			if got != tt.want {
+				t.Fatalf("canAccess = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,207 @@
+package handlers
+
+import (
+	"ai-image-microservice/api-gateway/internal/auth"
+	"ai-image-microservice/api-gateway/internal/config"
+	"ai-image-microservice/api-gateway/internal/fetch"
+	"ai-image-microservice/api-gateway/internal/metrics"
+	"ai-image-microservice/api-gateway/internal/middleware"
+	"ai-image-microservice/api-gateway/internal/models"
+	"ai-image-microservice/api-gateway/internal/services"
+	"ai-image-microservice/api-gateway/internal/store"
+	"ai-image-microservice/api-gateway/internal/webhook"
+	"ai-image-microservice/api-gateway/pkg/logger"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/google/uuid"
+)
+
+// imageSubmission is an image received through any of the ingestion
+// endpoints, before validation
+type imageSubmission struct {
+	FileName    string
+	MimeType    string
+	Content     []byte
+	Name        string
+	UserID      string
+	Metadata    string // JSON string
+	CallbackURL string
+	BatchID     string
+}
+
+// submissionError is a failed submission along with the response to send
+type submissionError struct {
+	Status  int
+	Message string
+	Err     string
+}
+
+func (e *submissionError) Error() string {
+	return e.Err
+}
+
+func (e *submissionError) Response() models.ProcessImageResponse {
+	return models.ProcessImageResponse{
+		Success: false,
+		Message: e.Message,
+		Error:   e.Err,
+	}
+}
+
+// checkImageSize rejects images above the upload limit; endpoints call it
+// early when the size is known before reading the content
+func checkImageSize(size int64) *submissionError {
+	if size > config.AppConfig.API.MaxUploadSize {
+		return &submissionError{
+			Status:  http.StatusBadRequest,
+			Message: "File too large",
+			Err:     fmt.Sprintf("Maximum file size is %dMB", config.AppConfig.API.MaxUploadSize/(1024*1024)),
+		}
+	}
+	return nil
+}
+
+// validate applies the checks shared by every ingestion endpoint
+func (s *imageSubmission) validate(ctx context.Context) *submissionError {
+	if err := checkImageSize(int64(len(s.Content))); err != nil {
+		return err
+	}
+
+	if s.MimeType == "" {
+		s.MimeType = http.DetectContentType(s.Content)
+	}
+
+	if s.MimeType != "image/jpeg" && s.MimeType != "image/png" && s.MimeType != "image/jpg" {
+		return &submissionError{
+			Status:  http.StatusBadRequest,
+			Message: "Invalid file type",
+			Err:     "Only JPEG and PNG images are supported",
+		}
+	}
+
+	if s.CallbackURL != "" {
+		if err := checkCallbackURL(ctx, s.CallbackURL); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func checkCallbackURL(ctx context.Context, callbackURL string) *submissionError {
+	err := webhook.GetDispatcher().CheckCallbackURL(ctx, callbackURL)
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, webhook.ErrDisabled):
+		return &submissionError{
+			Status:  http.StatusBadRequest,
+			Message: "Webhooks are not available",
+			Err:     "callback_url is not supported because webhook signing is not configured",
+		}
+	case errors.Is(err, fetch.ErrBlockedHost):
+		return &submissionError{
+			Status:  http.StatusBadRequest,
+			Message: "Invalid callback URL",
+			Err:     "callback_url must not target a private or loopback address",
+		}
+	default:
+		return &submissionError{
+			Status:  http.StatusBadRequest,
+			Message: "Invalid callback URL",
+			Err:     webhook.ErrInvalidURL.Error(),
+		}
+	}
+}
+
+// idNamespace scopes the IDs derived from idempotency keys
+var idNamespace = uuid.MustParse("0c1f6a8e-2d4b-4f7a-9e35-8b6d2a4c7e19")
+
+// newID returns a new ID for a record of the given kind. Requests made with
+// an Idempotency-Key get an ID derived from it, so a retried request
+// recreates the same records and events.
+func newID(ctx context.Context, kind string) string {
+	if key := middleware.IdempotencyKeyFromContext(ctx); key != "" {
+		return uuid.NewSHA1(idNamespace, []byte(kind+":"+key)).String()
+	}
+	return uuid.New().String()
+}
+
+// imageProcessor queues validated images; services.FaceService implements it
+type imageProcessor interface {
+	ProcessImage(ctx context.Context, imageData models.ImageReceivedEventData, content []byte) error
+}
+
+// submitImage validates the image and queues it through FaceService.ProcessImage,
+// returning the generated image ID
+func submitImage(ctx context.Context, faceService imageProcessor, sub imageSubmission) (string, *submissionError) {
+	if err := sub.validate(ctx); err != nil {
+		return "", err
+	}
+	metrics.ObserveUploadSize(len(sub.Content))
+
+	// The authenticated identity takes precedence over client supplied IDs
+	var tenantID string
+	if identity, ok := auth.IdentityFromContext(ctx); ok {
+		sub.UserID = identity.UserID
+		tenantID = identity.TenantID
+	}
+
+	imageID := newID(ctx, "image")
+
+	// Parse metadata if provided
+	var metadata map[string]interface{}
+	if sub.Metadata != "" {
+		if err := json.Unmarshal([]byte(sub.Metadata), &metadata); err != nil {
+			logger.FromContext(ctx).Warnf("Failed to parse metadata: %v", err)
+			// Don't fail the request, just log the warning
+		}
+	}
+
+	// Create event data
+	eventData := models.ImageReceivedEventData{
+		ImageID:  imageID,
+		FileName: sub.FileName,
+		MimeType: sub.MimeType,
+		UserID:   sub.UserID,
+		TenantID: tenantID,
+		Name:     sub.Name,
+		BatchID:  sub.BatchID,
+		Metadata: metadata,
+
+		CallbackURL: sub.CallbackURL,
+	}
+
+	// Process image through service
+	if err := faceService.ProcessImage(ctx, eventData, sub.Content); err != nil {
+		var quotaErr *services.QuotaExceededError
+		if errors.As(err, &quotaErr) {
+			return "", &submissionError{
+				Status:  http.StatusTooManyRequests,
+				Message: "Quota exceeded",
+				Err:     quotaErr.Error(),
+			}
+		}
+
+		if errors.Is(err, store.ErrOutboxFull) {
+			return "", &submissionError{
+				Status:  http.StatusServiceUnavailable,
+				Message: "Service temporarily unavailable",
+				Err:     "Too many images are waiting to be queued, retry later",
+			}
+		}
+
+		logger.FromContext(ctx).Errorf("Failed to process image: %v", err)
+		return "", &submissionError{
+			Status:  http.StatusInternalServerError,
+			Message: "Failed to process image",
+			Err:     err.Error(),
+		}
+	}
+
+	return imageID, nil
+}
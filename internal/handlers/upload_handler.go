@@ -0,0 +1,294 @@
+package handlers
+
+import (
+	"ai-image-microservice/api-gateway/internal/config"
+	"ai-image-microservice/api-gateway/internal/services"
+	"ai-image-microservice/api-gateway/internal/upload"
+	"ai-image-microservice/api-gateway/pkg/logger"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	tusVersion    = "1.0.0"
+	tusExtensions = "creation,termination,expiration"
+
+	headerImageID = "X-Image-ID"
+)
+
+// UploadHandler implements the tus resumable upload protocol (core,
+// creation, termination and expiration). Completed uploads are submitted
+// like regular multipart uploads.
+type UploadHandler struct {
+	faceService *services.FaceService
+	store       *upload.Store
+}
+
+func NewUploadHandler(faceService *services.FaceService) *UploadHandler {
+	return &UploadHandler{
+		faceService: faceService,
+		store:       upload.GetStore(),
+	}
+}
+
+// Options advertises the supported tus version and extensions
+func (h *UploadHandler) Options(c *gin.Context) {
+	c.Header("Tus-Resumable", tusVersion)
+	c.Header("Tus-Version", tusVersion)
+	c.Header("Tus-Extension", tusExtensions)
+	c.Header("Tus-Max-Size", strconv.FormatInt(config.AppConfig.API.MaxUploadSize, 10))
+	c.Status(http.StatusNoContent)
+}
+
+// Create starts a new upload of Upload-Length bytes
+func (h *UploadHandler) Create(c *gin.Context) {
+	if !h.checkVersion(c) {
+		return
+	}
+
+	length, err := strconv.ParseInt(c.GetHeader("Upload-Length"), 10, 64)
+	if err != nil || length <= 0 {
+		h.fail(c, http.StatusBadRequest, "Upload-Length header must be a positive integer")
+		return
+	}
+
+	if length > config.AppConfig.API.MaxUploadSize {
+		h.fail(c, http.StatusRequestEntityTooLarge, "Upload-Length exceeds Tus-Max-Size")
+		return
+	}
+
+	metadata, err := parseUploadMetadata(c.GetHeader("Upload-Metadata"))
+	if err != nil {
+		h.fail(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	up, err := h.store.Create(length, metadata, callerTenantID(c), callerUserID(c, ""))
+	if err != nil {
+		logger.FromContext(c.Request.Context()).Errorf("Failed to create upload: %v", err)
+		h.fail(c, http.StatusInternalServerError, "Failed to create upload")
+		return
+	}
+
+	c.Header("Location", strings.TrimSuffix(c.Request.URL.Path, "/")+"/"+up.ID)
+	c.Header("Upload-Expires", up.ExpiresAt.Format(http.TimeFormat))
+	c.Status(http.StatusCreated)
+}
+
+// Head reports how many bytes of the upload have been received
+func (h *UploadHandler) Head(c *gin.Context) {
+	c.Header("Tus-Resumable", tusVersion)
+	c.Header("Cache-Control", "no-store")
+
+	up, err := h.store.Get(c.Param("upload_id"))
+	if err == nil && !canAccess(c, up.TenantID, up.Owner) {
+		err = upload.ErrNotFound
+	}
+	if err != nil {
+		h.storeError(c, err)
+		return
+	}
+
+	c.Header("Upload-Offset", strconv.FormatInt(up.Offset, 10))
+	c.Header("Upload-Length", strconv.FormatInt(up.Length, 10))
+	c.Header("Upload-Expires", up.ExpiresAt.Format(http.TimeFormat))
+	if up.ImageID != "" {
+		c.Header(headerImageID, up.ImageID)
+	}
+	c.Status(http.StatusOK)
+}
+
+// Patch appends a chunk to the upload. Once the last byte arrives the image
+// is validated and queued, and its ID is returned in the X-Image-ID header.
+func (h *UploadHandler) Patch(c *gin.Context) {
+	if !h.checkVersion(c) {
+		return
+	}
+
+	if c.ContentType() != "application/offset+octet-stream" {
+		h.fail(c, http.StatusUnsupportedMediaType, "Content-Type must be application/offset+octet-stream")
+		return
+	}
+
+	offset, err := strconv.ParseInt(c.GetHeader("Upload-Offset"), 10, 64)
+	if err != nil || offset < 0 {
+		h.fail(c, http.StatusBadRequest, "Upload-Offset header must be a non-negative integer")
+		return
+	}
+
+	id := c.Param("upload_id")
+
+	unlock, err := h.store.Lock(id)
+	if err != nil {
+		h.storeError(c, err)
+		return
+	}
+	defer unlock()
+
+	up, err := h.store.Get(id)
+	if err == nil && !canAccess(c, up.TenantID, up.Owner) {
+		err = upload.ErrNotFound
+	}
+	if err != nil {
+		h.storeError(c, err)
+		return
+	}
+
+	if up.ImageID != "" {
+		// Already submitted; the client missed our last response
+		c.Header("Upload-Offset", strconv.FormatInt(up.Length, 10))
+		c.Header(headerImageID, up.ImageID)
+		c.Status(http.StatusNoContent)
+		return
+	}
+
+	up, err = h.store.WriteChunk(id, offset, c.Request.Body)
+	if err != nil {
+		h.storeError(c, err)
+		return
+	}
+
+	c.Header("Upload-Offset", strconv.FormatInt(up.Offset, 10))
+	c.Header("Upload-Expires", up.ExpiresAt.Format(http.TimeFormat))
+
+	if !up.Complete() {
+		c.Status(http.StatusNoContent)
+		return
+	}
+
+	h.submit(c, up)
+}
+
+// Delete terminates an upload and discards its data
+func (h *UploadHandler) Delete(c *gin.Context) {
+	if !h.checkVersion(c) {
+		return
+	}
+
+	id := c.Param("upload_id")
+
+	unlock, err := h.store.Lock(id)
+	if err != nil {
+		h.storeError(c, err)
+		return
+	}
+	defer unlock()
+
+	up, err := h.store.Get(id)
+	if err == nil && !canAccess(c, up.TenantID, up.Owner) {
+		err = upload.ErrNotFound
+	}
+	if err != nil {
+		h.storeError(c, err)
+		return
+	}
+
+	if err := h.store.Delete(id); err != nil {
+		h.storeError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+func (h *UploadHandler) submit(c *gin.Context, up *upload.Upload) {
+	content, err := h.store.ReadAll(up.ID)
+	if err != nil {
+		h.storeError(c, err)
+		return
+	}
+
+	imageID, subErr := submitImage(c.Request.Context(), h.faceService, imageSubmission{
+		FileName:    up.Metadata["filename"],
+		MimeType:    up.Metadata["filetype"],
+		Content:     content,
+		Name:        up.Metadata["name"],
+		UserID:      up.Metadata["user_id"],
+		Metadata:    up.Metadata["metadata"],
+		CallbackURL: up.Metadata["callback_url"],
+	})
+	if subErr != nil {
+		if subErr.Status < http.StatusInternalServerError {
+			// The content will never become valid, so free it right away
+			if err := h.store.Delete(up.ID); err != nil {
+				logger.FromContext(c.Request.Context()).Warnf("Failed to delete rejected upload %s: %v", up.ID, err)
+			}
+		}
+		c.JSON(subErr.Status, subErr.Response())
+		return
+	}
+
+	if err := h.store.Finish(up, imageID); err != nil {
+		logger.FromContext(c.Request.Context()).Errorf("Failed to record image for upload %s: %v", up.ID, err)
+	}
+
+	c.Header(headerImageID, imageID)
+	c.Status(http.StatusNoContent)
+}
+
+func (h *UploadHandler) checkVersion(c *gin.Context) bool {
+	c.Header("Tus-Resumable", tusVersion)
+
+	if c.GetHeader("Tus-Resumable") != tusVersion {
+		c.Header("Tus-Version", tusVersion)
+		h.fail(c, http.StatusPreconditionFailed, "Unsupported tus version")
+		return false
+	}
+	return true
+}
+
+func (h *UploadHandler) storeError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, upload.ErrNotFound):
+		h.fail(c, http.StatusNotFound, "Upload not found")
+	case errors.Is(err, upload.ErrOffsetMismatch):
+		h.fail(c, http.StatusConflict, "Upload-Offset does not match the current offset")
+	case errors.Is(err, upload.ErrLocked):
+		h.fail(c, http.StatusConflict, "Upload is being written by another request")
+	case errors.Is(err, upload.ErrTooLarge):
+		h.fail(c, http.StatusRequestEntityTooLarge, "Chunk exceeds Upload-Length")
+	default:
+		logger.FromContext(c.Request.Context()).Errorf("Upload storage error: %v", err)
+		h.fail(c, http.StatusInternalServerError, "Failed to store upload")
+	}
+}
+
+func (h *UploadHandler) fail(c *gin.Context, status int, message string) {
+	if c.Request.Method == http.MethodHead {
+		c.Status(status)
+		return
+	}
+
+	c.JSON(status, gin.H{
+		"success": false,
+		"error":   message,
+	})
+}
+
+// parseUploadMetadata decodes "key base64value,key base64value" pairs
+func parseUploadMetadata(header string) (map[string]string, error) {
+	metadata := make(map[string]string)
+	if header == "" {
+		return metadata, nil
+	}
+
+	for _, pair := range strings.Split(header, ",") {
+		key, encoded, _ := strings.Cut(strings.TrimSpace(pair), " ")
+		if key == "" {
+			return nil, errors.New("Upload-Metadata contains an empty key")
+		}
+
+		value, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, errors.New("Upload-Metadata values must be base64 encoded")
+		}
+		metadata[key] = string(value)
+	}
+
+	return metadata, nil
+}
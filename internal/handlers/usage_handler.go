@@ -0,0 +1,45 @@
+package handlers
+
+import (
+	"ai-image-microservice/api-gateway/internal/middleware"
+	"ai-image-microservice/api-gateway/internal/services"
+	"ai-image-microservice/api-gateway/pkg/logger"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type UsageHandler struct {
+	quotaService *services.QuotaService
+}
+
+func NewUsageHandler(quotaService *services.QuotaService) *UsageHandler {
+	return &UsageHandler{
+		quotaService: quotaService,
+	}
+}
+
+// GetUsage reports the caller's tenant consumption against its quotas
+func (h *UsageHandler) GetUsage(c *gin.Context) {
+	var tenantID string
+	if identity, ok := middleware.GetIdentity(c); ok {
+		tenantID = identity.TenantID
+	}
+
+	report, err := h.quotaService.Report(c.Request.Context(), tenantID)
+	if err != nil {
+		logger.FromContext(c.Request.Context()).Errorf("Failed to get usage: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"error":   "Failed to get usage",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success":   true,
+		"tenant_id": report.TenantID,
+		"enforced":  report.Enforced,
+		"periods":   report.Periods,
+	})
+}
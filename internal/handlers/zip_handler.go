@@ -0,0 +1,164 @@
+package handlers
+
+import (
+	"ai-image-microservice/api-gateway/internal/config"
+	"ai-image-microservice/api-gateway/internal/models"
+	"ai-image-microservice/api-gateway/pkg/logger"
+	"archive/zip"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+var zipImageExtensions = map[string]bool{
+	".jpg":  true,
+	".jpeg": true,
+	".png":  true,
+}
+
+// SubmitZip accepts a ZIP archive in the "archive" field and queues every
+// image it contains under a shared batch ID. Other entries are skipped.
+func (h *BatchHandler) SubmitZip(c *gin.Context) {
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, config.AppConfig.Batch.MaxRequestSize)
+
+	header, err := c.FormFile("archive")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.BatchResponse{
+			Success: false,
+			Message: "Invalid request",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	archive, err := header.Open()
+	if err != nil {
+		logger.FromContext(c.Request.Context()).Errorf("Failed to open uploaded archive: %v", err)
+		c.JSON(http.StatusInternalServerError, models.BatchResponse{
+			Success: false,
+			Message: "Failed to process archive",
+			Error:   "Could not open uploaded file",
+		})
+		return
+	}
+	defer archive.Close()
+
+	reader, err := zip.NewReader(archive, header.Size)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.BatchResponse{
+			Success: false,
+			Message: "Invalid archive",
+			Error:   "The uploaded file is not a valid ZIP archive",
+		})
+		return
+	}
+
+	entries, skipped, err := zipBatchEntries(c, reader)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.BatchResponse{
+			Success: false,
+			Message: "Archive rejected",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	h.submitBatch(c, c.PostForm("user_id"), entries, skipped)
+}
+
+// zipBatchEntries lists the image entries of the archive after checking the
+// archive-wide limits. Entries are decompressed one at a time when loaded.
+func zipBatchEntries(c *gin.Context, reader *zip.Reader) ([]batchEntry, int, error) {
+	cfg := config.AppConfig.Batch
+
+	// Every entry may be an image, so the archive cannot have more entries
+	// than a batch has items either
+	maxEntries := min(cfg.ZipMaxEntries, cfg.MaxItems)
+	if len(reader.File) > maxEntries {
+		return nil, 0, fmt.Errorf("archive has %d entries, the limit is %d", len(reader.File), maxEntries)
+	}
+
+	name := c.PostForm("name")
+	userID := c.PostForm("user_id")
+	metadata := c.PostForm("metadata")
+	callbackURL := c.PostForm("callback_url")
+
+	var (
+		entries []batchEntry
+		skipped int
+		total   uint64
+	)
+
+	for _, file := range reader.File {
+		if !isZipImage(file) {
+			skipped++
+			continue
+		}
+
+		// archive/zip fails reads that go past the declared size, so the
+		// declared sizes bound what decompression can produce
+		total += file.UncompressedSize64
+		if total > uint64(cfg.ZipMaxUncompressedSize) {
+			return nil, 0, fmt.Errorf("archive expands beyond the %d byte limit", cfg.ZipMaxUncompressedSize)
+		}
+
+		file := file
+		entries = append(entries, batchEntry{
+			fileName: file.Name,
+			load: func() (imageSubmission, *submissionError) {
+				if subErr := checkImageSize(int64(file.UncompressedSize64)); subErr != nil {
+					return imageSubmission{}, subErr
+				}
+
+				content, err := readZipFile(file)
+				if err != nil {
+					return imageSubmission{}, &submissionError{
+						Status:  http.StatusBadRequest,
+						Message: "Invalid archive entry",
+						Err:     fmt.Sprintf("Could not extract %s: %v", file.Name, err),
+					}
+				}
+
+				// The MIME type is sniffed from the content during validation
+				return imageSubmission{
+					FileName:    path.Base(file.Name),
+					Content:     content,
+					Name:        name,
+					UserID:      userID,
+					Metadata:    metadata,
+					CallbackURL: callbackURL,
+				}, nil
+			},
+		})
+	}
+
+	return entries, skipped, nil
+}
+
+func isZipImage(file *zip.File) bool {
+	if file.FileInfo().IsDir() {
+		return false
+	}
+
+	base := path.Base(file.Name)
+	// Resource forks and metadata added by macOS archivers
+	if strings.HasPrefix(file.Name, "__MACOSX/") || strings.HasPrefix(base, "._") {
+		return false
+	}
+
+	return zipImageExtensions[strings.ToLower(path.Ext(base))]
+}
+
+func readZipFile(file *zip.File) ([]byte, error) {
+	rc, err := file.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+
+	return io.ReadAll(io.LimitReader(rc, config.AppConfig.API.MaxUploadSize+1))
+}
@@ -0,0 +1,171 @@
+package handlers
+
+import (
+	"ai-image-microservice/api-gateway/internal/config"
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// zipArchive builds an in-memory archive of the given entries
+func zipArchive(t *testing.T, files map[string][]byte) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	for name, content := range files {
+		f, err := w.Create(name)
+		if err != nil {
+			t.Fatalf("Create %s: %v", name, err)
+		}
+		if _, err := f.Write(content); err != nil {
+			t.Fatalf("Write %s: %v", name, err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func zipRequest(t *testing.T, archive []byte) *http.Request {
+	t.Helper()
+
+	var body bytes.Buffer
+	w := multipart.NewWriter(&body)
+	part, err := w.CreateFormFile("archive", "images.zip")
+	if err != nil {
+		t.Fatalf("CreateFormFile: %v", err)
+	}
+	part.Write(archive)
+	w.Close()
+
+	req := httptest.NewRequest(http.MethodPost, "/batch/zip", &body)
+	req.Header.Set("Content-Type", w.FormDataContentType())
+	return req
+}
+
+func setZipConfig(maxItems, maxEntries int, maxUncompressed int64) {
+	config.AppConfig = &config.Config{
+		API: config.APIConfig{MaxUploadSize: 1 << 20},
+		Batch: config.BatchConfig{
+			MaxItems:               maxItems,
+			MaxRequestSize:         1 << 20,
+			ZipMaxEntries:          maxEntries,
+			ZipMaxUncompressedSize: maxUncompressed,
+		},
+	}
+}
+
+// postZip submits the archive to a handler without services, so only
+// archives rejected before submission can be posted
+func postZip(t *testing.T, archive []byte) (*httptest.ResponseRecorder, string) {
+	t.Helper()
+
+	router := authenticatedRouter(nil)
+	router.POST("/batch/zip", (&BatchHandler{}).SubmitZip)
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, zipRequest(t, archive))
+
+	var resp struct {
+		Error string `json:"error"`
+	}
+	json.Unmarshal(rec.Body.Bytes(), &resp)
+	return rec, resp.Error
+}
+
+func TestSubmitZipLimitsEntries(t *testing.T) {
+	files := map[string][]byte{}
+	for _, name := range []string{"a.jpg", "b.jpg", "c.jpg", "readme.txt"} {
+		files[name] = []byte("x")
+	}
+	archive := zipArchive(t, files)
+
+	tests := []struct {
+		name       string
+		maxItems   int
+		maxEntries int
+		wantLimit  string
+	}{
+		{name: "entry limit", maxItems: 10, maxEntries: 3, wantLimit: "limit is 3"},
+		// A batch limit below the entry limit applies to archives as well
+		{name: "batch limit", maxItems: 2, maxEntries: 10, wantLimit: "limit is 2"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setZipConfig(tt.maxItems, tt.maxEntries, 1<<20)
+
+			rec, errMsg := postZip(t, archive)
+			if rec.Code != http.StatusBadRequest {
+				t.Fatalf("status = %d, want %d", rec.Code, http.StatusBadRequest)
+			}
+			if !strings.Contains(errMsg, "archive has 4 entries") || !strings.Contains(errMsg, tt.wantLimit) {
+				t.Fatalf("error = %q, want the entry count and %q", errMsg, tt.wantLimit)
+			}
+		})
+	}
+}
+
+func TestSubmitZipLimitsUncompressedSize(t *testing.T) {
+	setZipConfig(10, 10, 1024)
+
+	// Zeros compress to a fraction of their size, like a zip bomb
+	archive := zipArchive(t, map[string][]byte{
+		"a.png": make([]byte, 600),
+		"b.png": make([]byte, 600),
+	})
+	if len(archive) >= 1024 {
+		t.Fatalf("archive is %d bytes, want it below the uncompressed limit", len(archive))
+	}
+
+	rec, errMsg := postZip(t, archive)
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusBadRequest)
+	}
+	if !strings.Contains(errMsg, "1024 byte limit") {
+		t.Fatalf("error = %q, want the uncompressed size limit", errMsg)
+	}
+}
+
+func TestZipBatchEntriesSkipsNonImages(t *testing.T) {
+	setZipConfig(10, 10, 1<<20)
+
+	archive := zipArchive(t, map[string][]byte{
+		"photos/a.jpg":            []byte("x"),
+		"photos/B.JPEG":           []byte("x"),
+		"c.png":                   []byte("x"),
+		"notes.txt":               []byte("x"),
+		"photos/":                 nil,
+		"__MACOSX/photos/._a.jpg": []byte("x"),
+		"photos/._b.png":          []byte("x"),
+	})
+	reader, err := zip.NewReader(bytes.NewReader(archive), int64(len(archive)))
+	if err != nil {
+		t.Fatalf("NewReader: %v", err)
+	}
+
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodPost, "/", nil)
+
+	entries, skipped, err := zipBatchEntries(c, reader)
+	if err != nil {
+		t.Fatalf("zipBatchEntries: %v", err)
+	}
+
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.fileName)
+	}
+	if len(entries) != 3 || skipped != 4 {
+		t.Fatalf("entries %v skipped %d, want 3 images and 4 skipped entries", names, skipped)
+	}
+}
@@ -0,0 +1,119 @@
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "api_gateway"
+
+var (
+	httpRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_requests_total",
+		Help:      "HTTP requests by method, route and status code.",
+	}, []string{"method", "route", "status"})
+
+	httpDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "HTTP request latency by method, route and status code.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route", "status"})
+
+	publishAttempts = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "rabbitmq_publish_attempts_total",
+		Help:      "Event publish attempts by topic.",
+	}, []string{"topic"})
+
+	publishFailures = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "rabbitmq_publish_failures_total",
+		Help:      "Event publish attempts that were not confirmed by the broker, by topic.",
+	}, []string{"topic"})
+
+	publishDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "rabbitmq_publish_duration_seconds",
+		Help:      "Time from publishing an event to its broker confirm, by topic.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"topic"})
+
+	consumerDeliveries = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "rabbitmq_consumer_deliveries_total",
+		Help:      "Messages delivered to the consumer by event type.",
+	}, []string{"event_type"})
+
+	consumerAcks = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "rabbitmq_consumer_acks_total",
+		Help:      "Messages acknowledged by the consumer by event type and result (processed or retried).",
+	}, []string{"event_type", "result"})
+
+	consumerNacks = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "rabbitmq_consumer_nacks_total",
+		Help:      "Messages rejected by the consumer by event type and whether they were requeued.",
+	}, []string{"event_type", "requeue"})
+
+	reconnects = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "rabbitmq_reconnects_total",
+		Help:      "Successful reconnects to RabbitMQ.",
+	})
+
+	uploadSize = promauto.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "upload_size_bytes",
+		Help:      "Size of submitted images.",
+		// 16KB to 256MB
+		Buckets: prometheus.ExponentialBuckets(16*1024, 4, 8),
+	})
+)
+
+// Handler serves the metrics in the Prometheus exposition format
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
+
+func ObserveHTTPRequest(method, route, status string, duration time.Duration) {
+	httpRequests.WithLabelValues(method, route, status).Inc()
+	httpDuration.WithLabelValues(method, route, status).Observe(duration.Seconds())
+}
+
+// ObservePublish records one publish attempt and its outcome
+func ObservePublish(topic string, duration time.Duration, err error) {
+	publishAttempts.WithLabelValues(topic).Inc()
+	publishDuration.WithLabelValues(topic).Observe(duration.Seconds())
+	if err != nil {
+		publishFailures.WithLabelValues(topic).Inc()
+	}
+}
+
+func IncDelivery(eventType string) {
+	consumerDeliveries.WithLabelValues(eventType).Inc()
+}
+
+// IncAck counts an acknowledged message; result is "processed" or "retried"
+func IncAck(eventType, result string) {
+	consumerAcks.WithLabelValues(eventType, result).Inc()
+}
+
+func IncNack(eventType string, requeue bool) {
+	consumerNacks.WithLabelValues(eventType, strconv.FormatBool(requeue)).Inc()
+}
+
+func IncReconnect() {
+	reconnects.Inc()
+}
+
+func ObserveUploadSize(bytes int) {
+	uploadSize.Observe(float64(bytes))
+}
@@ -0,0 +1,131 @@
+package middleware
+
+import (
+	"ai-image-microservice/api-gateway/internal/auth"
+	"ai-image-microservice/api-gateway/internal/models"
+	"ai-image-microservice/api-gateway/pkg/logger"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+)
+
+const identityKey = "identity"
+
+// Auth rejects requests that none of the authenticators accept, and stores
+// the caller identity in the gin and request contexts otherwise
+func Auth(authenticators ...auth.Authenticator) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// CORS preflight requests never carry credentials
+		if c.Request.Method == http.MethodOptions {
+			c.Next()
+			return
+		}
+
+		var identity *models.Identity
+		var authErr error = auth.ErrNoCredentials
+		for _, a := range authenticators {
+			id, err := a.Authenticate(c.Request)
+			if errors.Is(err, auth.ErrNoCredentials) {
+				continue
+			}
+			identity, authErr = id, err
+			break
+		}
+
+		if authErr != nil && !errors.Is(authErr, auth.ErrNoCredentials) && !errors.Is(authErr, auth.ErrInvalidCredentials) {
+			logger.FromContext(c.Request.Context()).Errorf("Authentication failed: %v", authErr)
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+				"success": false,
+				"error":   "Failed to authenticate request",
+			})
+			return
+		}
+
+		if authErr != nil {
+			message := "Authentication required"
+			if !errors.Is(authErr, auth.ErrNoCredentials) {
+				message = "Invalid credentials"
+			}
+			for _, a := range authenticators {
+				if challenger, ok := a.(auth.Challenger); ok {
+					c.Writer.Header().Add("WWW-Authenticate", challenger.Challenge())
+				}
+			}
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
+				"success": false,
+				"error":   message,
+			})
+			return
+		}
+
+		c.Set(identityKey, identity)
+		c.Request = c.Request.WithContext(auth.WithIdentity(c.Request.Context(), identity))
+		c.Next()
+	}
+}
+
+// GetIdentity returns the identity set by Auth, if the route is authenticated
+func GetIdentity(c *gin.Context) (*models.Identity, bool) {
+	value, ok := c.Get(identityKey)
+	if !ok {
+		return nil, false
+	}
+	identity, ok := value.(*models.Identity)
+	return identity, ok
+}
+
+// WebSocket subprotocols carrying credentials. Browsers cannot set headers on
+// a WebSocket handshake, so clients offer one of them followed by the
+// credential, e.g. new WebSocket(url, ["bearer", token]).
+const (
+	WebSocketProtocolBearer = "bearer"
+	WebSocketProtocolAPIKey = "api-key"
+)
+
+// WebSocketCredentials moves credentials offered as WebSocket subprotocols
+// to the headers the authenticators read. It must run before Auth. The
+// credential is removed from the offered subprotocols so that it is never
+// echoed back; only the marker protocol is.
+func WebSocketCredentials() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !websocket.IsWebSocketUpgrade(c.Request) {
+			c.Next()
+			return
+		}
+
+		protocols := websocket.Subprotocols(c.Request)
+		offered := make([]string, 0, len(protocols))
+		for i := 0; i < len(protocols); i++ {
+			header := ""
+			switch protocols[i] {
+			case WebSocketProtocolBearer:
+				header = "Authorization"
+			case WebSocketProtocolAPIKey:
+				header = auth.HeaderAPIKey
+			}
+			offered = append(offered, protocols[i])
+
+			if header == "" || i+1 == len(protocols) {
+				continue
+			}
+			i++
+			if c.Request.Header.Get(header) != "" {
+				continue
+			}
+			if header == "Authorization" {
+				c.Request.Header.Set(header, "Bearer "+protocols[i])
+			} else {
+				c.Request.Header.Set(header, protocols[i])
+			}
+		}
+
+		c.Request.Header.Del("Sec-WebSocket-Protocol")
+		if len(offered) > 0 {
+			c.Request.Header.Set("Sec-WebSocket-Protocol", strings.Join(offered, ", "))
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,104 @@
+package middleware
+
+import (
+	"ai-image-microservice/api-gateway/internal/auth"
+	"ai-image-microservice/api-gateway/internal/models"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// headerAuthenticator accepts the token "good" in the X-Token header
+type headerAuthenticator struct{}
+
+func (headerAuthenticator) Challenge() string { return "Token" }
+
+func (headerAuthenticator) Authenticate(r *http.Request) (*models.Identity, error) {
+	switch r.Header.Get("X-Token") {
+	case "":
+		return nil, auth.ErrNoCredentials
+	case "good":
+		return &models.Identity{UserID: "alice"}, nil
+	case "broken":
+		return nil, errors.New("key store unavailable")
+	default:
+		return nil, auth.ErrInvalidCredentials
+	}
+}
+
+func TestAuth(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	router := gin.New()
+	router.Use(Auth(headerAuthenticator{}))
+	router.GET("/", func(c *gin.Context) {
+		identity, _ := GetIdentity(c)
+		fromContext, _ := auth.IdentityFromContext(c.Request.Context())
+		if fromContext != identity {
+			t.Error("identity missing from the request context")
+		}
+		c.String(http.StatusOK, identity.UserID)
+	})
+
+	tests := []struct {
+		token      string
+		wantStatus int
+	}{
+		{token: "good", wantStatus: http.StatusOK},
+		{token: "", wantStatus: http.StatusUnauthorized},
+		{token: "bad", wantStatus: http.StatusUnauthorized},
+		{token: "broken", wantStatus: http.StatusInternalServerError},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		if tt.token != "" {
+			req.Header.Set("X-Token", tt.token)
+		}
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+
+		if rec.Code != tt.wantStatus {
+			t.Errorf("token %q: status = %d, want %d", tt.token, rec.Code, tt.wantStatus)
+		}
+		if rec.Code == http.StatusUnauthorized && rec.Header().Get("WWW-Authenticate") != "Token" {
+			t.Errorf("token %q: missing WWW-Authenticate challenge", tt.token)
+		}
+	}
+
+	// Preflight requests pass without credentials
+	req := httptest.NewRequest(http.MethodOptions, "/", nil)
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	if rec.Code == http.StatusUnauthorized {
+		t.Fatal("preflight request was rejected")
+	}
+}
+
+func TestWebSocketCredentials(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	var authorization, protocols string
+	router := gin.New()
+	router.Use(WebSocketCredentials())
+	router.GET("/", func(c *gin.Context) {
+		authorization = c.GetHeader("Authorization")
+		protocols = c.GetHeader("Sec-WebSocket-Protocol")
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", "websocket")
+	req.Header.Set("Sec-WebSocket-Protocol", "bearer, token.value, json")
+	router.ServeHTTP(httptest.NewRecorder(), req)
+
+	if authorization != "Bearer token.value" {
+		t.Fatalf("Authorization = %q, want the offered token", authorization)
+	}
+	if protocols != "bearer, json" {
+		t.Fatalf("Sec-WebSocket-Protocol = %q, want the token removed", protocols)
+	}
+}
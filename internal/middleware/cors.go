@@ -1,19 +1,63 @@
 package middleware
 
 import (
+	"ai-image-microservice/api-gateway/internal/config"
+	"net/http"
+	"net/url"
+	"strings"
+
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
 )
 
 func CORS() gin.HandlerFunc {
-	config := cors.Config{
-		AllowOrigins:     []string{"*"},
-		AllowMethods:     []string{"GET", "POST", "PUT", "DELETE", "OPTIONS", "PATCH"},
-		AllowHeaders:     []string{"Origin", "Content-Type", "Accept", "Authorization"},
-		ExposeHeaders:    []string{"Content-Length"},
+	corsConfig := cors.Config{
+		AllowOrigins: config.AppConfig.API.AllowedOrigins,
+		AllowMethods: []string{"GET", "POST", "PUT", "DELETE", "OPTIONS", "PATCH", "HEAD"},
+		AllowHeaders: []string{
+			"Origin", "Content-Type", "Accept", "Authorization", "X-API-Key", "Idempotency-Key", "X-Request-ID",
+			// tus resumable uploads
+			"Tus-Resumable", "Upload-Length", "Upload-Offset", "Upload-Metadata",
+		},
+		ExposeHeaders: []string{
+			"Content-Length",
+			// tus resumable uploads
+			"Location", "Tus-Resumable", "Tus-Version", "Tus-Extension", "Tus-Max-Size",
+			"Upload-Offset", "Upload-Length", "Upload-Expires", "X-Image-ID",
+			// rate limiting
+			"Retry-After", "X-RateLimit-Limit", "X-RateLimit-Remaining", "X-RateLimit-Reset",
+			"Idempotent-Replayed", "X-Request-ID",
+		},
 		AllowCredentials: true,
 		MaxAge:           86400,
 	}
 
-	return cors.New(config)
+	return cors.New(corsConfig)
+}
+
+// CheckWebSocketOrigin reports whether a WebSocket upgrade may proceed. CORS
+// does not apply to upgrades, so browsers would let any site open one:
+// requests from another origin must come from an explicitly allowed one,
+// the "*" wildcard does not count. Requests without an Origin header come
+// from non-browser clients and are allowed.
+func CheckWebSocketOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+
+	u, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+	if strings.EqualFold(u.Host, r.Host) {
+		return true
+	}
+
+	for _, allowed := range config.AppConfig.API.AllowedOrigins {
+		if allowed != "*" && strings.EqualFold(strings.TrimSuffix(allowed, "/"), origin) {
+			return true
+		}
+	}
+	return false
 }
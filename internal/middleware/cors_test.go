@@ -0,0 +1,39 @@
+package middleware
+
+import (
+	"ai-image-microservice/api-gateway/internal/config"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCheckWebSocketOrigin(t *testing.T) {
+	tests := []struct {
+		name    string
+		allowed []string
+		origin  string
+		want    bool
+	}{
+		{name: "no origin", allowed: []string{"*"}, want: true},
+		{name: "same origin", allowed: nil, origin: "https://gateway.example.com", want: true},
+		{name: "listed origin", allowed: []string{"https://app.example.com"}, origin: "https://app.example.com", want: true},
+		{name: "listed with trailing slash", allowed: []string{"https://app.example.com/"}, origin: "https://app.example.com", want: true},
+		{name: "unlisted origin", allowed: []string{"https://app.example.com"}, origin: "https://evil.example.net", want: false},
+		{name: "wildcard does not apply", allowed: []string{"*"}, origin: "https://evil.example.net", want: false},
+		{name: "malformed origin", allowed: []string{"*"}, origin: "://", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config.AppConfig = &config.Config{API: config.APIConfig{AllowedOrigins: tt.allowed}}
+
+			r := httptest.NewRequest("GET", "https://gateway.example.com/api/v1/face/ws", nil)
+			if tt.origin != "" {
+				r.Header.Set("Origin", tt.origin)
+			}
+
+			if got := CheckWebSocketOrigin(r); got != tt.want {
+				t.Fatalf("CheckWebSocketOrigin(%q) = %v, want %v", tt.origin, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,287 @@
+package middleware
+
+import (
+	"ai-image-microservice/api-gateway/internal/config"
+	"ai-image-microservice/api-gateway/internal/models"
+	"ai-image-microservice/api-gateway/internal/store"
+	"ai-image-microservice/api-gateway/pkg/logger"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	HeaderIdempotencyKey     = "Idempotency-Key"
+	HeaderIdempotentReplayed = "Idempotent-Replayed"
+	maxIdempotencyKeyLength  = 255
+	defaultMultipartMemory   = 32 << 20
+)
+
+type idempotencyKeyContextKey struct{}
+
+// WithIdempotencyKey returns a copy of ctx carrying a scoped idempotency key
+func WithIdempotencyKey(ctx context.Context, key string) context.Context {
+	return context.WithValue(ctx, idempotencyKeyContextKey{}, key)
+}
+
+// IdempotencyKeyFromContext returns the scoped idempotency key of the
+// request, if it was made with one. Handlers derive the IDs of what they
+// create from it, so a retry that gets past the replay (after a server error
+// or an expired reservation) creates the same records again rather than
+// duplicates.
+func IdempotencyKeyFromContext(ctx context.Context) string {
+	key, _ := ctx.Value(idempotencyKeyContextKey{}).(string)
+	return key
+}
+
+// Idempotency replays the stored response of a request retried with the same
+// Idempotency-Key header within the TTL. Reusing a key for a different
+// request, or while the first one is still running, is rejected with 409.
+// Server errors and 429s are not stored so the request can be retried.
+// maxBodySize is the largest body the route accepts; the body is read to
+// fingerprint the request before the handler runs.
+func Idempotency(s store.IdempotencyStore, ttl time.Duration, maxBodySize int64) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.GetHeader(HeaderIdempotencyKey)
+		if key == "" {
+			c.Next()
+			return
+		}
+
+		if len(key) > maxIdempotencyKeyLength {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+				"success": false,
+				"error":   fmt.Sprintf("Idempotency-Key must be at most %d characters", maxIdempotencyKeyLength),
+			})
+			return
+		}
+
+		fingerprint, err := requestFingerprint(c, maxBodySize)
+		if err != nil {
+			status := http.StatusBadRequest
+			var maxErr *http.MaxBytesError
+			if errors.As(err, &maxErr) {
+				status = http.StatusRequestEntityTooLarge
+			}
+			c.AbortWithStatusJSON(status, gin.H{
+				"success": false,
+				"error":   "Failed to read request: " + err.Error(),
+			})
+			return
+		}
+		// The copy of the body, or the files of a multipart form, are kept
+		// until the request is done
+		defer func(r *http.Request, body io.Closer) {
+			body.Close()
+			if r.MultipartForm != nil {
+				r.MultipartForm.RemoveAll()
+			}
+		}(c.Request, c.Request.Body)
+
+		now := time.Now().UTC()
+		record := &models.IdempotencyRecord{
+			Key:         idempotencyScope(c) + ":" + key,
+			Fingerprint: fingerprint,
+			CreatedAt:   now,
+			// A request in progress holds the key for at most twice the
+			// request timeout, in case the gateway dies before completing it
+			ExpiresAt: now.Add(2 * config.AppConfig.API.RequestTimeout),
+		}
+
+		existing, claimed, err := s.Reserve(c.Request.Context(), record)
+		if err != nil {
+			logger.FromContext(c.Request.Context()).Errorf("Failed to reserve idempotency key: %v", err)
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+				"success": false,
+				"error":   "Failed to process Idempotency-Key",
+			})
+			return
+		}
+
+		if !claimed {
+			switch {
+			case existing.Fingerprint != fingerprint:
+				c.AbortWithStatusJSON(http.StatusConflict, gin.H{
+					"success": false,
+					"error":   "Idempotency-Key was already used with a different request",
+				})
+			case !existing.Completed:
+				c.AbortWithStatusJSON(http.StatusConflict, gin.H{
+					"success": false,
+					"error":   "A request with this Idempotency-Key is still in progress",
+				})
+			default:
+				c.Header(HeaderIdempotentReplayed, "true")
+				c.Data(existing.StatusCode, "application/json; charset=utf-8", existing.Response)
+				c.Abort()
+			}
+			return
+		}
+
+		writer := &capturingWriter{ResponseWriter: c.Writer}
+		c.Writer = writer
+		c.Request = c.Request.WithContext(WithIdempotencyKey(c.Request.Context(), record.Key))
+
+		c.Next()
+
+		ctx := context.Background()
+		status := writer.Status()
+		if status >= http.StatusInternalServerError || status == http.StatusTooManyRequests {
+			if err := s.Release(ctx, record.Key); err != nil {
+				logger.FromContext(c.Request.Context()).Errorf("Failed to release idempotency key: %v", err)
+			}
+			return
+		}
+
+		if err := s.Complete(ctx, record.Key, status, writer.body.Bytes(), time.Now().UTC().Add(ttl)); err != nil {
+			logger.FromContext(c.Request.Context()).Errorf("Failed to store idempotent response: %v", err)
+		}
+	}
+}
+
+// idempotencyScope keeps the keys of different callers apart: by user of a
+// tenant when authenticated, otherwise by client IP
+func idempotencyScope(c *gin.Context) string {
+	if identity, ok := GetIdentity(c); ok {
+		return "user:" + identity.TenantID + ":" + identity.UserID
+	}
+	return "ip:" + c.ClientIP()
+}
+
+// capturingWriter keeps a copy of the response body
+type capturingWriter struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *capturingWriter) Write(data []byte) (int, error) {
+	w.body.Write(data)
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *capturingWriter) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
+
+// requestFingerprint hashes the content of a request of at most maxBodySize
+// bytes. Multipart forms are hashed field by field so that a retry with a new
+// boundary still matches. The body remains readable by the handler.
+func requestFingerprint(c *gin.Context, maxBodySize int64) (string, error) {
+	h := sha256.New()
+	fmt.Fprintf(h, "%s %s\n", c.Request.Method, c.Request.URL.Path)
+
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxBodySize)
+
+	if strings.HasPrefix(c.ContentType(), "multipart/form-data") {
+		if err := c.Request.ParseMultipartForm(defaultMultipartMemory); err != nil {
+			return "", err
+		}
+		if err := hashMultipartForm(h, c.Request.MultipartForm); err != nil {
+			return "", err
+		}
+		return hex.EncodeToString(h.Sum(nil)), nil
+	}
+
+	body, err := spoolBody(c.Request.Body, h, defaultMultipartMemory)
+	if err != nil {
+		return "", err
+	}
+	c.Request.Body = body
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// spoolBody hashes the body into h while keeping a copy for the handler: in
+// memory up to memLimit bytes, in a temporary file past that, like the
+// files of multipart forms. Closing the returned body removes the file.
+func spoolBody(body io.Reader, h hash.Hash, memLimit int64) (io.ReadCloser, error) {
+	var buf bytes.Buffer
+	n, err := io.Copy(io.MultiWriter(h, &buf), io.LimitReader(body, memLimit+1))
+	if err != nil {
+		return nil, err
+	}
+	if n <= memLimit {
+		return io.NopCloser(&buf), nil
+	}
+
+	file, err := os.CreateTemp("", "idempotent-body-*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to spool request body: %w", err)
+	}
+	spooled := &spooledBody{File: file}
+
+	if _, err := buf.WriteTo(file); err != nil {
+		spooled.Close()
+		return nil, fmt.Errorf("failed to spool request body: %w", err)
+	}
+	if _, err := io.Copy(io.MultiWriter(h, file), body); err != nil {
+		spooled.Close()
+		return nil, err
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		spooled.Close()
+		return nil, fmt.Errorf("failed to spool request body: %w", err)
+	}
+	return spooled, nil
+}
+
+// spooledBody is a request body kept in a temporary file
+type spooledBody struct {
+	*os.File
+}
+
+func (b *spooledBody) Close() error {
+	err := b.File.Close()
+	os.Remove(b.File.Name())
+	return err
+}
+
+func hashMultipartForm(h hash.Hash, form *multipart.Form) error {
+	names := make([]string, 0, len(form.Value))
+	for name := range form.Value {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		for _, value := range form.Value[name] {
+			fmt.Fprintf(h, "value %q=%q\n", name, value)
+		}
+	}
+
+	names = names[:0]
+	for name := range form.File {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		for _, header := range form.File[name] {
+			fmt.Fprintf(h, "file %q=%q %d\n", name, header.Filename, header.Size)
+
+			file, err := header.Open()
+			if err != nil {
+				return err
+			}
+			_, err = io.Copy(h, file)
+			file.Close()
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,172 @@
+package middleware
+
+import (
+	"ai-image-microservice/api-gateway/internal/config"
+	"ai-image-microservice/api-gateway/internal/models"
+	"ai-image-microservice/api-gateway/internal/store"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const testMaxBodySize = 1 << 10
+
+// newIdempotentRouter serves a handler that counts its calls. A user_id
+// header stands in for authentication.
+func newIdempotentRouter(t *testing.T) (*gin.Engine, *int) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	config.AppConfig = &config.Config{
+		API: config.APIConfig{RequestTimeout: time.Second},
+	}
+
+	calls := 0
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		if userID := c.GetHeader("X-Test-User"); userID != "" {
+			c.Set(identityKey, &models.Identity{UserID: userID})
+		}
+	})
+	router.Use(Idempotency(store.NewMemoryIdempotencyStore(), time.Hour, testMaxBodySize))
+	router.POST("/", func(c *gin.Context) {
+		calls++
+		body, _ := io.ReadAll(c.Request.Body)
+		c.JSON(http.StatusCreated, gin.H{"call": calls, "key": IdempotencyKeyFromContext(c.Request.Context()), "body": string(body)})
+	})
+	return router, &calls
+}
+
+func idempotentRequest(router *gin.Engine, remoteAddr, userID, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+	req.RemoteAddr = remoteAddr
+	req.Header.Set(HeaderIdempotencyKey, "key-1")
+	if userID != "" {
+		req.Header.Set("X-Test-User", userID)
+	}
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestIdempotencyReplaysForSameCaller(t *testing.T) {
+	router, calls := newIdempotentRouter(t)
+
+	first := idempotentRequest(router, "192.0.2.1:1000", "", "{}")
+	second := idempotentRequest(router, "192.0.2.1:2000", "", "{}")
+
+	if *calls != 1 {
+		t.Fatalf("handler called %d times, want 1", *calls)
+	}
+	if second.Header().Get(HeaderIdempotentReplayed) != "true" || second.Body.String() != first.Body.String() {
+		t.Fatalf("retry was not replayed: %d %s", second.Code, second.Body.String())
+	}
+}
+
+func TestIdempotencyScopesAnonymousCallersByIP(t *testing.T) {
+	router, calls := newIdempotentRouter(t)
+
+	idempotentRequest(router, "192.0.2.1:1000", "", "{}")
+	other := idempotentRequest(router, "192.0.2.2:1000", "", "{}")
+
+	if *calls != 2 {
+		t.Fatalf("handler called %d times, want 2", *calls)
+	}
+	if other.Header().Get(HeaderIdempotentReplayed) != "" {
+		t.Fatal("response of another client was replayed")
+	}
+	if !strings.Contains(other.Body.String(), "ip:192.0.2.2:key-1") {
+		t.Fatalf("scoped key not in context: %s", other.Body.String())
+	}
+}
+
+func TestIdempotencyScopesByUser(t *testing.T) {
+	router, calls := newIdempotentRouter(t)
+
+	idempotentRequest(router, "192.0.2.1:1000", "alice", "{}")
+	idempotentRequest(router, "192.0.2.1:1000", "bob", "{}")
+	idempotentRequest(router, "192.0.2.9:1000", "alice", "{}")
+
+	// The same user is recognized from another address
+	if *calls != 2 {
+		t.Fatalf("handler called %d times, want 2", *calls)
+	}
+}
+
+func TestIdempotencyRejectsReuseForDifferentRequest(t *testing.T) {
+	router, _ := newIdempotentRouter(t)
+
+	idempotentRequest(router, "192.0.2.1:1000", "", `{"a":1}`)
+	rec := idempotentRequest(router, "192.0.2.1:1000", "", `{"a":2}`)
+
+	if rec.Code != http.StatusConflict {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusConflict)
+	}
+}
+
+func TestIdempotencyRejectsLongKeys(t *testing.T) {
+	router, _ := newIdempotentRouter(t)
+
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader("{}"))
+	req.Header.Set(HeaderIdempotencyKey, fmt.Sprintf("%0*d", maxIdempotencyKeyLength+1, 0))
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusBadRequest)
+	}
+}
+
+func TestIdempotencyEnforcesRouteBodyLimit(t *testing.T) {
+	router, calls := newIdempotentRouter(t)
+
+	ok := idempotentRequest(router, "192.0.2.1:1000", "", `{"a":1}`)
+	if ok.Code != http.StatusCreated || !strings.Contains(ok.Body.String(), `{\"a\":1}`) {
+		t.Fatalf("response = %d %s, want the body passed to the handler", ok.Code, ok.Body.String())
+	}
+
+	rec := idempotentRequest(router, "192.0.2.2:1000", "", strings.Repeat("x", testMaxBodySize+1))
+	if rec.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusRequestEntityTooLarge)
+	}
+	if *calls != 1 {
+		t.Fatalf("handler called %d times, want 1", *calls)
+	}
+}
+
+func TestSpoolBodyMovesLargeBodiesToFile(t *testing.T) {
+	data := strings.Repeat("0123456789", 10)
+
+	h := sha256.New()
+	body, err := spoolBody(strings.NewReader(data), h, 16)
+	if err != nil {
+		t.Fatalf("spoolBody: %v", err)
+	}
+
+	spooled, ok := body.(*spooledBody)
+	if !ok {
+		t.Fatalf("body is a %T, want a spooled file", body)
+	}
+
+	want := sha256.Sum256([]byte(data))
+	if got := hex.EncodeToString(h.Sum(nil)); got != hex.EncodeToString(want[:]) {
+		t.Fatalf("hash = %s, want the hash of the whole body", got)
+	}
+	if got, _ := io.ReadAll(body); string(got) != data {
+		t.Fatalf("read back %q, want the whole body", got)
+	}
+
+	body.Close()
+	if _, err := os.Stat(spooled.Name()); !os.IsNotExist(err) {
+		t.Fatalf("spool file left behind: %v", err)
+	}
+}
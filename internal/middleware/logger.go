@@ -1,6 +1,7 @@
 package middleware
 
 import (
+	"ai-image-microservice/api-gateway/pkg/logger"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -24,7 +25,7 @@ func Logger() gin.HandlerFunc {
 			path = path + "?" + raw
 		}
 
-		entry := logrus.WithFields(logrus.Fields{
+		entry := logger.FromContext(c.Request.Context()).WithFields(logrus.Fields{
 			"status":     statusCode,
 			"method":     method,
 			"path":       path,
@@ -0,0 +1,41 @@
+package middleware
+
+import (
+	"ai-image-microservice/api-gateway/internal/metrics"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestMetricsLabelsByRouteTemplate(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	router := gin.New()
+	router.Use(Metrics())
+	router.GET("/metrics-test/:id", func(c *gin.Context) { c.Status(http.StatusAccepted) })
+
+	for _, path := range []string{"/metrics-test/a", "/metrics-test/b", "/metrics-test-missing"} {
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+
+	rec := httptest.NewRecorder()
+	metrics.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	body, _ := io.ReadAll(rec.Body)
+	exposition := string(body)
+
+	for _, want := range []string{
+		`api_gateway_http_requests_total{method="GET",route="/metrics-test/:id",status="202"} 2`,
+		`api_gateway_http_requests_total{method="GET",route="unmatched",status="404"} 1`,
+	} {
+		if !strings.Contains(exposition, want) {
+			t.Errorf("metrics do not contain %s", want)
+		}
+	}
+	if strings.Contains(exposition, `route="/metrics-test/a"`) {
+		t.Error("metrics are labelled with the raw path")
+	}
+}
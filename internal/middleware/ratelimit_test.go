@@ -0,0 +1,84 @@
+package middleware
+
+import (
+	"ai-image-microservice/api-gateway/internal/config"
+	"ai-image-microservice/api-gateway/internal/models"
+	"ai-image-microservice/api-gateway/internal/ratelimit"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// recordingLimiter allows every request and records the bucket keys
+type recordingLimiter struct {
+	keys []string
+}
+
+func (l *recordingLimiter) Allow(ctx context.Context, key string, limit ratelimit.Limit) (ratelimit.Result, error) {
+	l.keys = append(l.keys, key)
+	return ratelimit.Result{Allowed: true, Limit: limit.Burst}, nil
+}
+
+func TestRateLimitKeyIgnoresUntrustedForwardedFor(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name    string
+		trusted []string
+		want    string
+	}{
+		{name: "no trusted proxies", trusted: nil, want: "read:ip:10.0.0.5"},
+		{name: "trusted proxy", trusted: []string{"10.0.0.0/8"}, want: "read:ip:203.0.113.7"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			limiter := &recordingLimiter{}
+			router := gin.New()
+			if err := router.SetTrustedProxies(tt.trusted); err != nil {
+				t.Fatalf("SetTrustedProxies: %v", err)
+			}
+			router.Use(RateLimit("read", limiter, config.RateLimitPolicy{PerMinute: 60, Burst: 10, KeyBy: RateLimitByAuto}))
+			router.GET("/", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.RemoteAddr = "10.0.0.5:4321"
+			req.Header.Set("X-Forwarded-For", "203.0.113.7")
+			router.ServeHTTP(httptest.NewRecorder(), req)
+
+			if len(limiter.keys) != 1 || limiter.keys[0] != tt.want {
+				t.Fatalf("keys = %v, want [%s]", limiter.keys, tt.want)
+			}
+		})
+	}
+}
+
+// staticAuthenticator authenticates every request as the same identity
+type staticAuthenticator struct {
+	identity *models.Identity
+}
+
+func (a staticAuthenticator) Authenticate(r *http.Request) (*models.Identity, error) {
+	return a.identity, nil
+}
+
+func TestRateLimitKeyScopesUsersByTenant(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	limiter := &recordingLimiter{}
+	for _, tenantID := range []string{"acme", "globex"} {
+		router := gin.New()
+		router.Use(Auth(staticAuthenticator{identity: &models.Identity{Method: models.AuthMethodJWT, TenantID: tenantID, UserID: "alice"}}))
+		router.Use(RateLimit("read", limiter, config.RateLimitPolicy{PerMinute: 60, Burst: 10, KeyBy: RateLimitByUser}))
+		router.GET("/", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	}
+
+	if len(limiter.keys) != 2 || limiter.keys[0] == limiter.keys[1] {
+		t.Fatalf("keys = %v, want a separate bucket per tenant", limiter.keys)
+	}
+}
@@ -0,0 +1,32 @@
+package middleware
+
+import (
+	"ai-image-microservice/api-gateway/pkg/logger"
+	"regexp"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+const HeaderRequestID = "X-Request-ID"
+
+// validRequestID limits client supplied IDs to what is safe to log and to
+// forward in message headers
+var validRequestID = regexp.MustCompile(`^[A-Za-z0-9._:\-]{1,128}$`)
+
+// RequestID accepts the client's X-Request-ID or generates one, echoes it in
+// the response and stores it in the request context for logging and for the
+// events published while handling the request
+func RequestID() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		requestID := c.GetHeader(HeaderRequestID)
+		if !validRequestID.MatchString(requestID) {
+			requestID = uuid.New().String()
+		}
+
+		c.Header(HeaderRequestID, requestID)
+		c.Request = c.Request.WithContext(logger.WithRequestID(c.Request.Context(), requestID))
+
+		c.Next()
+	}
+}
@@ -0,0 +1,57 @@
+package middleware
+
+import (
+	"ai-image-microservice/api-gateway/pkg/logger"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+func TestRequestID(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	var seen string
+	router := gin.New()
+	router.Use(RequestID())
+	router.GET("/", func(c *gin.Context) {
+		seen = logger.RequestIDFromContext(c.Request.Context())
+	})
+
+	tests := []struct {
+		name     string
+		header   string
+		keepsOwn bool
+	}{
+		{name: "client ID", header: "req-123:abc", keepsOwn: true},
+		{name: "no ID", header: ""},
+		{name: "unsafe characters", header: "bad id\nInjected: yes"},
+		{name: "too long", header: strings.Repeat("a", 129)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.header != "" {
+				req.Header.Set(HeaderRequestID, tt.header)
+			}
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, req)
+
+			echoed := rec.Header().Get(HeaderRequestID)
+			if echoed != seen {
+				t.Fatalf("response %q and context %q differ", echoed, seen)
+			}
+			if tt.keepsOwn {
+				if seen != tt.header {
+					t.Fatalf("request ID = %q, want %q", seen, tt.header)
+				}
+			} else if _, err := uuid.Parse(seen); err != nil {
+				t.Fatalf("request ID = %q, want a generated UUID", seen)
+			}
+		})
+	}
+}
@@ -0,0 +1,29 @@
+package models
+
+import "time"
+
+const (
+	AuthMethodAPIKey = "api_key"
+	AuthMethodJWT    = "jwt"
+)
+
+// Identity is the authenticated caller of a request
+type Identity struct {
+	Method   string `json:"method"`
+	UserID   string `json:"user_id"`
+	TenantID string `json:"tenant_id"`
+	KeyID    string `json:"key_id,omitempty"`
+	// Claims holds the token claims of JWT authenticated callers
+	Claims map[string]interface{} `json:"claims,omitempty"`
+}
+
+// APIKey is a stored API key. Only the SHA-256 hash of the key is kept.
+type APIKey struct {
+	ID        string     `json:"id"`
+	KeyHash   string     `json:"-"`
+	TenantID  string     `json:"tenant_id"`
+	UserID    string     `json:"user_id,omitempty"`
+	Name      string     `json:"name,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+	RevokedAt *time.Time `json:"revoked_at,omitempty"`
+}
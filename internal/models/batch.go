@@ -0,0 +1,54 @@
+package models
+
+import "time"
+
+type BatchItemState string
+
+const (
+	BatchItemAccepted BatchItemState = "accepted"
+	BatchItemRejected BatchItemState = "rejected"
+)
+
+type BatchState string
+
+const (
+	BatchStateProcessing     BatchState = "processing"
+	BatchStateCompleted      BatchState = "completed"
+	BatchStatePartialFailure BatchState = "partial_failure"
+	BatchStateFailed         BatchState = "failed"
+)
+
+type Batch struct {
+	ID        string      `json:"batch_id"`
+	TenantID  string      `json:"tenant_id,omitempty"`
+	UserID    string      `json:"user_id,omitempty"`
+	Items     []BatchItem `json:"items"`
+	CreatedAt time.Time   `json:"created_at"`
+}
+
+// BatchItem is the outcome of submitting one image of a batch
+type BatchItem struct {
+	Index    int            `json:"index"`
+	FileName string         `json:"file_name,omitempty"`
+	ImageID  string         `json:"image_id,omitempty"`
+	State    BatchItemState `json:"state"`
+	Error    string         `json:"error,omitempty"`
+}
+
+// BatchItemStatus is a batch item along with the current state of its job
+type BatchItemStatus struct {
+	BatchItem
+	Status    JobState   `json:"status,omitempty"`
+	UpdatedAt *time.Time `json:"updated_at,omitempty"`
+}
+
+type BatchStatus struct {
+	BatchID   string            `json:"batch_id"`
+	TenantID  string            `json:"tenant_id,omitempty"`
+	UserID    string            `json:"user_id,omitempty"`
+	State     BatchState        `json:"state"`
+	Total     int               `json:"total"`
+	Counts    map[string]int    `json:"counts"`
+	Items     []BatchItemStatus `json:"items"`
+	CreatedAt time.Time         `json:"created_at"`
+}
@@ -3,25 +3,39 @@ package models
 import "time"
 
 type BaseEvent struct {
-	EventID   string      `json:"event_id"`
-	EventType string      `json:"event_type"`
-	Timestamp time.Time   `json:"timestamp"`
-	Data      interface{} `json:"data"`
+	EventID   string `json:"event_id"`
+	EventType string `json:"event_type"`
+	// CorrelationID is the X-Request-ID of the request behind the event
+	CorrelationID string      `json:"correlation_id,omitempty"`
+	Timestamp     time.Time   `json:"timestamp"`
+	Data          interface{} `json:"data"`
 }
 
 type ImageReceivedEventData struct {
 	ImageID   string                 `json:"image_id"`
-	ImageData string                 `json:"image_data"` // base64 encoded
+	ImageData string                 `json:"image_data,omitempty"` // base64 encoded, inline transport
+	ImageRef  string                 `json:"image_ref,omitempty"`  // blob reference, claim-check transport
+	Checksum  string                 `json:"checksum,omitempty"`   // hex SHA-256 of the image bytes
 	FileName  string                 `json:"file_name"`
 	FileSize  int64                  `json:"file_size"`
 	MimeType  string                 `json:"mime_type"`
 	UserID    string                 `json:"user_id,omitempty"`
+	TenantID  string                 `json:"tenant_id,omitempty"`
 	Name      string                 `json:"name,omitempty"`
+	BatchID   string                 `json:"batch_id,omitempty"`
 	Metadata  map[string]interface{} `json:"metadata,omitempty"`
+
+	// CallbackURL is kept by the gateway for webhook delivery and is not
+	// forwarded to the workers
+	CallbackURL string `json:"-"`
 }
 
 type FaceRecognitionEventData struct {
-	ImageID      string                  `json:"image_id"`
+	ImageID string `json:"image_id"`
+	// UserID and TenantID are echoed from image.received, so that a job
+	// missing from the status store keeps its owner
+	UserID       string                  `json:"user_id,omitempty"`
+	TenantID     string                  `json:"tenant_id,omitempty"`
 	FacesFound   int                     `json:"faces_found"`
 	ProcessingMs int64                   `json:"processing_ms"`
 	Results      []FaceRecognitionResult `json:"results"`
@@ -43,6 +57,8 @@ type BoundingBox struct {
 
 type DataSavedEventData struct {
 	ImageID    string    `json:"image_id"`
+	UserID     string    `json:"user_id,omitempty"`   // echoed from image.received
+	TenantID   string    `json:"tenant_id,omitempty"` // echoed from image.received
 	SavedAt    time.Time `json:"saved_at"`
 	StorageURL string    `json:"storage_url,omitempty"`
 	Success    bool      `json:"success"`
@@ -0,0 +1,15 @@
+package models
+
+import "time"
+
+// IdempotencyRecord holds the response to a request made with an
+// Idempotency-Key so that retries of the same request can be replayed
+type IdempotencyRecord struct {
+	Key         string    `json:"key"`
+	Fingerprint string    `json:"fingerprint"`
+	Completed   bool      `json:"completed"`
+	StatusCode  int       `json:"status_code,omitempty"`
+	Response    []byte    `json:"response,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+	ExpiresAt   time.Time `json:"expires_at"`
+}
@@ -0,0 +1,103 @@
+package models
+
+import "time"
+
+type JobState string
+
+const (
+	JobStateQueued     JobState = "queued"
+	JobStateProcessing JobState = "processing"
+	JobStateCompleted  JobState = "completed"
+	JobStateFailed     JobState = "failed"
+)
+
+// IsTerminal reports whether no further transitions are expected for the job
+func (s JobState) IsTerminal() bool {
+	return s == JobStateCompleted || s == JobStateFailed
+}
+
+type JobStatus struct {
+	ImageID     string     `json:"image_id"`
+	TenantID    string     `json:"tenant_id,omitempty"`
+	UserID      string     `json:"user_id,omitempty"`
+	CallbackURL string     `json:"callback_url,omitempty"`
+	Status      JobState   `json:"status"`
+	Message     string     `json:"message,omitempty"`
+	Error       string     `json:"error,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+	CompletedAt *time.Time `json:"completed_at,omitempty"`
+}
+
+type ImageResults struct {
+	ImageID      string                  `json:"image_id"`
+	FacesFound   int                     `json:"faces_found"`
+	ProcessingMs int64                   `json:"processing_ms"`
+	Results      []FaceRecognitionResult `json:"results"`
+	StorageURL   string                  `json:"storage_url,omitempty"`
+	RecognizedAt *time.Time              `json:"recognized_at,omitempty"`
+	SavedAt      *time.Time              `json:"saved_at,omitempty"`
+	UpdatedAt    time.Time               `json:"updated_at"`
+}
+
+type JobEventType string
+
+const (
+	JobEventQueued     JobEventType = "queued"
+	JobEventRecognized JobEventType = "recognized"
+	JobEventSaved      JobEventType = "saved"
+	JobEventFailed     JobEventType = "failed"
+)
+
+// JobEvent is a status transition pushed to clients following a job
+type JobEvent struct {
+	Type      JobEventType `json:"type"`
+	ImageID   string       `json:"image_id"`
+	TenantID  string       `json:"tenant_id,omitempty"`
+	UserID    string       `json:"user_id,omitempty"`
+	Status    JobState     `json:"status"`
+	Message   string       `json:"message,omitempty"`
+	Error     string       `json:"error,omitempty"`
+	Timestamp time.Time    `json:"timestamp"`
+	Data      interface{}  `json:"data,omitempty"`
+}
+
+type WebhookDeliveryState string
+
+const (
+	WebhookDeliveryPending   WebhookDeliveryState = "pending"
+	WebhookDeliveryDelivered WebhookDeliveryState = "delivered"
+	WebhookDeliveryFailed    WebhookDeliveryState = "failed"
+)
+
+// WebhookPayload is the signed JSON body POSTed to a job's callback URL
+type WebhookPayload struct {
+	DeliveryID string      `json:"delivery_id"`
+	Event      string      `json:"event"`
+	ImageID    string      `json:"image_id"`
+	UserID     string      `json:"user_id,omitempty"`
+	Status     JobState    `json:"status"`
+	Timestamp  time.Time   `json:"timestamp"`
+	Data       interface{} `json:"data,omitempty"`
+}
+
+type WebhookDelivery struct {
+	ID        string               `json:"id"`
+	ImageID   string               `json:"image_id"`
+	URL       string               `json:"url"`
+	Event     string               `json:"event"`
+	State     WebhookDeliveryState `json:"state"`
+	Attempts  []WebhookAttempt     `json:"attempts"`
+	CreatedAt time.Time            `json:"created_at"`
+	UpdatedAt time.Time            `json:"updated_at"`
+	// Payload is the signed body, kept so pending deliveries survive restarts
+	Payload []byte `json:"-"`
+}
+
+type WebhookAttempt struct {
+	Attempt    int       `json:"attempt"`
+	At         time.Time `json:"at"`
+	StatusCode int       `json:"status_code,omitempty"`
+	DurationMs int64     `json:"duration_ms"`
+	Error      string    `json:"error,omitempty"`
+}
@@ -0,0 +1,22 @@
+package models
+
+import "time"
+
+// OutboxMessage is an event kept in the local outbox until the broker has
+// confirmed it. Seq orders messages by the time they were added.
+type OutboxMessage struct {
+	Seq     int64  `json:"seq"`
+	EventID string `json:"event_id"`
+	Topic   string `json:"topic"`
+	Payload []byte `json:"payload"`
+	// CorrelationID is the request ID of the request that added the message
+	CorrelationID string `json:"correlation_id,omitempty"`
+	// TraceContext carries the trace of the request that added the message
+	TraceContext map[string]string `json:"trace_context,omitempty"`
+	Attempts     int               `json:"attempts"`
+	LastError    string            `json:"last_error,omitempty"`
+	CreatedAt    time.Time         `json:"created_at"`
+	SentAt       *time.Time        `json:"sent_at,omitempty"`
+	// FailedAt is set when the message was dead-lettered instead of sent
+	FailedAt *time.Time `json:"failed_at,omitempty"`
+}
@@ -5,10 +5,46 @@ import (
 )
 
 type ProcessImageRequest struct {
-	Image    *multipart.FileHeader `form:"image" binding:"required"`
-	Name     string                `form:"name"`
-	UserID   string                `form:"user_id"`
-	Metadata string                `form:"metadata"` // JSON string
+	Image       *multipart.FileHeader `form:"image" binding:"required"`
+	Name        string                `form:"name"`
+	UserID      string                `form:"user_id"`
+	Metadata    string                `form:"metadata"`     // JSON string
+	CallbackURL string                `form:"callback_url"` // optional webhook target
+}
+
+// ProcessImageURLRequest submits an image by URL instead of uploading it
+type ProcessImageURLRequest struct {
+	ImageURL    string                 `json:"image_url" binding:"required"`
+	Name        string                 `json:"name"`
+	UserID      string                 `json:"user_id"`
+	Metadata    map[string]interface{} `json:"metadata"`
+	CallbackURL string                 `json:"callback_url"`
+}
+
+// BatchRequest is the JSON form of a batch submission
+type BatchRequest struct {
+	UserID      string             `json:"user_id"`
+	CallbackURL string             `json:"callback_url"`
+	Items       []BatchRequestItem `json:"items" binding:"required,min=1"`
+}
+
+type BatchRequestItem struct {
+	ImageData string                 `json:"image_data" binding:"required"` // base64 encoded
+	FileName  string                 `json:"file_name"`
+	MimeType  string                 `json:"mime_type"`
+	Name      string                 `json:"name"`
+	Metadata  map[string]interface{} `json:"metadata"`
+}
+
+type BatchResponse struct {
+	Success  bool        `json:"success"`
+	Message  string      `json:"message"`
+	BatchID  string      `json:"batch_id,omitempty"`
+	Accepted int         `json:"accepted"`
+	Rejected int         `json:"rejected"`
+	Skipped  int         `json:"skipped,omitempty"`
+	Items    []BatchItem `json:"items,omitempty"`
+	Error    string      `json:"error,omitempty"`
 }
 
 type ProcessImageResponse struct {
@@ -0,0 +1,49 @@
+package models
+
+import "time"
+
+const (
+	UsagePeriodDaily   = "daily"
+	UsagePeriodMonthly = "monthly"
+)
+
+// UsageRecord is a ledger entry for one accepted image
+type UsageRecord struct {
+	ImageID   string    `json:"image_id"`
+	TenantID  string    `json:"tenant_id"`
+	UserID    string    `json:"user_id,omitempty"`
+	Bytes     int64     `json:"bytes"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// UsageTotals sums ledger entries over a period
+type UsageTotals struct {
+	Images int64 `json:"images"`
+	Bytes  int64 `json:"bytes"`
+}
+
+// QuotaLimits caps usage per period. Zero means unlimited.
+type QuotaLimits struct {
+	DailyImages   int64 `json:"daily_images"`
+	DailyBytes    int64 `json:"daily_bytes"`
+	MonthlyImages int64 `json:"monthly_images"`
+	MonthlyBytes  int64 `json:"monthly_bytes"`
+}
+
+// UsagePeriod is the consumption of a tenant in the current period against
+// its limits
+type UsagePeriod struct {
+	Period     string    `json:"period"`
+	Start      time.Time `json:"start"`
+	ResetsAt   time.Time `json:"resets_at"`
+	Images     int64     `json:"images"`
+	Bytes      int64     `json:"bytes"`
+	ImageLimit int64     `json:"image_limit,omitempty"`
+	BytesLimit int64     `json:"bytes_limit,omitempty"`
+}
+
+type UsageReport struct {
+	TenantID string        `json:"tenant_id"`
+	Enforced bool          `json:"enforced"`
+	Periods  []UsagePeriod `json:"periods"`
+}
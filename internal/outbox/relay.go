@@ -0,0 +1,279 @@
+package outbox
+
+import (
+	"ai-image-microservice/api-gateway/internal/config"
+	"ai-image-microservice/api-gateway/internal/models"
+	"ai-image-microservice/api-gateway/internal/rabbitmq"
+	"ai-image-microservice/api-gateway/internal/store"
+	"ai-image-microservice/api-gateway/pkg/logger"
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+)
+
+// pruneInterval is how often sent messages past their retention are deleted
+const pruneInterval = time.Hour
+
+// publishFunc makes one attempt at publishing an encoded event and waits for
+// the broker confirm
+type publishFunc func(ctx context.Context, topic, eventID string, payload []byte) error
+
+// DeadLetterFunc is called for an event that can never be published
+type DeadLetterFunc func(ctx context.Context, msg models.OutboxMessage, err error)
+
+// Relay records events in the outbox and publishes them to the exchange in
+// the order they were added. Events wait in the outbox while the broker is
+// unreachable and are published once the connection is back. The event ID
+// is sent as the message ID so consumers can drop the rare duplicate left
+// by a crash between the broker confirm and the outbox update.
+type Relay struct {
+	store     store.OutboxStore
+	connected func() bool
+	publishFn publishFunc
+	wake      chan struct{}
+
+	mu          sync.Mutex
+	deadLetters []DeadLetterFunc
+
+	ctx       context.Context
+	cancel    context.CancelFunc
+	startOnce sync.Once
+	done      chan struct{}
+}
+
+var (
+	relay     *Relay
+	relayOnce sync.Once
+)
+
+func GetRelay() *Relay {
+	relayOnce.Do(func() {
+		conn := rabbitmq.GetConnection()
+		relay = newRelay(store.GetOutboxStore(), conn.IsConnected,
+			func(ctx context.Context, topic, eventID string, payload []byte) error {
+				return rabbitmq.PublishMessage(ctx, conn, topic, eventID, payload)
+			})
+		conn.OnReconnect(relay.Notify)
+	})
+	return relay
+}
+
+func newRelay(outboxStore store.OutboxStore, connected func() bool, publish publishFunc) *Relay {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Relay{
+		store:     outboxStore,
+		connected: connected,
+		publishFn: publish,
+		wake:      make(chan struct{}, 1),
+		ctx:       ctx,
+		cancel:    cancel,
+		done:      make(chan struct{}),
+	}
+}
+
+// Enqueue wraps data in an event and adds it to the outbox, returning the
+// event ID. The event ID is derived from the topic and key, the ID of the
+// entity the event is about, so enqueueing the same event again is a no-op.
+// The event is published in the background.
+func (r *Relay) Enqueue(ctx context.Context, topic, key string, data interface{}) (string, error) {
+	eventID := rabbitmq.EventID(topic, key)
+	payload, err := rabbitmq.NewEvent(ctx, eventID, topic, data)
+	if err != nil {
+		return "", err
+	}
+
+	// The trace is resumed when the relay publishes the event
+	traceContext := propagation.MapCarrier{}
+	otel.GetTextMapPropagator().Inject(ctx, traceContext)
+
+	if err := r.store.Add(ctx, &models.OutboxMessage{
+		EventID:       eventID,
+		Topic:         topic,
+		Payload:       payload,
+		CorrelationID: logger.RequestIDFromContext(ctx),
+		TraceContext:  traceContext,
+		CreatedAt:     time.Now().UTC(),
+	}); err != nil {
+		return "", fmt.Errorf("failed to add event to outbox: %w", err)
+	}
+
+	r.Notify()
+	return eventID, nil
+}
+
+// OnDeadLetter registers fn to be called for each event dropped from the
+// outbox because it can never be published
+func (r *Relay) OnDeadLetter(fn DeadLetterFunc) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.deadLetters = append(r.deadLetters, fn)
+}
+
+// Notify wakes the relay to publish pending events without waiting for the
+// next interval
+func (r *Relay) Notify() {
+	select {
+	case r.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Start runs the relay in the background. Events left in the outbox by a
+// previous run are published first.
+func (r *Relay) Start() {
+	r.startOnce.Do(func() {
+		go r.run()
+		logrus.Info("Outbox relay started")
+	})
+}
+
+// Shutdown stops the relay and waits for the publish in progress. Events
+// still pending stay in the outbox for the next start.
+func (r *Relay) Shutdown(ctx context.Context) error {
+	r.cancel()
+
+	started := true
+	r.startOnce.Do(func() { started = false })
+	if !started {
+		return nil
+	}
+
+	select {
+	case <-r.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Pending returns the number of events waiting to be published
+func (r *Relay) Pending(ctx context.Context) (int, error) {
+	return r.store.CountPending(ctx)
+}
+
+func (r *Relay) run() {
+	defer close(r.done)
+
+	ticker := time.NewTicker(config.AppConfig.Outbox.RelayInterval)
+	defer ticker.Stop()
+
+	var lastPrune time.Time
+	for {
+		r.drain()
+
+		if time.Since(lastPrune) >= pruneInterval {
+			r.prune()
+			lastPrune = time.Now()
+		}
+
+		select {
+		case <-r.ctx.Done():
+			return
+		case <-r.wake:
+		case <-ticker.C:
+		}
+	}
+}
+
+// drain publishes pending events until the outbox is empty or a publish
+// fails. Events are never skipped, so a failure holds back the later ones
+// and preserves their order.
+func (r *Relay) drain() {
+	batchSize := max(config.AppConfig.Outbox.BatchSize, 1)
+
+	for r.ctx.Err() == nil && r.connected() {
+		messages, err := r.store.Pending(r.ctx, batchSize)
+		if err != nil {
+			logrus.Errorf("Failed to read outbox: %v", err)
+			return
+		}
+
+		for _, msg := range messages {
+			if err := r.publish(msg); err != nil {
+				return
+			}
+		}
+
+		if len(messages) < batchSize {
+			return
+		}
+	}
+}
+
+func (r *Relay) publish(msg models.OutboxMessage) error {
+	ctx := otel.GetTextMapPropagator().Extract(r.ctx, propagation.MapCarrier(msg.TraceContext))
+	if msg.CorrelationID != "" {
+		ctx = logger.WithRequestID(ctx, msg.CorrelationID)
+	}
+	ctx, cancel := context.WithTimeout(ctx, config.AppConfig.RabbitMQ.PublishTimeout)
+	err := r.publishFn(ctx, msg.Topic, msg.EventID, msg.Payload)
+	cancel()
+
+	// Retrying cannot change the routing of an unroutable event, and
+	// keeping it would hold back every later one
+	if errors.Is(err, rabbitmq.ErrUnroutable) {
+		return r.deadLetter(ctx, msg, err)
+	}
+
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"event_id": msg.EventID,
+			"topic":    msg.Topic,
+			"attempts": msg.Attempts + 1,
+		}).Warnf("Failed to publish event from outbox: %v", err)
+
+		if recordErr := r.store.RecordFailure(context.Background(), msg.Seq, err.Error()); recordErr != nil {
+			logrus.Errorf("Failed to record outbox failure for %s: %v", msg.EventID, recordErr)
+		}
+		return err
+	}
+
+	if err := r.store.MarkSent(context.Background(), msg.Seq, time.Now().UTC()); err != nil {
+		// The event will be published again; consumers deduplicate it by
+		// message ID
+		logrus.Errorf("Failed to mark outbox event %s sent: %v", msg.EventID, err)
+		return err
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"topic":    msg.Topic,
+		"event_id": msg.EventID,
+	}).Debug("Event relayed from outbox")
+	return nil
+}
+
+// deadLetter takes an event out of the pending ones, keeping it in the outbox
+// for inspection, and lets the listeners fail the work it was queued for
+func (r *Relay) deadLetter(ctx context.Context, msg models.OutboxMessage, err error) error {
+	logger.FromContext(ctx).WithFields(logrus.Fields{
+		"event_id": msg.EventID,
+		"topic":    msg.Topic,
+	}).Errorf("Dead-lettering unroutable event from outbox: %v", err)
+
+	if markErr := r.store.MarkFailed(context.Background(), msg.Seq, err.Error(), time.Now().UTC()); markErr != nil {
+		logrus.Errorf("Failed to dead-letter outbox event %s: %v", msg.EventID, markErr)
+		return markErr
+	}
+
+	r.mu.Lock()
+	listeners := append([]DeadLetterFunc(nil), r.deadLetters...)
+	r.mu.Unlock()
+
+	for _, fn := range listeners {
+		fn(context.WithoutCancel(ctx), msg, err)
+	}
+	return nil
+}
+
+func (r *Relay) prune() {
+	before := time.Now().Add(-config.AppConfig.Outbox.Retention)
+	if err := r.store.Prune(r.ctx, before); err != nil {
+		logrus.Errorf("Failed to prune outbox: %v", err)
+	}
+}
@@ -0,0 +1,235 @@
+package outbox
+
+import (
+	"ai-image-microservice/api-gateway/internal/config"
+	"ai-image-microservice/api-gateway/internal/models"
+	"ai-image-microservice/api-gateway/internal/rabbitmq"
+	"ai-image-microservice/api-gateway/internal/store"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestMain(m *testing.M) {
+	config.AppConfig = &config.Config{
+		RabbitMQ: config.RabbitMQConfig{PublishTimeout: time.Second},
+		Outbox: config.OutboxConfig{
+			RelayInterval: 20 * time.Millisecond,
+			BatchSize:     2,
+			Retention:     time.Hour,
+		},
+	}
+	os.Exit(m.Run())
+}
+
+// fakeBroker records published events and fails them on demand
+type fakeBroker struct {
+	mu        sync.Mutex
+	published []string
+	fail      func(topic, eventID string) error
+	connected atomic.Bool
+}
+
+func newFakeBroker() *fakeBroker {
+	b := &fakeBroker{}
+	b.connected.Store(true)
+	return b
+}
+
+func (b *fakeBroker) publish(ctx context.Context, topic, eventID string, payload []byte) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.fail != nil {
+		if err := b.fail(topic, eventID); err != nil {
+			return err
+		}
+	}
+	b.published = append(b.published, eventID)
+	return nil
+}
+
+func (b *fakeBroker) setFail(fail func(topic, eventID string) error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.fail = fail
+}
+
+func (b *fakeBroker) events() []string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return append([]string(nil), b.published...)
+}
+
+func newTestRelay(t *testing.T, broker *fakeBroker) (*Relay, store.OutboxStore) {
+	t.Helper()
+
+	s := store.NewMemoryOutboxStore(0)
+	r := newRelay(s, broker.connected.Load, broker.publish)
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		if err := r.Shutdown(ctx); err != nil {
+			t.Errorf("Shutdown: %v", err)
+		}
+	})
+	return r, s
+}
+
+func enqueue(t *testing.T, r *Relay, keys ...string) []string {
+	t.Helper()
+
+	ids := make([]string, 0, len(keys))
+	for _, key := range keys {
+		id, err := r.Enqueue(context.Background(), rabbitmq.TopicImageReceived, key, map[string]string{"image_id": key})
+		if err != nil {
+			t.Fatalf("Enqueue(%s): %v", key, err)
+		}
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if cond() {
+			return
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatalf("timed out waiting for %s", what)
+}
+
+func equal(a, b []string) bool {
+	return fmt.Sprint(a) == fmt.Sprint(b)
+}
+
+func TestRelayPublishesInOrder(t *testing.T) {
+	broker := newFakeBroker()
+	r, _ := newTestRelay(t, broker)
+
+	ids := enqueue(t, r, "a", "b", "c", "d", "e")
+	r.Start()
+
+	waitFor(t, "all events", func() bool { return len(broker.events()) == len(ids) })
+	if got := broker.events(); !equal(got, ids) {
+		t.Fatalf("published %v, want %v", got, ids)
+	}
+
+	if n, _ := r.Pending(context.Background()); n != 0 {
+		t.Fatalf("Pending = %d, want 0", n)
+	}
+}
+
+func TestRelayDeduplicatesEvents(t *testing.T) {
+	broker := newFakeBroker()
+	broker.connected.Store(false)
+	r, _ := newTestRelay(t, broker)
+
+	first := enqueue(t, r, "image-1")
+	second := enqueue(t, r, "image-1")
+	if first[0] != second[0] {
+		t.Fatalf("event IDs %s and %s differ for the same image", first[0], second[0])
+	}
+
+	if n, _ := r.Pending(context.Background()); n != 1 {
+		t.Fatalf("Pending = %d, want 1", n)
+	}
+}
+
+func TestRelayKeepsOrderAcrossFailures(t *testing.T) {
+	broker := newFakeBroker()
+	var failures atomic.Int32
+	broker.setFail(func(topic, eventID string) error {
+		if failures.Add(1) <= 3 {
+			return errors.New("broker unavailable")
+		}
+		return nil
+	})
+
+	r, s := newTestRelay(t, broker)
+	ids := enqueue(t, r, "a", "b", "c")
+	r.Start()
+
+	waitFor(t, "all events", func() bool { return len(broker.events()) == len(ids) })
+	if got := broker.events(); !equal(got, ids) {
+		t.Fatalf("published %v, want %v", got, ids)
+	}
+
+	if n, _ := s.CountPending(context.Background()); n != 0 {
+		t.Fatalf("CountPending = %d, want 0", n)
+	}
+}
+
+func TestRelayWaitsForConnection(t *testing.T) {
+	broker := newFakeBroker()
+	broker.connected.Store(false)
+
+	r, _ := newTestRelay(t, broker)
+	ids := enqueue(t, r, "a")
+	r.Start()
+
+	time.Sleep(50 * time.Millisecond)
+	if got := broker.events(); len(got) != 0 {
+		t.Fatalf("published %v while disconnected", got)
+	}
+
+	broker.connected.Store(true)
+	r.Notify()
+
+	waitFor(t, "the event", func() bool { return equal(broker.events(), ids) })
+}
+
+func TestRelayDeadLettersUnroutableEvents(t *testing.T) {
+	broker := newFakeBroker()
+	r, s := newTestRelay(t, broker)
+
+	ids := enqueue(t, r, "bad", "good")
+	broker.setFail(func(topic, eventID string) error {
+		if eventID == ids[0] {
+			return rabbitmq.ErrUnroutable
+		}
+		return nil
+	})
+
+	deadLetters := make(chan models.OutboxMessage, 1)
+	r.OnDeadLetter(func(ctx context.Context, msg models.OutboxMessage, err error) {
+		if !errors.Is(err, rabbitmq.ErrUnroutable) {
+			t.Errorf("dead letter error = %v, want ErrUnroutable", err)
+		}
+		deadLetters <- msg
+	})
+	r.Start()
+
+	select {
+	case msg := <-deadLetters:
+		if msg.EventID != ids[0] {
+			t.Fatalf("dead-lettered %s, want %s", msg.EventID, ids[0])
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("unroutable event was not dead-lettered")
+	}
+
+	// Later events are not held back, and the dead letter is not retried
+	waitFor(t, "the routable event", func() bool { return equal(broker.events(), ids[1:]) })
+	pending, _ := s.Pending(context.Background(), 10)
+	if len(pending) != 0 {
+		t.Fatalf("pending = %+v, want none", pending)
+	}
+}
+
+func TestRelayShutdownWithoutStart(t *testing.T) {
+	r := newRelay(store.NewMemoryOutboxStore(0), func() bool { return true }, newFakeBroker().publish)
+
+	if err := r.Shutdown(context.Background()); err != nil {
+		t.Fatalf("Shutdown: %v", err)
+	}
+}
@@ -0,0 +1,187 @@
+package rabbitmq
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+var (
+	// ErrNacked means the broker refused responsibility for a message
+	ErrNacked = errors.New("message was nacked by the broker")
+	// ErrUnroutable means a mandatory message matched no queue
+	ErrUnroutable = errors.New("message is unroutable")
+	// ErrChannelClosed means the channel closed before the broker confirmed
+	ErrChannelClosed = errors.New("channel closed before the message was confirmed")
+)
+
+// confirmChannel publishes on a channel in confirm mode and waits for the
+// broker to acknowledge each message. Mandatory messages that are returned
+// as unroutable fail with ErrUnroutable.
+//
+// The broker sends basic.return before the basic.ack of the same message,
+// and the client library delivers both synchronously from its reader. With
+// unbuffered notification channels drained by a single goroutine, a return
+// is therefore always recorded before the matching confirmation is handled.
+type confirmChannel struct {
+	channel *amqp.Channel
+
+	// publishMu keeps sequence numbers in step with publishes
+	publishMu sync.Mutex
+
+	mu        sync.Mutex
+	pending   map[uint64]*pendingPublish
+	byMessage map[string]*pendingPublish
+	closed    bool
+}
+
+type pendingPublish struct {
+	messageID string
+	returned  *amqp.Return
+	done      chan error
+}
+
+func newConfirmChannel(channel *amqp.Channel) (*confirmChannel, error) {
+	c := &confirmChannel{
+		channel:   channel,
+		pending:   make(map[uint64]*pendingPublish),
+		byMessage: make(map[string]*pendingPublish),
+	}
+
+	confirms := channel.NotifyPublish(make(chan amqp.Confirmation))
+	returns := channel.NotifyReturn(make(chan amqp.Return))
+
+	if err := channel.Confirm(false); err != nil {
+		return nil, fmt.Errorf("failed to enable publisher confirms: %w", err)
+	}
+
+	go c.listen(confirms, returns)
+	return c, nil
+}
+
+// Publish sends a mandatory message and blocks until the broker confirms it
+// or ctx is done. The message must carry a unique MessageId.
+func (c *confirmChannel) Publish(ctx context.Context, exchange, routingKey string, msg amqp.Publishing) error {
+	p := &pendingPublish{
+		messageID: msg.MessageId,
+		done:      make(chan error, 1),
+	}
+
+	c.publishMu.Lock()
+	tag := c.channel.GetNextPublishSeqNo()
+	if err := c.register(tag, p); err != nil {
+		c.publishMu.Unlock()
+		return err
+	}
+
+	err := c.channel.PublishWithContext(
+		ctx,
+		exchange,
+		routingKey,
+		true,  // mandatory
+		false, // immediate
+		msg,
+	)
+	c.publishMu.Unlock()
+
+	if err != nil {
+		c.unregister(tag)
+		return err
+	}
+
+	select {
+	case err := <-p.done:
+		return err
+	case <-ctx.Done():
+		c.unregister(tag)
+		return fmt.Errorf("timed out waiting for publisher confirm: %w", ctx.Err())
+	}
+}
+
+func (c *confirmChannel) isClosed() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.closed || c.channel.IsClosed()
+}
+
+func (c *confirmChannel) register(tag uint64, p *pendingPublish) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.closed {
+		return ErrChannelClosed
+	}
+
+	c.pending[tag] = p
+	if p.messageID != "" {
+		c.byMessage[p.messageID] = p
+	}
+	return nil
+}
+
+func (c *confirmChannel) unregister(tag uint64) *pendingPublish {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	p, ok := c.pending[tag]
+	if !ok {
+		return nil
+	}
+
+	delete(c.pending, tag)
+	if p.messageID != "" {
+		delete(c.byMessage, p.messageID)
+	}
+	return p
+}
+
+func (c *confirmChannel) listen(confirms <-chan amqp.Confirmation, returns <-chan amqp.Return) {
+	for confirms != nil || returns != nil {
+		select {
+		case ret, ok := <-returns:
+			if !ok {
+				returns = nil
+				continue
+			}
+			c.mu.Lock()
+			if p, exists := c.byMessage[ret.MessageId]; exists {
+				p.returned = &ret
+			}
+			c.mu.Unlock()
+
+		case confirmation, ok := <-confirms:
+			if !ok {
+				confirms = nil
+				continue
+			}
+			p := c.unregister(confirmation.DeliveryTag)
+			if p == nil {
+				// The publisher gave up waiting
+				continue
+			}
+
+			switch {
+			case !confirmation.Ack:
+				p.done <- ErrNacked
+			case p.returned != nil:
+				p.done <- fmt.Errorf("%w: %d %s", ErrUnroutable, p.returned.ReplyCode, p.returned.ReplyText)
+			default:
+				p.done <- nil
+			}
+		}
+	}
+
+	// The channel is gone; fail everything still waiting
+	c.mu.Lock()
+	c.closed = true
+	for tag, p := range c.pending {
+		p.done <- ErrChannelClosed
+		delete(c.pending, tag)
+	}
+	c.byMessage = make(map[string]*pendingPublish)
+	c.mu.Unlock()
+}
@@ -0,0 +1,109 @@
+package rabbitmq
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// listening starts the confirm listener of a fake channel on notification
+// channels the test feeds
+func listening(t *testing.T) (*confirmChannel, chan amqp.Confirmation, chan amqp.Return) {
+	t.Helper()
+
+	c := fakeConfirmChannel()
+	confirms := make(chan amqp.Confirmation)
+	returns := make(chan amqp.Return)
+	go c.listen(confirms, returns)
+	return c, confirms, returns
+}
+
+func pendingFor(t *testing.T, c *confirmChannel, tag uint64, messageID string) *pendingPublish {
+	t.Helper()
+
+	p := &pendingPublish{messageID: messageID, done: make(chan error, 1)}
+	if err := c.register(tag, p); err != nil {
+		t.Fatalf("register: %v", err)
+	}
+	return p
+}
+
+func outcome(t *testing.T, p *pendingPublish) error {
+	t.Helper()
+
+	select {
+	case err := <-p.done:
+		return err
+	case <-time.After(time.Second):
+		t.Fatal("publish was never resolved")
+		return nil
+	}
+}
+
+func TestConfirmChannelOutcomes(t *testing.T) {
+	c, confirms, returns := listening(t)
+	defer close(confirms)
+	defer close(returns)
+
+	acked := pendingFor(t, c, 1, "m1")
+	nacked := pendingFor(t, c, 2, "m2")
+	returned := pendingFor(t, c, 3, "m3")
+
+	confirms <- amqp.Confirmation{DeliveryTag: 1, Ack: true}
+	confirms <- amqp.Confirmation{DeliveryTag: 2, Ack: false}
+	// The broker returns an unroutable message before confirming it
+	returns <- amqp.Return{MessageId: "m3", ReplyCode: 312, ReplyText: "NO_ROUTE"}
+	confirms <- amqp.Confirmation{DeliveryTag: 3, Ack: true}
+
+	if err := outcome(t, acked); err != nil {
+		t.Fatalf("acked publish = %v, want nil", err)
+	}
+	if err := outcome(t, nacked); !errors.Is(err, ErrNacked) {
+		t.Fatalf("nacked publish = %v, want ErrNacked", err)
+	}
+	if err := outcome(t, returned); !errors.Is(err, ErrUnroutable) {
+		t.Fatalf("returned publish = %v, want ErrUnroutable", err)
+	}
+}
+
+func TestConfirmChannelIgnoresAbandonedPublishes(t *testing.T) {
+	c, confirms, returns := listening(t)
+	defer close(confirms)
+	defer close(returns)
+
+	pendingFor(t, c, 1, "m1")
+	c.unregister(1)
+	next := pendingFor(t, c, 2, "m2")
+
+	confirms <- amqp.Confirmation{DeliveryTag: 1, Ack: true}
+	confirms <- amqp.Confirmation{DeliveryTag: 2, Ack: true}
+
+	if err := outcome(t, next); err != nil {
+		t.Fatalf("publish after an abandoned one = %v, want nil", err)
+	}
+}
+
+func TestConfirmChannelFailsPendingOnClose(t *testing.T) {
+	c, confirms, returns := listening(t)
+
+	p := pendingFor(t, c, 1, "m1")
+	close(confirms)
+	close(returns)
+
+	if err := outcome(t, p); !errors.Is(err, ErrChannelClosed) {
+		t.Fatalf("pending publish = %v, want ErrChannelClosed", err)
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for !c.isClosed() {
+		if time.Now().After(deadline) {
+			t.Fatal("channel not marked closed")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if err := c.register(2, &pendingPublish{done: make(chan error, 1)}); !errors.Is(err, ErrChannelClosed) {
+		t.Fatalf("register on a closed channel = %v, want ErrChannelClosed", err)
+	}
+}
@@ -2,6 +2,9 @@ package rabbitmq
 
 import (
 	"ai-image-microservice/api-gateway/internal/config"
+	"ai-image-microservice/api-gateway/internal/metrics"
+	"ai-image-microservice/api-gateway/internal/tracing"
+	"ai-image-microservice/api-gateway/pkg/logger"
 	"context"
 	"fmt"
 	"sync"
@@ -9,17 +12,25 @@ import (
 
 	amqp "github.com/rabbitmq/amqp091-go"
 	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 type Connection struct {
-	conn            *amqp.Connection
-	channel         *amqp.Channel
-	url             string
-	mu              sync.RWMutex
-	reconnectMu     sync.Mutex
-	isReconnecting  bool
-	notifyClose     chan *amqp.Error
-	notifyReconnect chan bool
+	conn           *amqp.Connection
+	channel        *amqp.Channel
+	pool           *ChannelPool
+	topology       topology
+	url            string
+	mu             sync.RWMutex
+	reconnectMu    sync.Mutex
+	isReconnecting bool
+	closed         bool
+	notifyClose    chan *amqp.Error
+
+	listenersMu sync.Mutex
+	listeners   []func()
 }
 
 var (
@@ -30,11 +41,20 @@ var (
 func GetConnection() *Connection {
 	once.Do(func() {
 		instance = &Connection{
-			url:             config.AppConfig.RabbitMQ.URL,
-			notifyReconnect: make(chan bool),
+			url: config.AppConfig.RabbitMQ.URL,
 		}
+		instance.pool = newChannelPool(config.AppConfig.RabbitMQ.ChannelPoolSize, instance.openPublishChannel)
 		if err := instance.Connect(); err != nil {
-			logrus.Fatalf("Failed to connect to RabbitMQ: %v", err)
+			// With the outbox, events wait locally until the broker is up
+			if !config.AppConfig.Outbox.Enabled {
+				logrus.Fatalf("Failed to connect to RabbitMQ: %v", err)
+			}
+			logrus.Warnf("RabbitMQ is unavailable, connecting in the background: %v", err)
+			go func() {
+				instance.reconnect()
+				instance.handleReconnect()
+			}()
+			return
 		}
 		go instance.handleReconnect()
 	})
@@ -70,22 +90,102 @@ func (c *Connection) Connect() error {
 
 	c.conn = conn
 	c.channel = channel
-	c.notifyClose = make(chan *amqp.Error)
+	// Buffered so the library never blocks delivering the close error
+	c.notifyClose = make(chan *amqp.Error, 1)
 	c.conn.NotifyClose(c.notifyClose)
 
 	logrus.Info("Successfully connected to RabbitMQ")
 	return nil
 }
 
+// handleReconnect waits for the connection to close and reconnects, until
+// the connection is closed through Close
 func (c *Connection) handleReconnect() {
 	for {
-		select {
-		case err := <-c.notifyClose:
-			if err != nil {
-				logrus.Errorf("RabbitMQ connection closed: %v", err)
-				c.reconnect()
-			}
+		c.mu.RLock()
+		notifyClose := c.notifyClose
+		c.mu.RUnlock()
+
+		err := <-notifyClose
+		if c.isClosed() {
+			return
 		}
+
+		if err != nil {
+			logrus.Errorf("RabbitMQ connection closed: %v", err)
+		} else {
+			logrus.Warn("RabbitMQ connection closed unexpectedly")
+		}
+		c.reconnect()
+	}
+}
+
+func (c *Connection) isClosed() bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.closed
+}
+
+// IsConnected reports whether the connection is currently open
+func (c *Connection) IsConnected() bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.conn != nil && !c.conn.IsClosed()
+}
+
+// WhenConnected runs setup right away if the connection is open and returns
+// its error. Otherwise setup runs once the connection is established, and
+// again after each reconnect until it succeeds.
+func (c *Connection) WhenConnected(setup func() error) error {
+	var (
+		mu   sync.Mutex
+		done bool
+	)
+	run := func() error {
+		mu.Lock()
+		defer mu.Unlock()
+
+		if done {
+			return nil
+		}
+		if err := setup(); err != nil {
+			return err
+		}
+		done = true
+		return nil
+	}
+
+	// Registered first so a connection made in the meantime is not missed
+	c.OnReconnect(func() {
+		if err := run(); err != nil {
+			logrus.Errorf("RabbitMQ setup failed, retrying after the next reconnect: %v", err)
+		}
+	})
+
+	if c.IsConnected() {
+		return run()
+	}
+	return nil
+}
+
+// OnReconnect registers fn to run after every successful reconnect, once the
+// recorded topology has been declared again
+func (c *Connection) OnReconnect(fn func()) {
+	c.listenersMu.Lock()
+	defer c.listenersMu.Unlock()
+
+	c.listeners = append(c.listeners, fn)
+}
+
+func (c *Connection) notifyReconnected() {
+	c.listenersMu.Lock()
+	listeners := append([]func(){}, c.listeners...)
+	c.listenersMu.Unlock()
+
+	for _, fn := range listeners {
+		fn()
 	}
 }
 
@@ -104,7 +204,7 @@ func (c *Connection) reconnect() {
 		c.reconnectMu.Unlock()
 	}()
 
-	for {
+	for !c.isClosed() {
 		logrus.Info("Attempting to reconnect to RabbitMQ...")
 
 		if err := c.Connect(); err != nil {
@@ -113,11 +213,32 @@ func (c *Connection) reconnect() {
 			continue
 		}
 
-		c.notifyReconnect <- true
-		break
+		if err := c.restoreTopology(); err != nil {
+			logrus.Errorf("Failed to restore topology: %v", err)
+			c.mu.Lock()
+			c.conn.Close()
+			c.mu.Unlock()
+			time.Sleep(config.AppConfig.RabbitMQ.ReconnectDelay)
+			continue
+		}
+
+		logrus.Info("Reconnected to RabbitMQ")
+		metrics.IncReconnect()
+		c.notifyReconnected()
+		return
 	}
 }
 
+// restoreTopology declares every recorded exchange, queue and binding on the
+// new connection
+func (c *Connection) restoreTopology() error {
+	channel, err := c.GetChannel()
+	if err != nil {
+		return err
+	}
+	return c.topology.declare(channel)
+}
+
 func (c *Connection) GetChannel() (*amqp.Channel, error) {
 	c.mu.RLock()
 	defer c.mu.RUnlock()
@@ -128,7 +249,61 @@ func (c *Connection) GetChannel() (*amqp.Channel, error) {
 	return c.channel, nil
 }
 
+// OpenChannel opens a separate channel with the configured prefetch count,
+// for consumers that must not share the publishing channel
+func (c *Connection) OpenChannel() (*amqp.Channel, error) {
+	c.mu.RLock()
+	conn := c.conn
+	c.mu.RUnlock()
+
+	if conn == nil {
+		return nil, fmt.Errorf("connection is not initialized")
+	}
+
+	channel, err := conn.Channel()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open channel: %w", err)
+	}
+
+	if err := channel.Qos(config.AppConfig.RabbitMQ.PrefetchCount, 0, false); err != nil {
+		channel.Close()
+		return nil, fmt.Errorf("failed to set QoS: %w", err)
+	}
+
+	return channel, nil
+}
+
+// openPublishChannel opens a channel on the current connection for the
+// publishing pool
+func (c *Connection) openPublishChannel() (*amqp.Channel, error) {
+	c.mu.RLock()
+	conn := c.conn
+	c.mu.RUnlock()
+
+	if conn == nil || conn.IsClosed() {
+		return nil, fmt.Errorf("connection is not open")
+	}
+
+	channel, err := conn.Channel()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open publishing channel: %w", err)
+	}
+	return channel, nil
+}
+
+// PoolStats reports the state of the publishing channel pool
+func (c *Connection) PoolStats() PoolStats {
+	return c.pool.Stats()
+}
+
+// Close closes the connection for good; it is not reconnected afterwards
 func (c *Connection) Close() error {
+	c.mu.Lock()
+	c.closed = true
+	c.mu.Unlock()
+
+	c.pool.Close()
+
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
@@ -147,39 +322,62 @@ func (c *Connection) Close() error {
 	return nil
 }
 
-// DeclareExchange declares an exchange
+// DeclareExchange declares an exchange. Declarations are recorded and made
+// again after a reconnect.
 func (c *Connection) DeclareExchange(name, kind string) error {
 	channel, err := c.GetChannel()
 	if err != nil {
 		return err
 	}
 
-	return channel.ExchangeDeclare(
-		name,  // name
-		kind,  // type
-		true,  // durable
-		false, // auto-deleted
-		false, // internal
-		false, // no-wait
-		nil,   // arguments
-	)
+	decl := exchangeDecl{name: name, kind: kind}
+	if err := declareExchange(channel, decl); err != nil {
+		return err
+	}
+
+	c.topology.addExchange(decl)
+	return nil
 }
 
 // DeclareQueue declares a queue
 func (c *Connection) DeclareQueue(name string) (amqp.Queue, error) {
+	return c.DeclareQueueWithArgs(name, nil)
+}
+
+// DeclareQueueWithArgs declares a queue with optional arguments such as
+// x-dead-letter-exchange
+func (c *Connection) DeclareQueueWithArgs(name string, args amqp.Table) (amqp.Queue, error) {
 	channel, err := c.GetChannel()
 	if err != nil {
 		return amqp.Queue{}, err
 	}
 
-	return channel.QueueDeclare(
-		name,  // name
-		true,  // durable
-		false, // delete when unused
-		false, // exclusive
-		false, // no-wait
-		nil,   // arguments
-	)
+	decl := queueDecl{name: name, args: args}
+	queue, err := declareQueue(channel, decl)
+	if err != nil {
+		return amqp.Queue{}, err
+	}
+
+	c.topology.addQueue(decl)
+	return queue, nil
+}
+
+// DeclareExclusiveQueue declares a transient queue owned by the connection.
+// It is declared again on the new connection after a reconnect.
+func (c *Connection) DeclareExclusiveQueue(name string) (amqp.Queue, error) {
+	channel, err := c.GetChannel()
+	if err != nil {
+		return amqp.Queue{}, err
+	}
+
+	decl := queueDecl{name: name, exclusive: true}
+	queue, err := declareQueue(channel, decl)
+	if err != nil {
+		return amqp.Queue{}, err
+	}
+
+	c.topology.addQueue(decl)
+	return queue, nil
 }
 
 // BindQueue binds a queue to an exchange
@@ -189,33 +387,57 @@ func (c *Connection) BindQueue(queueName, routingKey, exchangeName string) error
 		return err
 	}
 
-	return channel.QueueBind(
-		queueName,    // queue name
-		routingKey,   // routing key
-		exchangeName, // exchange
-		false,        // no-wait
-		nil,          // arguments
+	decl := bindingDecl{queue: queueName, routingKey: routingKey, exchange: exchangeName}
+	if err := bindQueue(channel, decl); err != nil {
+		return err
+	}
+
+	c.topology.addBinding(decl)
+	return nil
+}
+
+// PublishWithContext publishes a persistent, mandatory JSON message and waits
+// for the broker to confirm it. The publish is traced and the trace context
+// is sent in the message headers. The request ID of ctx, if any, is sent as
+// the correlation ID.
+func (c *Connection) PublishWithContext(ctx context.Context, exchange, routingKey, messageID string, message []byte) error {
+	ctx, span := tracing.Tracer().Start(ctx, "publish "+routingKey,
+		trace.WithSpanKind(trace.SpanKindProducer),
+		trace.WithAttributes(
+			attribute.String("messaging.system", "rabbitmq"),
+			attribute.String("messaging.operation.type", "publish"),
+			attribute.String("messaging.destination.name", exchange),
+			attribute.String("messaging.rabbitmq.destination.routing_key", routingKey),
+			attribute.String("messaging.message.id", messageID),
+			attribute.Int("messaging.message.body.size", len(message)),
+		),
 	)
+
+	headers := amqp.Table{}
+	otel.GetTextMapPropagator().Inject(ctx, headerCarrier(headers))
+
+	err := c.Publish(ctx, exchange, routingKey, amqp.Publishing{
+		Headers:       headers,
+		ContentType:   "application/json",
+		Body:          message,
+		DeliveryMode:  amqp.Persistent,
+		MessageId:     messageID,
+		CorrelationId: logger.RequestIDFromContext(ctx),
+		Timestamp:     time.Now(),
+	})
+	tracing.EndSpan(span, err)
+	return err
 }
 
-// PublishWithContext publishes a message with context
-func (c *Connection) PublishWithContext(ctx context.Context, exchange, routingKey string, message []byte) error {
-	channel, err := c.GetChannel()
+// Publish publishes a mandatory message on a pooled channel and waits for the
+// broker to confirm it. It fails with ErrUnroutable when no queue is bound
+// for the routing key and with ErrNacked when the broker rejects it.
+func (c *Connection) Publish(ctx context.Context, exchange, routingKey string, msg amqp.Publishing) error {
+	channel, err := c.pool.Get(ctx)
 	if err != nil {
 		return err
 	}
+	defer c.pool.Put(channel)
 
-	return channel.PublishWithContext(
-		ctx,
-		exchange,
-		routingKey,
-		false, // mandatory
-		false, // immediate
-		amqp.Publishing{
-			ContentType:  "application/json",
-			Body:         message,
-			DeliveryMode: amqp.Persistent,
-			Timestamp:    time.Now(),
-		},
-	)
+	return channel.Publish(ctx, exchange, routingKey, msg)
 }
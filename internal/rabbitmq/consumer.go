@@ -1,25 +1,75 @@
 package rabbitmq
 
 import (
+	"ai-image-microservice/api-gateway/internal/config"
+	"ai-image-microservice/api-gateway/internal/metrics"
+	"ai-image-microservice/api-gateway/internal/tracing"
+	"ai-image-microservice/api-gateway/pkg/logger"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"sync"
+	"time"
 
+	"github.com/google/uuid"
 	amqp "github.com/rabbitmq/amqp091-go"
 	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
-type MessageHandler func(message []byte) error
+const (
+	DeadLetterExchangeType = "direct"
+	DeadLetterQueueSuffix  = ".dlq"
+	RetryRoutingKeySuffix  = ".retry"
 
+	// HeaderRetryCount counts the failed deliveries of a republished message
+	HeaderRetryCount = "x-retry-count"
+
+	// unknownEventType labels metrics of messages without a readable or
+	// registered type
+	unknownEventType = "unknown"
+
+	// stopCancelGrace is how long Stop waits for cancelled handlers to return
+	// once its context has expired
+	stopCancelGrace = 5 * time.Second
+)
+
+// MessageHandler handles the body of a delivery. The context expires after
+// ConsumeTimeout and is cancelled when the consumer stops. Returned errors
+// are retried through the retry tiers unless wrapped with Permanent.
+type MessageHandler func(ctx context.Context, message []byte) error
+
+// Consumer handles deliveries with a fixed pool of workers on a channel of
+// its own. It subscribes again whenever the connection is re-established.
 type Consumer struct {
 	conn     *Connection
 	handlers map[string]MessageHandler
+	queue    string
+	tag      string
+	workers  sync.WaitGroup
+	// fanout consumers read a queue of their own and drop failed messages
+	fanout bool
+
+	mu      sync.Mutex
+	channel *amqp.Channel
+	stopped bool
+
+	// ctx is cancelled to abort running handlers when Stop gives up waiting
+	ctx    context.Context
+	cancel context.CancelFunc
 }
 
 func NewConsumer() *Consumer {
+	ctx, cancel := context.WithCancel(context.Background())
 	return &Consumer{
 		conn:     GetConnection(),
 		handlers: make(map[string]MessageHandler),
+		tag:      "api-gateway-" + uuid.New().String(),
+		ctx:      ctx,
+		cancel:   cancel,
 	}
 }
 
@@ -27,79 +77,413 @@ func (c *Consumer) RegisterHandler(routingKey string, handler MessageHandler) {
 	c.handlers[routingKey] = handler
 }
 
+// StartConsuming declares the queue along with its dead-letter exchange,
+// <queue>.dlq and retry tiers, binds it to the routing keys and starts
+// handling deliveries.
+//
+// Each retry tier is a queue without consumers whose messages expire after
+// the tier delay and are dead-lettered back to the main exchange with the
+// <queue>.retry routing key, which only this queue is bound to.
 func (c *Consumer) StartConsuming(queueName string, routingKeys []string) error {
-	queue, err := c.conn.DeclareQueue(queueName)
+	dlx := config.AppConfig.RabbitMQ.DeadLetterExchange
+	dlq := queueName + DeadLetterQueueSuffix
+
+	if err := c.conn.DeclareExchange(dlx, DeadLetterExchangeType); err != nil {
+		return fmt.Errorf("failed to declare dead-letter exchange: %w", err)
+	}
+
+	if _, err := c.conn.DeclareQueue(dlq); err != nil {
+		return fmt.Errorf("failed to declare dead-letter queue: %w", err)
+	}
+
+	if err := c.conn.BindQueue(dlq, queueName, dlx); err != nil {
+		return fmt.Errorf("failed to bind dead-letter queue: %w", err)
+	}
+
+	queue, err := c.conn.DeclareQueueWithArgs(queueName, amqp.Table{
+		"x-dead-letter-exchange":    dlx,
+		"x-dead-letter-routing-key": queueName,
+	})
 	if err != nil {
+		var amqpErr *amqp.Error
+		if errors.As(err, &amqpErr) && amqpErr.Code == amqp.PreconditionFailed {
+			// Queues created by earlier versions have no dead-letter
+			// arguments, and RabbitMQ refuses to change them in place
+			return fmt.Errorf("queue %s exists without dead-letter arguments; "+
+				"delete it once drained, or set RABBITMQ_GATEWAY_QUEUE to a new name "+
+				"and delete the old queue: %w", queueName, err)
+		}
 		return fmt.Errorf("failed to declare queue: %w", err)
 	}
 
-	for _, key := range routingKeys {
+	retryKey := queueName + RetryRoutingKeySuffix
+	for _, delay := range config.AppConfig.RabbitMQ.RetryDelays {
+		_, err := c.conn.DeclareQueueWithArgs(retryQueueName(queueName, delay), amqp.Table{
+			"x-message-ttl":             delay.Milliseconds(),
+			"x-dead-letter-exchange":    ExchangeName,
+			"x-dead-letter-routing-key": retryKey,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to declare retry queue: %w", err)
+		}
+	}
+
+	bindings := append([]string{retryKey}, routingKeys...)
+	for _, key := range bindings {
 		if err := c.conn.BindQueue(queue.Name, key, ExchangeName); err != nil {
 			return fmt.Errorf("failed to bind queue: %w", err)
 		}
 	}
 
-	channel, err := c.conn.GetChannel()
+	c.queue = queue.Name
+	if err := c.subscribe(); err != nil {
+		return err
+	}
+
+	// The delivery channel closes with the connection
+	c.conn.OnReconnect(c.resubscribe)
+
+	logrus.Infof("Started consuming from queue: %s", queueName)
+	return nil
+}
+
+// StartFanout consumes every message of a fanout exchange on a queue of
+// this gateway instance alone. The queue is exclusive to the connection, so
+// it goes away with the instance; messages whose handler fails are dropped
+// rather than retried.
+func (c *Consumer) StartFanout(exchange string) error {
+	if err := c.conn.DeclareExchange(exchange, FanoutExchangeType); err != nil {
+		return fmt.Errorf("failed to declare fanout exchange: %w", err)
+	}
+
+	queueName := exchange + "." + c.tag
+	if _, err := c.conn.DeclareExclusiveQueue(queueName); err != nil {
+		return fmt.Errorf("failed to declare fanout queue: %w", err)
+	}
+
+	if err := c.conn.BindQueue(queueName, "", exchange); err != nil {
+		return fmt.Errorf("failed to bind fanout queue: %w", err)
+	}
+
+	c.queue = queueName
+	c.fanout = true
+	if err := c.subscribe(); err != nil {
+		return err
+	}
+
+	c.conn.OnReconnect(c.resubscribe)
+
+	logrus.Infof("Started consuming from fanout exchange: %s", exchange)
+	return nil
+}
+
+// subscribe opens a consumer channel and starts the workers on it
+func (c *Consumer) subscribe() error {
+	channel, err := c.conn.OpenChannel()
 	if err != nil {
 		return err
 	}
 
 	msgs, err := channel.Consume(
-		queue.Name, // queue
-		"",         // consumer
-		false,      // auto-ack
-		false,      // exclusive
-		false,      // no-local
-		false,      // no-wait
-		nil,        // args
+		c.queue, // queue
+		c.tag,   // consumer
+		false,   // auto-ack
+		false,   // exclusive
+		false,   // no-local
+		false,   // no-wait
+		nil,     // args
 	)
 	if err != nil {
+		channel.Close()
 		return fmt.Errorf("failed to register consumer: %w", err)
 	}
 
-	go c.handleMessages(msgs)
+	c.mu.Lock()
+	defer c.mu.Unlock()
 
-	logrus.Infof("Started consuming from queue: %s", queueName)
+	if c.stopped {
+		channel.Close()
+		return nil
+	}
+	c.channel = channel
+
+	workers := max(config.AppConfig.RabbitMQ.ConsumerWorkers, 1)
+	for i := 0; i < workers; i++ {
+		c.workers.Add(1)
+		go c.handleMessages(msgs)
+	}
+
+	logrus.Debugf("Subscribed to queue %s with %d workers", c.queue, workers)
 	return nil
 }
 
+// resubscribe runs after a reconnect. Workers of the previous subscription
+// have already returned, or will once their current delivery is handled.
+func (c *Consumer) resubscribe() {
+	c.mu.Lock()
+	stopped := c.stopped
+	c.mu.Unlock()
+
+	if stopped {
+		return
+	}
+
+	if err := c.subscribe(); err != nil {
+		logrus.Errorf("Failed to resume consuming from queue %s: %v", c.queue, err)
+		return
+	}
+
+	logrus.Infof("Resumed consuming from queue: %s", c.queue)
+}
+
+// handleMessages is a worker loop; it returns once the delivery channel is
+// closed by Stop or by the channel going away
 func (c *Consumer) handleMessages(msgs <-chan amqp.Delivery) {
+	defer c.workers.Done()
+
 	for msg := range msgs {
-		go c.processMessage(msg)
+		c.processMessage(msg)
 	}
 }
 
+// processMessage handles a delivery within a consumer span that continues
+// the trace carried in the message headers. The correlation ID is passed to
+// the handler as the request ID so its logs can be matched to the request.
 func (c *Consumer) processMessage(msg amqp.Delivery) {
+	ctx := otel.GetTextMapPropagator().Extract(c.ctx, headerCarrier(msg.Headers))
+	if msg.CorrelationId != "" {
+		ctx = logger.WithRequestID(ctx, msg.CorrelationId)
+	}
+	ctx, span := tracing.Tracer().Start(ctx, "process "+c.queue,
+		trace.WithSpanKind(trace.SpanKindConsumer),
+		trace.WithAttributes(
+			attribute.String("messaging.system", "rabbitmq"),
+			attribute.String("messaging.operation.type", "process"),
+			attribute.String("messaging.destination.name", c.queue),
+			attribute.String("messaging.rabbitmq.destination.routing_key", msg.RoutingKey),
+			attribute.String("messaging.message.id", msg.MessageId),
+			attribute.Int("messaging.rabbitmq.delivery_count", deliveryCount(msg)),
+		),
+	)
+
+	var handlerErr error
+	defer func() {
+		tracing.EndSpan(span, handlerErr)
+	}()
+
 	var event map[string]interface{}
 	if err := json.Unmarshal(msg.Body, &event); err != nil {
-		logrus.Errorf("Failed to unmarshal message: %v", err)
-		msg.Nack(false, false)
+		metrics.IncDelivery(unknownEventType)
+		c.deadLetter(msg, "", fmt.Sprintf("malformed message: %v", err))
 		return
 	}
 
 	eventType, ok := event["event_type"].(string)
 	if !ok {
-		logrus.Error("Event type not found in message")
-		msg.Nack(false, false)
+		metrics.IncDelivery(unknownEventType)
+		c.deadLetter(msg, "", "event type not found in message")
 		return
 	}
+	span.SetAttributes(attribute.String("event_type", eventType))
 
+	// Only registered types become metric labels, so that publishers cannot
+	// grow the label set without bound
 	handler, exists := c.handlers[eventType]
 	if !exists {
-		logrus.Warnf("No handler registered for event type: %s", eventType)
-		msg.Nack(false, false)
+		metrics.IncDelivery(unknownEventType)
+		c.deadLetter(msg, "", fmt.Sprintf("no handler registered for event type %q", eventType))
+		return
+	}
+	metrics.IncDelivery(eventType)
+
+	ctx, cancel := context.WithTimeout(ctx, config.AppConfig.RabbitMQ.ConsumeTimeout)
+	defer cancel()
+
+	if handlerErr = handler(ctx, msg.Body); handlerErr != nil {
+		c.retry(msg, eventType, handlerErr)
+		return
+	}
+
+	msg.Ack(false)
+	metrics.IncAck(eventType, "processed")
+}
+
+// retry schedules a failed message for redelivery through the retry tier
+// matching its delivery count. Permanent errors, and messages that reached
+// MaxDeliveries, are dead-lettered instead.
+func (c *Consumer) retry(msg amqp.Delivery, eventType string, handlerErr error) {
+	if c.fanout {
+		// The fanout queue has no dead-letter exchange, so this drops it
+		c.deadLetter(msg, eventType, fmt.Sprintf("fanout handler error: %v", handlerErr))
 		return
 	}
 
-	if err := handler(msg.Body); err != nil {
-		logrus.Errorf("Handler error for %s: %v", eventType, err)
-		msg.Nack(false, true) // requeue
+	if IsPermanent(handlerErr) {
+		c.deadLetter(msg, eventType, fmt.Sprintf("permanent handler error: %v", handlerErr))
+		return
+	}
+
+	deliveries := deliveryCount(msg)
+	maxDeliveries := config.AppConfig.RabbitMQ.MaxDeliveries
+
+	if deliveries >= maxDeliveries {
+		c.deadLetter(msg, eventType, fmt.Sprintf("handler failed %d times: %v", deliveries, handlerErr))
+		return
+	}
+
+	// Without retry tiers the message goes straight back to the queue
+	target := c.queue
+	var delay time.Duration
+	if delays := config.AppConfig.RabbitMQ.RetryDelays; len(delays) > 0 {
+		delay = delays[min(deliveries, len(delays))-1]
+		target = retryQueueName(c.queue, delay)
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"event_type":          eventType,
+		"message_id":          msg.MessageId,
+		logger.FieldRequestID: msg.CorrelationId,
+		"delivery":            deliveries,
+		"retry_in":            delay.String(),
+	}).Warnf("Handler error, retrying: %v", handlerErr)
+
+	headers := amqp.Table{}
+	for k, v := range msg.Headers {
+		headers[k] = v
+	}
+	headers[HeaderRetryCount] = int32(deliveries)
+
+	ctx, cancel := context.WithTimeout(context.Background(), config.AppConfig.RabbitMQ.PublishTimeout)
+	defer cancel()
+
+	// The default exchange routes straight to the target queue
+	err := c.conn.Publish(ctx, "", target, amqp.Publishing{
+		Headers:       headers,
+		ContentType:   msg.ContentType,
+		DeliveryMode:  amqp.Persistent,
+		CorrelationId: msg.CorrelationId,
+		MessageId:     msg.MessageId,
+		Timestamp:     msg.Timestamp,
+		Type:          msg.Type,
+		Body:          msg.Body,
+	})
+	if err != nil {
+		// A plain requeue would not count the delivery and could loop
+		// forever, so the message goes to the dead-letter queue instead
+		c.deadLetter(msg, eventType, fmt.Sprintf("failed to republish for retry: %v (handler error: %v)", err, handlerErr))
 		return
 	}
 
 	msg.Ack(false)
+	metrics.IncAck(eventType, "retried")
+}
+
+// retryQueueName names the retry tier queue after its delay in milliseconds,
+// so tiers that differ by less than a second do not share a queue
+func retryQueueName(queueName string, delay time.Duration) string {
+	return fmt.Sprintf("%s%s.%dms", queueName, RetryRoutingKeySuffix, delay.Milliseconds())
+}
+
+// deadLetter rejects a message without requeueing, which routes it to the
+// dead-letter queue
+func (c *Consumer) deadLetter(msg amqp.Delivery, eventType, reason string) {
+	logrus.WithFields(logrus.Fields{
+		"queue":               c.queue,
+		"event_type":          eventType,
+		"message_id":          msg.MessageId,
+		logger.FieldRequestID: msg.CorrelationId,
+		"reason":              reason,
+	}).Error("Dead-lettering message")
+
+	msg.Nack(false, false)
+	if eventType == "" {
+		eventType = unknownEventType
+	}
+	metrics.IncNack(eventType, false)
+}
+
+// drain waits for the workers to finish. If ctx expires first, it cancels
+// the running handlers and gives them grace to return before giving up, so a
+// handler that ignores its context cannot hold up shutdown.
+func (c *Consumer) drain(ctx context.Context, grace time.Duration) error {
+	drained := make(chan struct{})
+	go func() {
+		c.workers.Wait()
+		close(drained)
+	}()
+
+	select {
+	case <-drained:
+		return nil
+	case <-ctx.Done():
+	}
+
+	c.cancel()
+	timer := time.NewTimer(grace)
+	defer timer.Stop()
+
+	select {
+	case <-drained:
+		return fmt.Errorf("consumer stopped before draining: %w", ctx.Err())
+	case <-timer.C:
+		return fmt.Errorf("consumer stopped with handlers still running: %w", ctx.Err())
+	}
 }
 
+// deliveryCount returns how many times the message has been delivered,
+// including this delivery
+func deliveryCount(msg amqp.Delivery) int {
+	return headerInt(msg.Headers, HeaderRetryCount) + 1
+}
+
+func headerInt(headers amqp.Table, key string) int {
+	switch v := headers[key].(type) {
+	case int:
+		return v
+	case int8:
+		return int(v)
+	case int16:
+		return int(v)
+	case int32:
+		return int(v)
+	case int64:
+		return int(v)
+	case uint8:
+		return int(v)
+	case uint16:
+		return int(v)
+	case uint32:
+		return int(v)
+	default:
+		return 0
+	}
+}
+
+// Stop cancels the subscription and waits for the workers to finish the
+// deliveries already received. If ctx expires first, running handlers are
+// cancelled and given stopCancelGrace to return. The consumer channel is closed afterwards, so unacknowledged
+// messages return to the queue.
 func (c *Consumer) Stop(ctx context.Context) error {
-	return c.conn.Close()
+	c.mu.Lock()
+	c.stopped = true
+	channel := c.channel
+	c.mu.Unlock()
+
+	if channel == nil {
+		return nil
+	}
+
+	if err := channel.Cancel(c.tag, false); err != nil {
+		logrus.Warnf("Failed to cancel consumer %s: %v", c.tag, err)
+	}
+
+	err := c.drain(ctx, stopCancelGrace)
+	c.cancel()
+
+	if closeErr := channel.Close(); closeErr != nil && !errors.Is(closeErr, amqp.ErrClosed) {
+		logrus.Warnf("Failed to close consumer channel: %v", closeErr)
+	}
+
+	logrus.Infof("Stopped consuming from queue: %s", c.queue)
+	return err
 }
@@ -0,0 +1,249 @@
+package rabbitmq
+
+import (
+	"ai-image-microservice/api-gateway/internal/config"
+	"ai-image-microservice/api-gateway/internal/metrics"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+func TestRetryQueueName(t *testing.T) {
+	tests := []struct {
+		delay time.Duration
+		want  string
+	}{
+		{delay: time.Second, want: "jobs.retry.1000ms"},
+		{delay: 1500 * time.Millisecond, want: "jobs.retry.1500ms"},
+		{delay: 250 * time.Millisecond, want: "jobs.retry.250ms"},
+		{delay: time.Minute, want: "jobs.retry.60000ms"},
+	}
+
+	for _, tt := range tests {
+		if got := retryQueueName("jobs", tt.delay); got != tt.want {
+			t.Errorf("retryQueueName(%s) = %q, want %q", tt.delay, got, tt.want)
+		}
+	}
+}
+
+func TestDeliveryCount(t *testing.T) {
+	tests := []struct {
+		name    string
+		headers amqp.Table
+		want    int
+	}{
+		{name: "first delivery", headers: nil, want: 1},
+		{name: "int32 header", headers: amqp.Table{HeaderRetryCount: int32(2)}, want: 3},
+		{name: "int64 header", headers: amqp.Table{HeaderRetryCount: int64(4)}, want: 5},
+		{name: "unreadable header", headers: amqp.Table{HeaderRetryCount: "2"}, want: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := deliveryCount(amqp.Delivery{Headers: tt.headers}); got != tt.want {
+				t.Fatalf("deliveryCount = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+// fakeAcknowledger records how deliveries were settled
+type fakeAcknowledger struct {
+	mu      sync.Mutex
+	acks    []uint64
+	nacks   []uint64
+	requeue []bool
+}
+
+func (a *fakeAcknowledger) Ack(tag uint64, multiple bool) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.acks = append(a.acks, tag)
+	return nil
+}
+
+func (a *fakeAcknowledger) Nack(tag uint64, multiple, requeue bool) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.nacks = append(a.nacks, tag)
+	a.requeue = append(a.requeue, requeue)
+	return nil
+}
+
+func (a *fakeAcknowledger) Reject(tag uint64, requeue bool) error {
+	return a.Nack(tag, false, requeue)
+}
+
+func newTestConsumer(t *testing.T) *Consumer {
+	t.Helper()
+
+	config.AppConfig = &config.Config{RabbitMQ: config.RabbitMQConfig{
+		ConsumeTimeout:  time.Second,
+		ConsumerWorkers: 3,
+		MaxDeliveries:   3,
+	}}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	return &Consumer{
+		handlers: make(map[string]MessageHandler),
+		queue:    "jobs",
+		ctx:      ctx,
+		cancel:   cancel,
+	}
+}
+
+func delivery(ack amqp.Acknowledger, tag uint64, body string, headers amqp.Table) amqp.Delivery {
+	return amqp.Delivery{Acknowledger: ack, DeliveryTag: tag, Body: []byte(body), Headers: headers}
+}
+
+func TestProcessMessageSettlesDeliveries(t *testing.T) {
+	c := newTestConsumer(t)
+	c.RegisterHandler("ok", func(ctx context.Context, message []byte) error { return nil })
+	c.RegisterHandler("poison", func(ctx context.Context, message []byte) error {
+		return Permanent(errors.New("malformed payload"))
+	})
+	c.RegisterHandler("flaky", func(ctx context.Context, message []byte) error {
+		return errors.New("temporarily unavailable")
+	})
+
+	tests := []struct {
+		name    string
+		body    string
+		headers amqp.Table
+		acked   bool
+	}{
+		{name: "handled", body: `{"event_type":"ok"}`, acked: true},
+		{name: "permanent error", body: `{"event_type":"poison"}`},
+		{name: "no handler", body: `{"event_type":"unknown"}`},
+		{name: "no event type", body: `{}`},
+		{name: "malformed", body: `not json`},
+		{name: "out of deliveries", body: `{"event_type":"flaky"}`, headers: amqp.Table{HeaderRetryCount: int32(2)}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ack := &fakeAcknowledger{}
+			c.processMessage(delivery(ack, 1, tt.body, tt.headers))
+
+			if tt.acked {
+				if len(ack.acks) != 1 || len(ack.nacks) != 0 {
+					t.Fatalf("acks %v nacks %v, want one ack", ack.acks, ack.nacks)
+				}
+				return
+			}
+			// Dead-lettered messages are rejected without requeueing
+			if len(ack.acks) != 0 || len(ack.nacks) != 1 || ack.requeue[0] {
+				t.Fatalf("acks %v nacks %v requeue %v, want one nack without requeue", ack.acks, ack.nacks, ack.requeue)
+			}
+		})
+	}
+}
+
+func TestFanoutConsumerDropsFailedMessages(t *testing.T) {
+	c := newTestConsumer(t)
+	c.fanout = true
+	c.RegisterHandler("flaky", func(ctx context.Context, message []byte) error {
+		return errors.New("temporarily unavailable")
+	})
+
+	ack := &fakeAcknowledger{}
+	c.processMessage(delivery(ack, 1, `{"event_type":"flaky"}`, nil))
+
+	if len(ack.nacks) != 1 || ack.requeue[0] {
+		t.Fatalf("nacks %v requeue %v, want one nack without requeue", ack.nacks, ack.requeue)
+	}
+}
+
+func TestUnregisteredEventTypesAreNotMetricLabels(t *testing.T) {
+	c := newTestConsumer(t)
+
+	c.processMessage(delivery(&fakeAcknowledger{}, 1, `{"event_type":"unregistered-4f7c"}`, nil))
+
+	rec := httptest.NewRecorder()
+	metrics.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if strings.Contains(rec.Body.String(), "unregistered-4f7c") {
+		t.Fatal("unregistered event type exported as a metric label")
+	}
+	if !strings.Contains(rec.Body.String(), `rabbitmq_consumer_deliveries_total{event_type="unknown"}`) {
+		t.Fatal("delivery of an unregistered event type not counted as unknown")
+	}
+}
+
+func TestWorkersHandleDeliveriesConcurrently(t *testing.T) {
+	c := newTestConsumer(t)
+
+	var mu sync.Mutex
+	running, peak := 0, 0
+	c.RegisterHandler("slow", func(ctx context.Context, message []byte) error {
+		mu.Lock()
+		running++
+		peak = max(peak, running)
+		mu.Unlock()
+
+		time.Sleep(10 * time.Millisecond)
+
+		mu.Lock()
+		running--
+		mu.Unlock()
+		return nil
+	})
+
+	msgs := make(chan amqp.Delivery)
+	workers := config.AppConfig.RabbitMQ.ConsumerWorkers
+	for i := 0; i < workers; i++ {
+		c.workers.Add(1)
+		go c.handleMessages(msgs)
+	}
+
+	ack := &fakeAcknowledger{}
+	for i := 0; i < 12; i++ {
+		msgs <- delivery(ack, uint64(i), `{"event_type":"slow"}`, nil)
+	}
+	close(msgs)
+	c.workers.Wait()
+
+	if len(ack.acks) != 12 {
+		t.Fatalf("acked %d deliveries, want 12", len(ack.acks))
+	}
+	if peak < 2 || peak > workers {
+		t.Fatalf("peak concurrency = %d, want between 2 and %d", peak, workers)
+	}
+}
+
+func TestDrainGivesUpOnHandlersIgnoringCancellation(t *testing.T) {
+	c := newTestConsumer(t)
+
+	release := make(chan struct{})
+	defer close(release)
+	c.workers.Add(1)
+	go func() {
+		defer c.workers.Done()
+		<-release
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	done := make(chan error, 1)
+	go func() { done <- c.drain(ctx, 10*time.Millisecond) }()
+
+	select {
+	case err := <-done:
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Fatalf("drain = %v, want a deadline error", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("drain blocked on a handler that ignores cancellation")
+	}
+	if c.ctx.Err() == nil {
+		t.Fatal("running handlers were not cancelled")
+	}
+}
@@ -0,0 +1,28 @@
+package rabbitmq
+
+import "errors"
+
+// permanentError marks a handler failure that retrying cannot fix, such as a
+// malformed payload. The message is dead-lettered right away.
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string { return e.err.Error() }
+func (e *permanentError) Unwrap() error { return e.err }
+
+// Permanent wraps a handler error so the message is dead-lettered instead of
+// retried
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &permanentError{err: err}
+}
+
+// IsPermanent reports whether a handler error was marked with Permanent.
+// Any other error is retryable.
+func IsPermanent(err error) bool {
+	var permanent *permanentError
+	return errors.As(err, &permanent)
+}
@@ -0,0 +1,44 @@
+package rabbitmq
+
+import (
+	"ai-image-microservice/api-gateway/internal/config"
+	"ai-image-microservice/api-gateway/internal/metrics"
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// JobEventsExchange fans job events out to every gateway instance, so
+	// that SSE and WebSocket clients get them whichever instance handled
+	// the worker event
+	JobEventsExchange  = "api_gateway.job_events.fanout"
+	FanoutExchangeType = "fanout"
+
+	TopicJobEvent = "job.event"
+)
+
+// BroadcastJobEvent publishes a job event to the event hubs of every gateway
+// instance, this one included
+func BroadcastJobEvent(ctx context.Context, event interface{}) error {
+	eventID := uuid.New().String()
+	message, err := NewEvent(ctx, eventID, TopicJobEvent, event)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, config.AppConfig.RabbitMQ.PublishTimeout)
+	defer cancel()
+
+	start := time.Now()
+	err = GetConnection().PublishWithContext(ctx, JobEventsExchange, TopicJobEvent, eventID, message)
+	metrics.ObservePublish(TopicJobEvent, time.Since(start), err)
+	if err != nil {
+		return err
+	}
+
+	logrus.WithField("event_id", eventID).Debug("Job event broadcast")
+	return nil
+}
@@ -0,0 +1,188 @@
+package rabbitmq
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+	"github.com/sirupsen/logrus"
+)
+
+// PoolStats is a snapshot of the publishing channel pool
+type PoolStats struct {
+	Size     int    `json:"size"`
+	Open     int    `json:"open"`
+	Idle     int    `json:"idle"`
+	InUse    int    `json:"in_use"`
+	Created  uint64 `json:"created"`
+	Replaced uint64 `json:"replaced"`
+	Waits    uint64 `json:"waits"`
+	Failures uint64 `json:"failures"`
+}
+
+// ChannelPool hands out confirm-mode channels so concurrent publishes never
+// share a channel. At most size channels are open; callers wait for one to
+// be returned once the pool is exhausted. Channels found closed are dropped
+// and a new one is opened in their place on demand.
+type ChannelPool struct {
+	open func() (*confirmChannel, error)
+	size int
+
+	mu   sync.Mutex
+	idle []*confirmChannel
+	// count is the number of open channels, idle or in use
+	count int
+	// released is signalled whenever a channel is returned or dropped
+	released chan struct{}
+
+	created  atomic.Uint64
+	replaced atomic.Uint64
+	waits    atomic.Uint64
+	failures atomic.Uint64
+}
+
+func newChannelPool(size int, open func() (*amqp.Channel, error)) *ChannelPool {
+	return &ChannelPool{
+		open: func() (*confirmChannel, error) {
+			channel, err := open()
+			if err != nil {
+				return nil, err
+			}
+
+			ch, err := newConfirmChannel(channel)
+			if err != nil {
+				channel.Close()
+				return nil, err
+			}
+			return ch, nil
+		},
+		size:     max(size, 1),
+		released: make(chan struct{}, 1),
+	}
+}
+
+// Get returns an idle channel, opens a new one if the pool has room, or
+// waits until a channel is returned or ctx is done
+func (p *ChannelPool) Get(ctx context.Context) (*confirmChannel, error) {
+	waited := false
+	for {
+		p.mu.Lock()
+		for len(p.idle) > 0 {
+			ch := p.idle[len(p.idle)-1]
+			p.idle = p.idle[:len(p.idle)-1]
+			if !ch.isClosed() {
+				p.wakeNextLocked()
+				p.mu.Unlock()
+				return ch, nil
+			}
+			p.count--
+			p.replaced.Add(1)
+		}
+
+		if p.count < p.size {
+			p.count++
+			p.wakeNextLocked()
+			p.mu.Unlock()
+
+			ch, err := p.openChannel()
+			if err != nil {
+				p.drop()
+				return nil, err
+			}
+			return ch, nil
+		}
+		p.mu.Unlock()
+
+		if !waited {
+			waited = true
+			p.waits.Add(1)
+		}
+
+		select {
+		case <-p.released:
+		case <-ctx.Done():
+			return nil, fmt.Errorf("timed out waiting for a publishing channel: %w", ctx.Err())
+		}
+	}
+}
+
+// Put returns a channel to the pool. Closed channels are discarded.
+func (p *ChannelPool) Put(ch *confirmChannel) {
+	if ch.isClosed() {
+		p.replaced.Add(1)
+		p.drop()
+		return
+	}
+
+	p.mu.Lock()
+	p.idle = append(p.idle, ch)
+	p.mu.Unlock()
+	p.signal()
+}
+
+// Close closes the idle channels. Channels in use are closed with their
+// connection.
+func (p *ChannelPool) Close() {
+	p.mu.Lock()
+	idle := p.idle
+	p.idle = nil
+	p.count -= len(idle)
+	p.mu.Unlock()
+
+	for _, ch := range idle {
+		if err := ch.channel.Close(); err != nil {
+			logrus.Debugf("Failed to close pooled channel: %v", err)
+		}
+	}
+}
+
+func (p *ChannelPool) Stats() PoolStats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return PoolStats{
+		Size:     p.size,
+		Open:     p.count,
+		Idle:     len(p.idle),
+		InUse:    p.count - len(p.idle),
+		Created:  p.created.Load(),
+		Replaced: p.replaced.Load(),
+		Waits:    p.waits.Load(),
+		Failures: p.failures.Load(),
+	}
+}
+
+func (p *ChannelPool) openChannel() (*confirmChannel, error) {
+	ch, err := p.open()
+	if err != nil {
+		p.failures.Add(1)
+		return nil, err
+	}
+
+	p.created.Add(1)
+	return ch, nil
+}
+
+func (p *ChannelPool) drop() {
+	p.mu.Lock()
+	p.count--
+	p.mu.Unlock()
+	p.signal()
+}
+
+// wakeNextLocked passes a wakeup on to the next waiter while channels are
+// still available, since signals are coalesced
+func (p *ChannelPool) wakeNextLocked() {
+	if len(p.idle) > 0 || p.count < p.size {
+		p.signal()
+	}
+}
+
+func (p *ChannelPool) signal() {
+	select {
+	case p.released <- struct{}{}:
+	default:
+	}
+}
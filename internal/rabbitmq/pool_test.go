@@ -0,0 +1,166 @@
+package rabbitmq
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// fakeConfirmChannel is a confirm channel without a broker behind it
+func fakeConfirmChannel() *confirmChannel {
+	return &confirmChannel{
+		channel:   &amqp.Channel{},
+		pending:   make(map[uint64]*pendingPublish),
+		byMessage: make(map[string]*pendingPublish),
+	}
+}
+
+func newTestPool(size int) *ChannelPool {
+	return &ChannelPool{
+		open: func() (*confirmChannel, error) {
+			return fakeConfirmChannel(), nil
+		},
+		size:     size,
+		released: make(chan struct{}, 1),
+	}
+}
+
+func TestChannelPoolReusesChannels(t *testing.T) {
+	p := newTestPool(2)
+	ctx := context.Background()
+
+	first, err := p.Get(ctx)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	p.Put(first)
+
+	second, err := p.Get(ctx)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if second != first {
+		t.Fatal("idle channel was not reused")
+	}
+
+	stats := p.Stats()
+	if stats.Created != 1 || stats.Open != 1 || stats.InUse != 1 {
+		t.Fatalf("stats = %+v, want one channel created and in use", stats)
+	}
+}
+
+func TestChannelPoolWaitsWhenExhausted(t *testing.T) {
+	p := newTestPool(1)
+
+	held, err := p.Get(context.Background())
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := p.Get(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Get on an exhausted pool = %v, want a timeout", err)
+	}
+
+	got := make(chan *confirmChannel, 1)
+	go func() {
+		ch, err := p.Get(context.Background())
+		if err != nil {
+			t.Errorf("Get: %v", err)
+		}
+		got <- ch
+	}()
+
+	time.Sleep(10 * time.Millisecond)
+	p.Put(held)
+
+	select {
+	case ch := <-got:
+		if ch != held {
+			t.Fatal("waiter did not get the returned channel")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("waiter was not woken when a channel was returned")
+	}
+
+	if waits := p.Stats().Waits; waits != 2 {
+		t.Fatalf("Waits = %d, want 2", waits)
+	}
+}
+
+func TestChannelPoolReplacesClosedChannels(t *testing.T) {
+	p := newTestPool(1)
+	ctx := context.Background()
+
+	ch, _ := p.Get(ctx)
+	ch.closed = true
+	p.Put(ch)
+
+	fresh, err := p.Get(ctx)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if fresh == ch {
+		t.Fatal("closed channel was handed out again")
+	}
+
+	// A channel that closes while idle is replaced on the next Get
+	p.Put(fresh)
+	fresh.closed = true
+	if again, _ := p.Get(ctx); again == fresh {
+		t.Fatal("channel closed while idle was handed out again")
+	}
+
+	if stats := p.Stats(); stats.Replaced != 2 || stats.Open != 1 {
+		t.Fatalf("stats = %+v, want two replaced and one open", stats)
+	}
+}
+
+func TestChannelPoolOpenFailure(t *testing.T) {
+	p := newTestPool(1)
+	p.open = func() (*confirmChannel, error) {
+		return nil, errors.New("connection closed")
+	}
+
+	if _, err := p.Get(context.Background()); err == nil {
+		t.Fatal("Get succeeded without a channel")
+	}
+
+	// The failed slot is given back
+	p.open = func() (*confirmChannel, error) { return fakeConfirmChannel(), nil }
+	if _, err := p.Get(context.Background()); err != nil {
+		t.Fatalf("Get after a failure: %v", err)
+	}
+	if failures := p.Stats().Failures; failures != 1 {
+		t.Fatalf("Failures = %d, want 1", failures)
+	}
+}
+
+func TestChannelPoolConcurrentUse(t *testing.T) {
+	p := newTestPool(3)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ch, err := p.Get(context.Background())
+			if err != nil {
+				t.Errorf("Get: %v", err)
+				return
+			}
+			time.Sleep(time.Millisecond)
+			p.Put(ch)
+		}()
+	}
+	wg.Wait()
+
+	if stats := p.Stats(); stats.Created > 3 || stats.InUse != 0 {
+		t.Fatalf("stats = %+v, want at most 3 channels and none in use", stats)
+	}
+}
@@ -2,9 +2,14 @@ package rabbitmq
 
 import (
 	"ai-image-microservice/api-gateway/internal/config"
+	"ai-image-microservice/api-gateway/internal/metrics"
+	"ai-image-microservice/api-gateway/internal/models"
+	"ai-image-microservice/api-gateway/pkg/logger"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"sync"
 	"time"
 
 	"github.com/google/uuid"
@@ -26,8 +31,15 @@ type Publisher struct {
 	conn *Connection
 }
 
-var publisher *Publisher
+// publisher is set from the connection setup, which may run on the
+// reconnect goroutine, and read from request goroutines
+var (
+	publisher   *Publisher
+	publisherMu sync.Mutex
+)
 
+// InitPublisher declares the exchange and creates the publisher. It runs
+// again after reconnects; the publisher created first is kept.
 func InitPublisher() error {
 	conn := GetConnection()
 
@@ -35,56 +47,104 @@ func InitPublisher() error {
 		return fmt.Errorf("failed to declare exchange: %w", err)
 	}
 
-	publisher = &Publisher{
-		conn: conn,
-	}
+	publisherMu.Lock()
+	defer publisherMu.Unlock()
 
-	logrus.Info("RabbitMQ Publisher initialized")
+	if publisher == nil {
+		publisher = &Publisher{
+			conn: conn,
+		}
+		logrus.Info("RabbitMQ Publisher initialized")
+	}
 	return nil
 }
 
 func GetPublisher() *Publisher {
-	if publisher == nil {
-		if err := InitPublisher(); err != nil {
-			logrus.Fatalf("Failed to initialize publisher: %v", err)
-		}
+	publisherMu.Lock()
+	p := publisher
+	publisherMu.Unlock()
+	if p != nil {
+		return p
 	}
+
+	if err := InitPublisher(); err != nil {
+		logrus.Fatalf("Failed to initialize publisher: %v", err)
+	}
+
+	publisherMu.Lock()
+	defer publisherMu.Unlock()
 	return publisher
 }
 
-func (p *Publisher) PublishEvent(topic string, data interface{}) error {
-	return p.PublishEventWithContext(context.Background(), topic, data)
+// eventNamespace scopes the event IDs derived by EventID
+var eventNamespace = uuid.MustParse("5b0e9f3e-7f43-4c55-9a4e-1d3c8a1f2b60")
+
+// EventID derives the event ID of a topic's event about the entity
+// identified by key, so that queueing the same event twice yields the same
+// ID and is deduplicated
+func EventID(topic, key string) string {
+	return uuid.NewSHA1(eventNamespace, []byte(topic+":"+key)).String()
 }
 
-func (p *Publisher) PublishEventWithContext(ctx context.Context, topic string, data interface{}) error {
-	event := map[string]interface{}{
-		"event_id":   uuid.New().String(),
-		"event_type": topic,
-		"timestamp":  time.Now().UTC().Format(time.RFC3339),
-		"data":       data,
+// NewEvent wraps data in an event envelope and returns the encoded event. The
+// request ID of ctx, if any, becomes the correlation ID of the event.
+func NewEvent(ctx context.Context, eventID, topic string, data interface{}) ([]byte, error) {
+	event := models.BaseEvent{
+		EventID:       eventID,
+		EventType:     topic,
+		CorrelationID: logger.RequestIDFromContext(ctx),
+		Timestamp:     time.Now().UTC(),
+		Data:          data,
 	}
 
 	message, err := json.Marshal(event)
 	if err != nil {
-		return fmt.Errorf("failed to marshal event: %w", err)
+		return nil, fmt.Errorf("failed to marshal event: %w", err)
 	}
+	return message, nil
+}
 
-	publishCtx, cancel := context.WithTimeout(ctx, config.AppConfig.RabbitMQ.PublishTimeout)
-	defer cancel()
+func (p *Publisher) PublishEvent(topic string, data interface{}) error {
+	return p.PublishEventWithContext(context.Background(), topic, data)
+}
+
+// PublishEventWithContext publishes an event and returns once the broker has
+// confirmed it. Unroutable events are not retried since retrying cannot
+// change their routing.
+func (p *Publisher) PublishEventWithContext(ctx context.Context, topic string, data interface{}) error {
+	eventID := uuid.New().String()
+	message, err := NewEvent(ctx, eventID, topic, data)
+	if err != nil {
+		return err
+	}
 
 	var lastErr error
 	for i := 0; i < config.AppConfig.RabbitMQ.MaxRetries; i++ {
-		if err := p.conn.PublishWithContext(publishCtx, ExchangeName, topic, message); err != nil {
+		if i > 0 {
+			select {
+			case <-ctx.Done():
+				return fmt.Errorf("failed to publish after %d attempts: %w", i, lastErr)
+			case <-time.After(config.AppConfig.RabbitMQ.RetryDelay):
+			}
+		}
+
+		publishCtx, cancel := context.WithTimeout(ctx, config.AppConfig.RabbitMQ.PublishTimeout)
+		err := PublishMessage(publishCtx, p.conn, topic, eventID, message)
+		cancel()
+
+		if errors.Is(err, ErrUnroutable) {
+			return fmt.Errorf("failed to publish %s event: %w", topic, err)
+		}
+		if err != nil {
 			lastErr = err
 			logrus.Warnf("Failed to publish message (attempt %d/%d): %v", i+1, config.AppConfig.RabbitMQ.MaxRetries, err)
-			time.Sleep(config.AppConfig.RabbitMQ.RetryDelay)
 			continue
 		}
 
 		logrus.WithFields(logrus.Fields{
 			"topic":    topic,
-			"event_id": event["event_id"],
-		}).Debug("Event published successfully")
+			"event_id": eventID,
+		}).Debug("Event published and confirmed")
 
 		return nil
 	}
@@ -92,6 +152,16 @@ func (p *Publisher) PublishEventWithContext(ctx context.Context, topic string, d
 	return fmt.Errorf("failed to publish after %d attempts: %w", config.AppConfig.RabbitMQ.MaxRetries, lastErr)
 }
 
+// PublishMessage makes a single attempt at publishing an encoded event to the
+// exchange and waits for the broker confirm, recording publish metrics for
+// the topic
+func PublishMessage(ctx context.Context, conn *Connection, topic, eventID string, message []byte) error {
+	start := time.Now()
+	err := conn.PublishWithContext(ctx, ExchangeName, topic, eventID, message)
+	metrics.ObservePublish(topic, time.Since(start), err)
+	return err
+}
+
 func PublishImageReceived(imageData interface{}) error {
 	return GetPublisher().PublishEvent(TopicImageReceived, imageData)
 }
@@ -0,0 +1,137 @@
+package rabbitmq
+
+import (
+	"fmt"
+	"sync"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// topology records every exchange, queue and binding declared through the
+// connection so they can be declared again on a new connection. Entries keep
+// their declaration order, as bindings depend on their exchange and queue.
+type topology struct {
+	mu        sync.Mutex
+	exchanges []exchangeDecl
+	queues    []queueDecl
+	bindings  []bindingDecl
+}
+
+type exchangeDecl struct {
+	name string
+	kind string
+}
+
+type queueDecl struct {
+	name string
+	args amqp.Table
+	// exclusive queues belong to one connection and are deleted with it
+	exclusive bool
+}
+
+type bindingDecl struct {
+	queue      string
+	routingKey string
+	exchange   string
+}
+
+func (t *topology) addExchange(decl exchangeDecl) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for i, existing := range t.exchanges {
+		if existing.name == decl.name {
+			t.exchanges[i] = decl
+			return
+		}
+	}
+	t.exchanges = append(t.exchanges, decl)
+}
+
+func (t *topology) addQueue(decl queueDecl) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for i, existing := range t.queues {
+		if existing.name == decl.name {
+			t.queues[i] = decl
+			return
+		}
+	}
+	t.queues = append(t.queues, decl)
+}
+
+func (t *topology) addBinding(decl bindingDecl) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for _, existing := range t.bindings {
+		if existing == decl {
+			return
+		}
+	}
+	t.bindings = append(t.bindings, decl)
+}
+
+// declare replays the recorded topology on channel: exchanges first, then
+// queues, then bindings
+func (t *topology) declare(channel *amqp.Channel) error {
+	t.mu.Lock()
+	exchanges := append([]exchangeDecl(nil), t.exchanges...)
+	queues := append([]queueDecl(nil), t.queues...)
+	bindings := append([]bindingDecl(nil), t.bindings...)
+	t.mu.Unlock()
+
+	for _, e := range exchanges {
+		if err := declareExchange(channel, e); err != nil {
+			return fmt.Errorf("failed to declare exchange %s: %w", e.name, err)
+		}
+	}
+
+	for _, q := range queues {
+		if _, err := declareQueue(channel, q); err != nil {
+			return fmt.Errorf("failed to declare queue %s: %w", q.name, err)
+		}
+	}
+
+	for _, b := range bindings {
+		if err := bindQueue(channel, b); err != nil {
+			return fmt.Errorf("failed to bind queue %s to %s: %w", b.queue, b.routingKey, err)
+		}
+	}
+
+	return nil
+}
+
+func declareExchange(channel *amqp.Channel, decl exchangeDecl) error {
+	return channel.ExchangeDeclare(
+		decl.name, // name
+		decl.kind, // type
+		true,      // durable
+		false,     // auto-deleted
+		false,     // internal
+		false,     // no-wait
+		nil,       // arguments
+	)
+}
+
+func declareQueue(channel *amqp.Channel, decl queueDecl) (amqp.Queue, error) {
+	return channel.QueueDeclare(
+		decl.name,       // name
+		!decl.exclusive, // durable
+		decl.exclusive,  // delete when unused
+		decl.exclusive,  // exclusive
+		false,           // no-wait
+		decl.args,       // arguments
+	)
+}
+
+func bindQueue(channel *amqp.Channel, decl bindingDecl) error {
+	return channel.QueueBind(
+		decl.queue,      // queue name
+		decl.routingKey, // routing key
+		decl.exchange,   // exchange
+		false,           // no-wait
+		nil,             // arguments
+	)
+}
@@ -0,0 +1,46 @@
+package rabbitmq
+
+import (
+	"testing"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+func TestTopologyReplacesRedeclaredEntries(t *testing.T) {
+	var topo topology
+
+	topo.addExchange(exchangeDecl{name: "events", kind: "topic"})
+	topo.addExchange(exchangeDecl{name: "events.fanout", kind: FanoutExchangeType})
+	topo.addExchange(exchangeDecl{name: "events", kind: "direct"})
+
+	if len(topo.exchanges) != 2 {
+		t.Fatalf("recorded %d exchanges, want 2", len(topo.exchanges))
+	}
+	// A redeclared exchange keeps its place so that the declaration order holds
+	if got := topo.exchanges[0]; got.name != "events" || got.kind != "direct" {
+		t.Fatalf("first exchange = %+v, want the latest declaration of events", got)
+	}
+
+	topo.addQueue(queueDecl{name: "jobs"})
+	topo.addQueue(queueDecl{name: "jobs", args: amqp.Table{"x-dead-letter-exchange": "dlx"}})
+
+	if len(topo.queues) != 1 {
+		t.Fatalf("recorded %d queues, want 1", len(topo.queues))
+	}
+	if topo.queues[0].args["x-dead-letter-exchange"] != "dlx" {
+		t.Fatalf("queue args = %v, want the latest declaration", topo.queues[0].args)
+	}
+}
+
+func TestTopologySkipsDuplicateBindings(t *testing.T) {
+	var topo topology
+
+	binding := bindingDecl{queue: "jobs", routingKey: "job.done", exchange: "events"}
+	topo.addBinding(binding)
+	topo.addBinding(binding)
+	topo.addBinding(bindingDecl{queue: "jobs", routingKey: "job.failed", exchange: "events"})
+
+	if len(topo.bindings) != 2 {
+		t.Fatalf("recorded %d bindings, want 2", len(topo.bindings))
+	}
+}
@@ -0,0 +1,49 @@
+package rabbitmq
+
+import (
+	"context"
+	"testing"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestHeaderCarrierPropagatesTraceContext(t *testing.T) {
+	spanContext := trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    trace.TraceID{0x01, 0x02, 0x03},
+		SpanID:     trace.SpanID{0x04, 0x05},
+		TraceFlags: trace.FlagsSampled,
+		Remote:     true,
+	})
+	ctx := trace.ContextWithSpanContext(context.Background(), spanContext)
+
+	propagator := propagation.TraceContext{}
+	headers := amqp.Table{}
+	propagator.Inject(ctx, headerCarrier(headers))
+
+	if _, ok := headers["traceparent"].(string); !ok {
+		t.Fatalf("headers = %v, want a traceparent", headers)
+	}
+
+	// Brokers may hand string headers back as bytes
+	headers["traceparent"] = []byte(headers["traceparent"].(string))
+
+	extracted := trace.SpanContextFromContext(propagator.Extract(context.Background(), headerCarrier(headers)))
+	if extracted.TraceID() != spanContext.TraceID() || extracted.SpanID() != spanContext.SpanID() || !extracted.IsSampled() {
+		t.Fatalf("extracted %+v, want %+v", extracted, spanContext)
+	}
+}
+
+func TestHeaderCarrierGet(t *testing.T) {
+	h := headerCarrier{"s": "text", "b": []byte("bytes"), "n": int32(7)}
+
+	for key, want := range map[string]string{"s": "text", "b": "bytes", "n": "7", "missing": ""} {
+		if got := h.Get(key); got != want {
+			t.Errorf("Get(%s) = %q, want %q", key, got, want)
+		}
+	}
+	if len(h.Keys()) != 3 {
+		t.Errorf("Keys = %v, want 3 keys", h.Keys())
+	}
+}
@@ -0,0 +1,71 @@
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+)
+
+const sweepInterval = time.Minute
+
+type bucket struct {
+	tokens  float64
+	updated time.Time
+	full    time.Time // when the bucket will be full again
+}
+
+// MemoryLimiter keeps token buckets in process memory. Limits are per
+// gateway instance.
+type MemoryLimiter struct {
+	mu      sync.Mutex
+	buckets map[string]*bucket
+}
+
+func NewMemoryLimiter() *MemoryLimiter {
+	l := &MemoryLimiter{buckets: make(map[string]*bucket)}
+	go l.sweepLoop()
+	return l
+}
+
+func (l *MemoryLimiter) Allow(ctx context.Context, key string, limit Limit) (Result, error) {
+	now := time.Now()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(limit.Burst), updated: now}
+		l.buckets[key] = b
+	}
+
+	elapsed := now.Sub(b.updated).Seconds()
+	b.tokens = math.Min(float64(limit.Burst), b.tokens+elapsed*limit.Rate)
+	b.updated = now
+
+	allowed := b.tokens >= 1
+	if allowed {
+		b.tokens--
+	}
+
+	r := result(limit, allowed, b.tokens)
+	b.full = now.Add(r.ResetAfter)
+	return r, nil
+}
+
+// sweepLoop drops buckets that have refilled, as they are equivalent to new ones
+func (l *MemoryLimiter) sweepLoop() {
+	ticker := time.NewTicker(sweepInterval)
+	defer ticker.Stop()
+
+	for now := range ticker.C {
+		l.mu.Lock()
+		for key, b := range l.buckets {
+			if now.After(b.full) {
+				delete(l.buckets, key)
+			}
+		}
+		l.mu.Unlock()
+	}
+}
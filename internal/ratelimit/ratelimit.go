@@ -0,0 +1,89 @@
+package ratelimit
+
+import (
+	"ai-image-microservice/api-gateway/internal/config"
+	"context"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	DriverMemory = "memory"
+	DriverRedis  = "redis"
+)
+
+// Limit is a token bucket refilled at Rate tokens per second, holding up to
+// Burst tokens
+type Limit struct {
+	Rate  float64
+	Burst int
+}
+
+// PerMinute returns a limit of n requests per minute with the given burst
+func PerMinute(n, burst int) Limit {
+	return Limit{Rate: float64(n) / 60, Burst: burst}
+}
+
+// Result is the outcome of taking a token from a bucket
+type Result struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+	// RetryAfter is how long until a token is available, when not allowed
+	RetryAfter time.Duration
+	// ResetAfter is how long until the bucket is full again
+	ResetAfter time.Duration
+}
+
+// Limiter takes tokens from the bucket identified by key
+type Limiter interface {
+	Allow(ctx context.Context, key string, limit Limit) (Result, error)
+}
+
+// result derives the response of a bucket left holding tokens
+func result(limit Limit, allowed bool, tokens float64) Result {
+	r := Result{
+		Allowed:    allowed,
+		Limit:      limit.Burst,
+		Remaining:  int(math.Floor(tokens)),
+		ResetAfter: time.Duration((float64(limit.Burst) - tokens) / limit.Rate * float64(time.Second)),
+	}
+	if !allowed {
+		r.RetryAfter = time.Duration((1 - tokens) / limit.Rate * float64(time.Second))
+	}
+	return r
+}
+
+var limiter Limiter
+
+func InitLimiter() error {
+	cfg := config.AppConfig.RateLimit
+
+	switch cfg.Driver {
+	case DriverMemory:
+		limiter = NewMemoryLimiter()
+	case DriverRedis:
+		l, err := NewRedisLimiter(cfg.RedisURL)
+		if err != nil {
+			return err
+		}
+		limiter = l
+	default:
+		return fmt.Errorf("unsupported rate limit driver: %s", cfg.Driver)
+	}
+
+	logrus.Infof("Rate limiter initialized (%s)", cfg.Driver)
+	return nil
+}
+
+func GetLimiter() Limiter {
+	if limiter == nil {
+		if err := InitLimiter(); err != nil {
+			logrus.Fatalf("Failed to initialize rate limiter: %v", err)
+		}
+	}
+	return limiter
+}
@@ -0,0 +1,115 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+func newRedisLimiter(t *testing.T) (*RedisLimiter, *miniredis.Miniredis) {
+	t.Helper()
+
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	t.Cleanup(func() { client.Close() })
+	return NewRedisLimiterWithClient(client), server
+}
+
+func limiters(t *testing.T) map[string]Limiter {
+	redisLimiter, _ := newRedisLimiter(t)
+	return map[string]Limiter{
+		DriverMemory: NewMemoryLimiter(),
+		DriverRedis:  redisLimiter,
+	}
+}
+
+func TestLimiterAllowsBurstThenRejects(t *testing.T) {
+	for name, l := range limiters(t) {
+		t.Run(name, func(t *testing.T) {
+			limit := PerMinute(60, 3)
+			ctx := context.Background()
+
+			for i := 0; i < 3; i++ {
+				r, err := l.Allow(ctx, "client", limit)
+				if err != nil {
+					t.Fatalf("Allow: %v", err)
+				}
+				if !r.Allowed {
+					t.Fatalf("request %d rejected within the burst", i+1)
+				}
+				if r.Limit != 3 || r.Remaining != 2-i {
+					t.Fatalf("request %d: limit %d remaining %d, want 3 and %d", i+1, r.Limit, r.Remaining, 2-i)
+				}
+			}
+
+			r, err := l.Allow(ctx, "client", limit)
+			if err != nil {
+				t.Fatalf("Allow: %v", err)
+			}
+			if r.Allowed {
+				t.Fatal("request allowed past the burst")
+			}
+			if r.RetryAfter <= 0 || r.RetryAfter > time.Second {
+				t.Fatalf("RetryAfter = %s, want up to 1s at one token per second", r.RetryAfter)
+			}
+
+			// Buckets are independent per key
+			if r, _ := l.Allow(ctx, "other", limit); !r.Allowed {
+				t.Fatal("another key was rejected")
+			}
+		})
+	}
+}
+
+func TestLimiterRefills(t *testing.T) {
+	for name, l := range limiters(t) {
+		t.Run(name, func(t *testing.T) {
+			// 6000 per minute is one token every 10ms
+			limit := PerMinute(6000, 1)
+			ctx := context.Background()
+
+			if r, _ := l.Allow(ctx, "client", limit); !r.Allowed {
+				t.Fatal("first request rejected")
+			}
+			if r, _ := l.Allow(ctx, "client", limit); r.Allowed {
+				t.Fatal("second request allowed with an empty bucket")
+			}
+
+			time.Sleep(30 * time.Millisecond)
+			if r, _ := l.Allow(ctx, "client", limit); !r.Allowed {
+				t.Fatal("request rejected after the bucket refilled")
+			}
+		})
+	}
+}
+
+func TestRedisLimiterExpiresBuckets(t *testing.T) {
+	l, server := newRedisLimiter(t)
+
+	if _, err := l.Allow(context.Background(), "client", PerMinute(60, 2)); err != nil {
+		t.Fatalf("Allow: %v", err)
+	}
+
+	key := redisKeyPrefix + "client"
+	ttl := server.TTL(key)
+	if ttl <= 0 || ttl > 3*time.Second {
+		t.Fatalf("TTL = %s, want the refill time plus a second", ttl)
+	}
+
+	server.FastForward(ttl)
+	if server.Exists(key) {
+		t.Fatal("bucket still stored after it refilled")
+	}
+}
+
+func TestRedisLimiterReportsUnavailableBackend(t *testing.T) {
+	l, server := newRedisLimiter(t)
+	server.Close()
+
+	if _, err := l.Allow(context.Background(), "client", PerMinute(60, 1)); err == nil {
+		t.Fatal("Allow succeeded without a backend")
+	}
+}
@@ -1,24 +1,71 @@
 package router
 
 import (
+	"ai-image-microservice/api-gateway/internal/auth"
+	"ai-image-microservice/api-gateway/internal/config"
 	"ai-image-microservice/api-gateway/internal/handlers"
+	"ai-image-microservice/api-gateway/internal/metrics"
 	"ai-image-microservice/api-gateway/internal/middleware"
+	"ai-image-microservice/api-gateway/internal/ratelimit"
 	"ai-image-microservice/api-gateway/internal/services"
+	"ai-image-microservice/api-gateway/internal/store"
 	_ "github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
 )
 
+// maxFormFieldsSize leaves room for the fields and framing of a request next
+// to its image, and bounds requests without one
+const maxFormFieldsSize = 1 << 20
+
 func SetupRouter() *gin.Engine {
 	router := gin.New()
 
+	// Without trusted proxies ClientIP is the peer address, so clients
+	// cannot pick their rate limit key with X-Forwarded-For
+	if err := router.SetTrustedProxies(config.AppConfig.API.TrustedProxies); err != nil {
+		logrus.Fatalf("Invalid trusted proxies: %v", err)
+	}
+
 	router.Use(gin.Recovery())
+	router.Use(middleware.RequestID())
 	router.Use(middleware.Logger())
+	if config.AppConfig.Tracing.Enabled {
+		router.Use(middleware.Tracing())
+	}
+	if config.AppConfig.Metrics.Enabled {
+		router.Use(middleware.Metrics())
+		// The scrape endpoint is unauthenticated; restrict it at the proxy
+		router.GET(config.AppConfig.Metrics.Path, gin.WrapH(metrics.Handler()))
+	}
 	router.Use(middleware.CORS())
 
-	faceService := services.NewFaceService()
+	quotaService := services.GetQuotaService()
+	faceService := services.NewFaceService(quotaService)
+	jobService := services.NewJobService()
+	batchService := services.NewBatchService()
 
 	healthHandler := handlers.NewHealthHandler()
-	faceHandler := handlers.NewFaceHandler(faceService)
+	faceHandler := handlers.NewFaceHandler(faceService, jobService)
+	eventsHandler := handlers.NewEventsHandler(jobService)
+	uploadHandler := handlers.NewUploadHandler(faceService)
+	batchHandler := handlers.NewBatchHandler(faceService, batchService)
+	usageHandler := handlers.NewUsageHandler(quotaService)
+
+	var authMiddleware []gin.HandlerFunc
+	if config.AppConfig.Auth.Enabled {
+		authMiddleware = append(authMiddleware,
+			middleware.WebSocketCredentials(),
+			middleware.Auth(auth.GetAuthenticators()...),
+		)
+	}
+
+	var submitLimit, readLimit []gin.HandlerFunc
+	if config.AppConfig.RateLimit.Enabled {
+		limiter := ratelimit.GetLimiter()
+		submitLimit = append(submitLimit, middleware.RateLimit("submit", limiter, config.AppConfig.RateLimit.Submit))
+		readLimit = append(readLimit, middleware.RateLimit("read", limiter, config.AppConfig.RateLimit.Read))
+	}
 
 	v1 := router.Group("/api/v1")
 	{
@@ -26,12 +73,53 @@ func SetupRouter() *gin.Engine {
 		{
 			health.GET("/", healthHandler.Health)
 			health.GET("/ready", healthHandler.Ready)
+			health.GET("/rabbitmq", healthHandler.RabbitMQ)
 		}
 
-		face := v1.Group("/face")
+		usage := v1.Group("/usage", authMiddleware...)
+		usage.Use(readLimit...)
 		{
-			face.POST("/process", faceHandler.ProcessImage)
-			face.GET("/status/:image_id", faceHandler.GetProcessingStatus)
+			usage.GET("", usageHandler.GetUsage)
+		}
+
+		face := v1.Group("/face", authMiddleware...)
+
+		// Ingestion and read routes are rate limited separately
+		submit := face.Group("", submitLimit...)
+		read := face.Group("", readLimit...)
+		{
+			// Retried submissions with the same Idempotency-Key are replayed.
+			// Bodies are capped at what each route accepts: one image along
+			// with the form fields, or a whole batch.
+			idempotency := func(maxBodySize int64) gin.HandlerFunc {
+				return middleware.Idempotency(store.GetIdempotencyStore(), config.AppConfig.Idempotency.TTL, maxBodySize)
+			}
+			imageRequestSize := config.AppConfig.API.MaxUploadSize + maxFormFieldsSize
+			submit.POST("/process", idempotency(imageRequestSize), faceHandler.ProcessImage)
+			submit.POST("/process-url", idempotency(maxFormFieldsSize), faceHandler.ProcessImageURL)
+			submit.POST("/batch", idempotency(config.AppConfig.Batch.MaxRequestSize), batchHandler.SubmitBatch)
+			submit.POST("/batch/zip", idempotency(config.AppConfig.Batch.MaxRequestSize), batchHandler.SubmitZip)
+
+			submit.POST("/uploads", uploadHandler.Create)
+		}
+
+		{
+			read.GET("/status/:image_id", faceHandler.GetProcessingStatus)
+			read.GET("/results/:image_id", faceHandler.GetResults)
+			read.GET("/webhooks/:image_id", faceHandler.GetWebhookDeliveries)
+			read.GET("/events/:image_id", eventsHandler.StreamJobEvents)
+			read.GET("/ws", eventsHandler.SubscribeUserEvents)
+			read.GET("/batch/:batch_id", batchHandler.GetBatchStatus)
+
+			// Upload chunks count against the read limit so a resumed
+			// upload isn't throttled like a new submission
+			uploads := read.Group("/uploads")
+			{
+				uploads.OPTIONS("", uploadHandler.Options)
+				uploads.HEAD("/:upload_id", uploadHandler.Head)
+				uploads.PATCH("/:upload_id", uploadHandler.Patch)
+				uploads.DELETE("/:upload_id", uploadHandler.Delete)
+			}
 		}
 	}
 
@@ -0,0 +1,89 @@
+package services
+
+import (
+	"ai-image-microservice/api-gateway/internal/models"
+	"ai-image-microservice/api-gateway/internal/store"
+	"context"
+	"errors"
+	"fmt"
+)
+
+// BatchService records batch submissions and aggregates the state of their jobs
+type BatchService struct {
+	batchStore  store.BatchStore
+	statusStore store.StatusStore
+}
+
+func NewBatchService() *BatchService {
+	return &BatchService{
+		batchStore:  store.GetBatchStore(),
+		statusStore: store.GetStatusStore(),
+	}
+}
+
+func (s *BatchService) SaveBatch(ctx context.Context, batch *models.Batch) error {
+	if err := s.batchStore.Save(ctx, batch); err != nil {
+		return fmt.Errorf("failed to save batch: %w", err)
+	}
+	return nil
+}
+
+// GetBatchStatus returns the per-image states of a batch, or store.ErrNotFound
+func (s *BatchService) GetBatchStatus(ctx context.Context, batchID string) (*models.BatchStatus, error) {
+	batch, err := s.batchStore.Get(ctx, batchID)
+	if err != nil {
+		return nil, err
+	}
+
+	status := &models.BatchStatus{
+		BatchID:   batch.ID,
+		TenantID:  batch.TenantID,
+		UserID:    batch.UserID,
+		Total:     len(batch.Items),
+		Counts:    make(map[string]int),
+		Items:     make([]models.BatchItemStatus, 0, len(batch.Items)),
+		CreatedAt: batch.CreatedAt,
+	}
+
+	pending := 0
+	for _, item := range batch.Items {
+		itemStatus := models.BatchItemStatus{BatchItem: item}
+
+		if item.State == models.BatchItemRejected {
+			status.Counts[string(models.BatchItemRejected)]++
+			status.Items = append(status.Items, itemStatus)
+			continue
+		}
+
+		job, err := s.statusStore.Get(ctx, item.ImageID)
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			status.Counts["unknown"]++
+		case err != nil:
+			return nil, err
+		default:
+			itemStatus.Status = job.Status
+			itemStatus.UpdatedAt = &job.UpdatedAt
+			status.Counts[string(job.Status)]++
+			if !job.Status.IsTerminal() {
+				pending++
+			}
+		}
+
+		status.Items = append(status.Items, itemStatus)
+	}
+
+	completed := status.Counts[string(models.JobStateCompleted)]
+	switch {
+	case pending > 0:
+		status.State = models.BatchStateProcessing
+	case completed == status.Total:
+		status.State = models.BatchStateCompleted
+	case completed == 0:
+		status.State = models.BatchStateFailed
+	default:
+		status.State = models.BatchStatePartialFailure
+	}
+
+	return status, nil
+}
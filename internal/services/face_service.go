@@ -1,46 +1,233 @@
 package services
 
 import (
+	"ai-image-microservice/api-gateway/internal/blobstore"
+	"ai-image-microservice/api-gateway/internal/config"
+	"ai-image-microservice/api-gateway/internal/events"
 	"ai-image-microservice/api-gateway/internal/models"
+	"ai-image-microservice/api-gateway/internal/outbox"
 	"ai-image-microservice/api-gateway/internal/rabbitmq"
+	"ai-image-microservice/api-gateway/internal/store"
+	"ai-image-microservice/api-gateway/internal/tracing"
+	"ai-image-microservice/api-gateway/internal/webhook"
+	"ai-image-microservice/api-gateway/pkg/logger"
+	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
 	"fmt"
+	"time"
 
 	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	TransportInline     = "inline"
+	TransportClaimCheck = "claim_check"
 )
 
 type FaceService struct {
-	publisher *rabbitmq.Publisher
+	// Events go through the outbox relay when it is enabled, and are
+	// published directly otherwise
+	relay       *outbox.Relay
+	publisher   *rabbitmq.Publisher
+	statusStore store.StatusStore
+	blobStore   blobstore.BlobStore
+	hub         *events.Hub
+	// broadcast sends job events to the hubs of every gateway instance
+	broadcast func(ctx context.Context, event interface{}) error
+	quotas    *QuotaService
+	webhooks  *webhook.Dispatcher
 }
 
-func NewFaceService() *FaceService {
-	return &FaceService{
-		publisher: rabbitmq.GetPublisher(),
+func NewFaceService(quotas *QuotaService) *FaceService {
+	s := &FaceService{
+		statusStore: store.GetStatusStore(),
+		hub:         events.GetHub(),
+		broadcast:   rabbitmq.BroadcastJobEvent,
+		quotas:      quotas,
+	}
+
+	if config.AppConfig.Outbox.Enabled {
+		s.relay = outbox.GetRelay()
+		s.webhooks = webhook.GetDispatcher()
+		s.relay.OnDeadLetter(s.failDeadLetter)
+	} else {
+		s.publisher = rabbitmq.GetPublisher()
+	}
+
+	if config.AppConfig.Blob.Transport == TransportClaimCheck {
+		s.blobStore = blobstore.GetBlobStore()
 	}
+
+	return s
 }
 
-func (s *FaceService) ProcessImage(ctx context.Context, imageData models.ImageReceivedEventData) error {
+// ProcessImage queues an image for processing. The image bytes travel either
+// inline as base64 or, in claim-check mode, through the blob store with only
+// a reference in the published event.
+func (s *FaceService) ProcessImage(ctx context.Context, imageData models.ImageReceivedEventData, content []byte) error {
+	ctx, span := tracing.Tracer().Start(ctx, "FaceService.ProcessImage", trace.WithAttributes(
+		attribute.String("image.id", imageData.ImageID),
+		attribute.String("image.batch_id", imageData.BatchID),
+		attribute.Int("image.size", len(content)),
+	))
+
+	err := s.processImage(ctx, imageData, content)
+	tracing.EndSpan(span, err)
+	return err
+}
+
+func (s *FaceService) processImage(ctx context.Context, imageData models.ImageReceivedEventData, content []byte) error {
 	if imageData.ImageID == "" {
 		return fmt.Errorf("image ID is required")
 	}
 
-	if imageData.ImageData == "" {
+	if len(content) == 0 {
 		return fmt.Errorf("image data is required")
 	}
 
-	if err := s.publisher.PublishEventWithContext(
-		ctx,
-		rabbitmq.TopicImageReceived,
-		imageData,
-	); err != nil {
-		return fmt.Errorf("failed to publish image received event: %w", err)
+	checksum := sha256.Sum256(content)
+	imageData.Checksum = hex.EncodeToString(checksum[:])
+	imageData.FileSize = int64(len(content))
+
+	// Reserve quota up front; the reservation is released unless the image
+	// ends up queued
+	usage := &models.UsageRecord{
+		ImageID:   imageData.ImageID,
+		TenantID:  imageData.TenantID,
+		UserID:    imageData.UserID,
+		Bytes:     imageData.FileSize,
+		CreatedAt: time.Now().UTC(),
+	}
+	if err := s.quotas.Reserve(ctx, usage); err != nil {
+		return err
+	}
+
+	accepted := false
+	defer func() {
+		if !accepted {
+			s.quotas.Release(context.Background(), usage.ImageID)
+		}
+	}()
+
+	blobKey := ""
+	if s.blobStore != nil {
+		blobKey = "images/" + imageData.ImageID
+		ref, err := s.blobStore.Put(ctx, blobKey, bytes.NewReader(content), imageData.FileSize, imageData.MimeType)
+		if err != nil {
+			return fmt.Errorf("failed to store image: %w", err)
+		}
+		imageData.ImageRef = ref
+	} else {
+		imageData.ImageData = base64.StdEncoding.EncodeToString(content)
+	}
+
+	now := time.Now().UTC()
+	status := &models.JobStatus{
+		ImageID:     imageData.ImageID,
+		TenantID:    imageData.TenantID,
+		UserID:      imageData.UserID,
+		CallbackURL: imageData.CallbackURL,
+		Status:      models.JobStateQueued,
+		Message:     "Image received and queued for processing",
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+
+	// Record the job before publishing so that events coming back from the
+	// workers always find an existing status entry
+	if err := s.statusStore.Save(ctx, status); err != nil {
+		return fmt.Errorf("failed to record job status: %w", err)
+	}
+
+	if err := s.queueEvent(ctx, rabbitmq.TopicImageReceived, imageData.ImageID, imageData); err != nil {
+		s.failJob(ctx, status, "Failed to queue image for processing", err)
+		if blobKey != "" {
+			if delErr := s.blobStore.Delete(context.Background(), blobKey); delErr != nil {
+				logger.FromContext(ctx).Warnf("Failed to delete orphaned blob for %s: %v", imageData.ImageID, delErr)
+			}
+		}
+		return fmt.Errorf("failed to queue image received event: %w", err)
 	}
 
-	logrus.WithFields(logrus.Fields{
+	accepted = true
+	publishJobEvent(ctx, s.hub, s.broadcast, events.FromStatus(status))
+
+	logger.FromContext(ctx).WithFields(logrus.Fields{
 		"image_id":  imageData.ImageID,
 		"file_name": imageData.FileName,
 		"file_size": imageData.FileSize,
+		"image_ref": imageData.ImageRef,
 	}).Info("Image processing initiated")
 
 	return nil
 }
+
+// queueEvent adds the event to the outbox, or publishes it and waits for the
+// broker confirm when the outbox is disabled. The key identifies the entity
+// the event is about and deduplicates it in the outbox.
+func (s *FaceService) queueEvent(ctx context.Context, topic, key string, data interface{}) error {
+	if s.relay != nil {
+		_, err := s.relay.Enqueue(ctx, topic, key, data)
+		return err
+	}
+	return s.publisher.PublishEventWithContext(ctx, topic, data)
+}
+
+// failJob records a job that will not be processed and notifies subscribers
+func (s *FaceService) failJob(ctx context.Context, status *models.JobStatus, message string, err error) {
+	status.Status = models.JobStateFailed
+	status.Message = message
+	status.Error = err.Error()
+	status.UpdatedAt = time.Now().UTC()
+	status.CompletedAt = &status.UpdatedAt
+	if saveErr := s.statusStore.Save(context.Background(), status); saveErr != nil {
+		logger.FromContext(ctx).Errorf("Failed to record job failure for %s: %v", status.ImageID, saveErr)
+	}
+	publishJobEvent(ctx, s.hub, s.broadcast, events.FromStatus(status))
+}
+
+// failDeadLetter fails the job of an image.received event the outbox could
+// not publish. The client was already told the image was accepted, so the
+// failure is reported through the job status and its callback.
+func (s *FaceService) failDeadLetter(ctx context.Context, msg models.OutboxMessage, reason error) {
+	if msg.Topic != rabbitmq.TopicImageReceived {
+		return
+	}
+
+	var event struct {
+		Data models.ImageReceivedEventData `json:"data"`
+	}
+	if err := json.Unmarshal(msg.Payload, &event); err != nil || event.Data.ImageID == "" {
+		logger.FromContext(ctx).Errorf("Failed to decode dead-lettered event %s: %v", msg.EventID, err)
+		return
+	}
+	imageData := event.Data
+
+	status, err := s.statusStore.Get(ctx, imageData.ImageID)
+	if err != nil {
+		logger.FromContext(ctx).Errorf("Failed to get job status of %s: %v", imageData.ImageID, err)
+		return
+	}
+	if status.Status.IsTerminal() {
+		return
+	}
+
+	s.failJob(ctx, status, "Image could not be queued for processing", reason)
+	s.quotas.Release(ctx, imageData.ImageID)
+
+	if s.blobStore != nil && imageData.ImageRef != "" {
+		if err := s.blobStore.Delete(ctx, "images/"+imageData.ImageID); err != nil {
+			logger.FromContext(ctx).Warnf("Failed to delete orphaned blob for %s: %v", imageData.ImageID, err)
+		}
+	}
+
+	if status.CallbackURL != "" {
+		s.webhooks.Dispatch(status.CallbackURL, rabbitmq.TopicImageReceived, status, nil)
+	}
+}
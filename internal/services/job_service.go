@@ -0,0 +1,254 @@
+package services
+
+import (
+	"ai-image-microservice/api-gateway/internal/events"
+	"ai-image-microservice/api-gateway/internal/models"
+	"ai-image-microservice/api-gateway/internal/rabbitmq"
+	"ai-image-microservice/api-gateway/internal/store"
+	"ai-image-microservice/api-gateway/internal/webhook"
+	"ai-image-microservice/api-gateway/pkg/logger"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// JobService tracks job progress from the events emitted by the workers
+type JobService struct {
+	statusStore  store.StatusStore
+	resultStore  store.ResultStore
+	webhookStore store.WebhookStore
+	hub          *events.Hub
+	webhooks     *webhook.Dispatcher
+	// broadcast sends job events to the hubs of every gateway instance
+	broadcast func(ctx context.Context, event interface{}) error
+	// images serializes the read-modify-write cycles on the records of an
+	// image, since its events may be handled concurrently. Events of other
+	// images are handled in parallel.
+	images keyedMutex
+}
+
+func NewJobService() *JobService {
+	return &JobService{
+		statusStore:  store.GetStatusStore(),
+		resultStore:  store.GetResultStore(),
+		webhookStore: store.GetWebhookStore(),
+		hub:          events.GetHub(),
+		webhooks:     webhook.GetDispatcher(),
+		broadcast:    rabbitmq.BroadcastJobEvent,
+	}
+}
+
+// GetStatus returns the current status of an image, or store.ErrNotFound
+func (s *JobService) GetStatus(ctx context.Context, imageID string) (*models.JobStatus, error) {
+	return s.statusStore.Get(ctx, imageID)
+}
+
+// GetResults returns the recognition output of an image, or store.ErrNotFound
+func (s *JobService) GetResults(ctx context.Context, imageID string) (*models.ImageResults, error) {
+	return s.resultStore.Get(ctx, imageID)
+}
+
+// ListWebhookDeliveries returns the callback deliveries made for an image
+func (s *JobService) ListWebhookDeliveries(ctx context.Context, imageID string) ([]models.WebhookDelivery, error) {
+	return s.webhookStore.ListByImage(ctx, imageID)
+}
+
+// HandleFaceRecognition consumes face.recognition events
+func (s *JobService) HandleFaceRecognition(ctx context.Context, message []byte) error {
+	var data models.FaceRecognitionEventData
+	if err := decodeEventData(message, &data); err != nil {
+		return err
+	}
+
+	if err := s.updateResults(ctx, data.ImageID, func(results *models.ImageResults, now time.Time) {
+		results.FacesFound = data.FacesFound
+		results.ProcessingMs = data.ProcessingMs
+		results.Results = data.Results
+		results.RecognizedAt = &now
+	}); err != nil {
+		return err
+	}
+
+	status, err := s.transition(ctx, data.ImageID, data.TenantID, data.UserID, data, func(status *models.JobStatus) {
+		status.Status = models.JobStateProcessing
+		status.Message = fmt.Sprintf("Recognition finished with %d face(s) found, saving results", data.FacesFound)
+	})
+	if err != nil {
+		return err
+	}
+
+	s.notifyCallback(rabbitmq.TopicFaceRecognition, status, data)
+	return nil
+}
+
+// HandleDataSaved consumes data.saved events
+func (s *JobService) HandleDataSaved(ctx context.Context, message []byte) error {
+	var data models.DataSavedEventData
+	if err := decodeEventData(message, &data); err != nil {
+		return err
+	}
+
+	if data.Success {
+		if err := s.updateResults(ctx, data.ImageID, func(results *models.ImageResults, now time.Time) {
+			results.StorageURL = data.StorageURL
+			savedAt := data.SavedAt
+			if savedAt.IsZero() {
+				savedAt = now
+			}
+			results.SavedAt = &savedAt
+		}); err != nil {
+			return err
+		}
+	}
+
+	status, err := s.transition(ctx, data.ImageID, data.TenantID, data.UserID, data, func(status *models.JobStatus) {
+		if data.Success {
+			status.Status = models.JobStateCompleted
+			status.Message = "Image processed successfully"
+		} else {
+			status.Status = models.JobStateFailed
+			status.Message = "Failed to save processing results"
+			status.Error = data.Error
+		}
+	})
+	if err != nil {
+		return err
+	}
+
+	s.notifyCallback(rabbitmq.TopicDataSaved, status, data)
+	return nil
+}
+
+// HandleJobEvent consumes job events broadcast by any gateway instance and
+// passes them to the local subscribers
+func (s *JobService) HandleJobEvent(ctx context.Context, message []byte) error {
+	var event models.JobEvent
+	if err := decodeEventData(message, &event); err != nil {
+		return err
+	}
+	if event.ImageID == "" {
+		return rabbitmq.Permanent(fmt.Errorf("job event is missing image_id"))
+	}
+
+	s.hub.Publish(event)
+	return nil
+}
+
+// publishJobEvent sends the event to the subscribers of every gateway
+// instance through broadcast. When it cannot be broadcast, at least the
+// local subscribers get it.
+func publishJobEvent(ctx context.Context, hub *events.Hub, broadcast func(ctx context.Context, event interface{}) error, event models.JobEvent) {
+	if broadcast != nil {
+		err := broadcast(ctx, event)
+		if err == nil {
+			return
+		}
+		logger.FromContext(ctx).Warnf("Failed to broadcast job event for %s, notifying local subscribers only: %v", event.ImageID, err)
+	}
+	hub.Publish(event)
+}
+
+// notifyCallback sends the event to the job's callback URL, if it has one
+func (s *JobService) notifyCallback(event string, status *models.JobStatus, data interface{}) {
+	if status == nil || status.CallbackURL == "" {
+		return
+	}
+	s.webhooks.Dispatch(status.CallbackURL, event, status, data)
+}
+
+// transition applies a status change and notifies subscribers, attaching the
+// event payload that caused it. tenantID and userID are the owner the
+// workers echo back, used when the job is missing from the store. It returns
+// nil when the job had already reached a terminal state.
+func (s *JobService) transition(ctx context.Context, imageID, tenantID, userID string, data interface{}, apply func(status *models.JobStatus)) (*models.JobStatus, error) {
+	if imageID == "" {
+		return nil, rabbitmq.Permanent(fmt.Errorf("event is missing image_id"))
+	}
+
+	// The lock is held while the event is broadcast so that subscribers get
+	// the events of an image in order
+	unlock := s.images.Lock(imageID)
+	defer unlock()
+
+	now := time.Now().UTC()
+
+	status, err := s.statusStore.Get(ctx, imageID)
+	switch {
+	case errors.Is(err, store.ErrNotFound):
+		// Jobs submitted before a restart of an in-memory store still get tracked
+		status = &models.JobStatus{
+			ImageID:   imageID,
+			TenantID:  tenantID,
+			UserID:    userID,
+			CreatedAt: now,
+		}
+	case err != nil:
+		return nil, err
+	case status.Status.IsTerminal():
+		logger.FromContext(ctx).Debugf("Ignoring event for image %s already in terminal state %s", imageID, status.Status)
+		return nil, nil
+	}
+
+	apply(status)
+	status.UpdatedAt = now
+	if status.Status.IsTerminal() {
+		status.CompletedAt = &now
+	}
+
+	if err := s.statusStore.Save(ctx, status); err != nil {
+		return nil, err
+	}
+
+	event := events.FromStatus(status)
+	event.Data = data
+	publishJobEvent(ctx, s.hub, s.broadcast, event)
+
+	logger.FromContext(ctx).WithFields(logrus.Fields{
+		"image_id": imageID,
+		"status":   status.Status,
+	}).Info("Job status updated")
+
+	return status, nil
+}
+
+func (s *JobService) updateResults(ctx context.Context, imageID string, apply func(results *models.ImageResults, now time.Time)) error {
+	if imageID == "" {
+		return rabbitmq.Permanent(fmt.Errorf("event is missing image_id"))
+	}
+
+	unlock := s.images.Lock(imageID)
+	defer unlock()
+
+	now := time.Now().UTC()
+
+	results, err := s.resultStore.Get(ctx, imageID)
+	if errors.Is(err, store.ErrNotFound) {
+		results = &models.ImageResults{ImageID: imageID}
+	} else if err != nil {
+		return err
+	}
+
+	apply(results, now)
+	results.UpdatedAt = now
+
+	return s.resultStore.Save(ctx, results)
+}
+
+func decodeEventData(message []byte, out interface{}) error {
+	var event struct {
+		EventType string          `json:"event_type"`
+		Data      json.RawMessage `json:"data"`
+	}
+	if err := json.Unmarshal(message, &event); err != nil {
+		return rabbitmq.Permanent(fmt.Errorf("failed to unmarshal event: %w", err))
+	}
+
+	if err := json.Unmarshal(event.Data, out); err != nil {
+		return rabbitmq.Permanent(fmt.Errorf("failed to unmarshal %s event data: %w", event.EventType, err))
+	}
+	return nil
+}
@@ -0,0 +1,159 @@
+package services
+
+import (
+	"ai-image-microservice/api-gateway/internal/events"
+	"ai-image-microservice/api-gateway/internal/models"
+	"ai-image-microservice/api-gateway/internal/rabbitmq"
+	"ai-image-microservice/api-gateway/internal/store"
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func newTestJobService(broadcast func(ctx context.Context, event interface{}) error) *JobService {
+	return &JobService{
+		statusStore:  store.NewMemoryStatusStore(),
+		resultStore:  store.NewMemoryResultStore(),
+		webhookStore: store.NewMemoryWebhookStore(),
+		hub:          events.NewHub(4),
+		broadcast:    broadcast,
+	}
+}
+
+func encodeEvent(t *testing.T, topic string, data interface{}) []byte {
+	t.Helper()
+
+	message, err := rabbitmq.NewEvent(context.Background(), "event-1", topic, data)
+	if err != nil {
+		t.Fatalf("NewEvent: %v", err)
+	}
+	return message
+}
+
+func nextEvent(t *testing.T, sub *events.Subscription) (models.JobEvent, bool) {
+	t.Helper()
+
+	select {
+	case event, ok := <-sub.Events():
+		return event, ok
+	case <-time.After(100 * time.Millisecond):
+		return models.JobEvent{}, false
+	}
+}
+
+func TestJobServiceBroadcastsTransitions(t *testing.T) {
+	var broadcast []models.JobEvent
+	s := newTestJobService(func(ctx context.Context, event interface{}) error {
+		broadcast = append(broadcast, event.(models.JobEvent))
+		return nil
+	})
+	sub := s.hub.Subscribe(events.ImageKey("image-1"))
+
+	message := encodeEvent(t, rabbitmq.TopicDataSaved, models.DataSavedEventData{ImageID: "image-1", Success: true})
+	if err := s.HandleDataSaved(context.Background(), message); err != nil {
+		t.Fatalf("HandleDataSaved: %v", err)
+	}
+
+	if len(broadcast) != 1 || broadcast[0].Status != models.JobStateCompleted {
+		t.Fatalf("broadcast %+v, want one completed event", broadcast)
+	}
+	// Local subscribers get the event back through the fanout queue
+	if event, ok := nextEvent(t, sub); ok {
+		t.Fatalf("event %+v published locally as well as broadcast", event)
+	}
+}
+
+func TestJobServiceFallsBackToLocalSubscribers(t *testing.T) {
+	s := newTestJobService(func(ctx context.Context, event interface{}) error {
+		return errors.New("broker unavailable")
+	})
+	sub := s.hub.Subscribe(events.ImageKey("image-1"))
+
+	message := encodeEvent(t, rabbitmq.TopicDataSaved, models.DataSavedEventData{ImageID: "image-1", Success: true})
+	if err := s.HandleDataSaved(context.Background(), message); err != nil {
+		t.Fatalf("HandleDataSaved: %v", err)
+	}
+
+	event, ok := nextEvent(t, sub)
+	if !ok || event.Status != models.JobStateCompleted {
+		t.Fatalf("local event = %+v, %v; want the completed event", event, ok)
+	}
+}
+
+func TestJobServiceHandleJobEvent(t *testing.T) {
+	s := newTestJobService(nil)
+	sub := s.hub.Subscribe(events.UserKey("acme", "alice"))
+
+	message := encodeEvent(t, rabbitmq.TopicJobEvent, models.JobEvent{
+		Type:     models.JobEventSaved,
+		ImageID:  "image-1",
+		TenantID: "acme",
+		UserID:   "alice",
+		Status:   models.JobStateCompleted,
+	})
+	if err := s.HandleJobEvent(context.Background(), message); err != nil {
+		t.Fatalf("HandleJobEvent: %v", err)
+	}
+
+	event, ok := nextEvent(t, sub)
+	if !ok || event.ImageID != "image-1" || event.Status != models.JobStateCompleted {
+		t.Fatalf("event = %+v, %v; want the broadcast event", event, ok)
+	}
+
+	bad := encodeEvent(t, rabbitmq.TopicJobEvent, models.JobEvent{})
+	if err := s.HandleJobEvent(context.Background(), bad); !rabbitmq.IsPermanent(err) {
+		t.Fatalf("HandleJobEvent without image_id = %v, want a permanent error", err)
+	}
+}
+
+func TestJobServiceHandlesImagesInParallel(t *testing.T) {
+	release := make(chan struct{})
+	started := make(chan string, 2)
+	s := newTestJobService(func(ctx context.Context, event interface{}) error {
+		started <- event.(models.JobEvent).ImageID
+		<-release
+		return nil
+	})
+
+	done := make(chan error, 2)
+	for _, imageID := range []string{"image-1", "image-2"} {
+		message := encodeEvent(t, rabbitmq.TopicDataSaved, models.DataSavedEventData{ImageID: imageID, Success: true})
+		go func() { done <- s.HandleDataSaved(context.Background(), message) }()
+	}
+
+	// Both broadcasts are in flight at once: a slow confirm for one image
+	// does not hold back the events of another
+	for i := 0; i < 2; i++ {
+		select {
+		case <-started:
+		case <-time.After(time.Second):
+			t.Fatal("events of different images were not handled in parallel")
+		}
+	}
+	close(release)
+
+	for i := 0; i < 2; i++ {
+		if err := <-done; err != nil {
+			t.Fatalf("HandleDataSaved: %v", err)
+		}
+	}
+}
+
+func TestJobServiceKeepsOwnerOfUntrackedJobs(t *testing.T) {
+	s := newTestJobService(nil)
+	sub := s.hub.Subscribe(events.UserKey("acme", "alice"))
+
+	message := encodeEvent(t, rabbitmq.TopicFaceRecognition, models.FaceRecognitionEventData{ImageID: "image-1", TenantID: "acme", UserID: "alice"})
+	if err := s.HandleFaceRecognition(context.Background(), message); err != nil {
+		t.Fatalf("HandleFaceRecognition: %v", err)
+	}
+
+	status, err := s.GetStatus(context.Background(), "image-1")
+	if err != nil || status.TenantID != "acme" || status.UserID != "alice" {
+		t.Fatalf("status = %+v, %v; want the job of alice of acme", status, err)
+	}
+	if event, ok := nextEvent(t, sub); !ok || event.ImageID != "image-1" {
+		t.Fatalf("user event = %+v, %v; want the event of image-1", event, ok)
+	}
+}
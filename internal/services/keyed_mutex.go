@@ -0,0 +1,43 @@
+package services
+
+import "sync"
+
+// keyedMutex serializes work on one key, such as an image or a tenant,
+// without holding back work on the others. The zero value is ready to use.
+type keyedMutex struct {
+	mu    sync.Mutex
+	locks map[string]*keyLock
+}
+
+// keyLock is shared by the holders and waiters of a key and dropped once
+// none of them is left
+type keyLock struct {
+	mu   sync.Mutex
+	refs int
+}
+
+// Lock locks key and returns the function that unlocks it
+func (m *keyedMutex) Lock(key string) func() {
+	m.mu.Lock()
+	if m.locks == nil {
+		m.locks = make(map[string]*keyLock)
+	}
+	lock, ok := m.locks[key]
+	if !ok {
+		lock = &keyLock{}
+		m.locks[key] = lock
+	}
+	lock.refs++
+	m.mu.Unlock()
+
+	lock.mu.Lock()
+	return func() {
+		lock.mu.Unlock()
+
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		if lock.refs--; lock.refs == 0 {
+			delete(m.locks, key)
+		}
+	}
+}
@@ -0,0 +1,44 @@
+package services
+
+import (
+	"testing"
+	"time"
+)
+
+func TestKeyedMutex(t *testing.T) {
+	var m keyedMutex
+
+	unlock := m.Lock("a")
+
+	// Another key is not held back
+	other := make(chan struct{})
+	go func() {
+		m.Lock("b")()
+		close(other)
+	}()
+	select {
+	case <-other:
+	case <-time.After(time.Second):
+		t.Fatal("lock of another key blocked")
+	}
+
+	same := make(chan struct{})
+	go func() {
+		m.Lock("a")()
+		close(same)
+	}()
+	select {
+	case <-same:
+		t.Fatal("lock of a held key did not block")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	unlock()
+	<-same
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if len(m.locks) != 0 {
+		t.Fatalf("%d locks left after release, want none", len(m.locks))
+	}
+}
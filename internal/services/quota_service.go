@@ -0,0 +1,202 @@
+package services
+
+import (
+	"ai-image-microservice/api-gateway/internal/config"
+	"ai-image-microservice/api-gateway/internal/models"
+	"ai-image-microservice/api-gateway/internal/store"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// DefaultTenant accounts for the usage of unauthenticated requests
+const DefaultTenant = "default"
+
+// QuotaExceededError reports which quota a submission would exceed
+type QuotaExceededError struct {
+	Period   string
+	Resource string
+	Limit    int64
+	Used     int64
+	ResetsAt time.Time
+}
+
+func (e *QuotaExceededError) Error() string {
+	return fmt.Sprintf("%s %s quota exceeded (%d of %d used), resets at %s",
+		e.Period, e.Resource, e.Used, e.Limit, e.ResetsAt.Format(time.RFC3339))
+}
+
+type QuotaService struct {
+	usageStore store.UsageStore
+	enforced   bool
+	defaults   models.QuotaLimits
+	tenants    map[string]models.QuotaLimits
+
+	// tenantLocks serializes the check and the ledger write of the reservations
+	// of a tenant without holding back the others
+	tenantLocks keyedMutex
+}
+
+var quotaService *QuotaService
+
+// InitQuotaService builds the quota service with the configured limits
+func InitQuotaService() error {
+	s, err := NewQuotaService()
+	if err != nil {
+		return err
+	}
+
+	quotaService = s
+	logrus.Infof("Quota service initialized (enforced: %t, tenant overrides: %d)", s.enforced, len(s.tenants))
+	return nil
+}
+
+func GetQuotaService() *QuotaService {
+	if quotaService == nil {
+		if err := InitQuotaService(); err != nil {
+			logrus.Fatalf("Failed to initialize quota service: %v", err)
+		}
+	}
+	return quotaService
+}
+
+func NewQuotaService() (*QuotaService, error) {
+	cfg := config.AppConfig.Quota
+
+	s := &QuotaService{
+		usageStore: store.GetUsageStore(),
+		enforced:   cfg.Enabled,
+		defaults: models.QuotaLimits{
+			DailyImages:   cfg.DailyImages,
+			DailyBytes:    cfg.DailyBytes,
+			MonthlyImages: cfg.MonthlyImages,
+			MonthlyBytes:  cfg.MonthlyBytes,
+		},
+		tenants: make(map[string]models.QuotaLimits),
+	}
+
+	if cfg.TenantsFile != "" {
+		raw, err := os.ReadFile(cfg.TenantsFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read quota tenants file: %w", err)
+		}
+		if err := json.Unmarshal(raw, &s.tenants); err != nil {
+			return nil, fmt.Errorf("failed to parse quota tenants file: %w", err)
+		}
+	}
+
+	return s, nil
+}
+
+// TenantOf returns the tenant usage is accounted to
+func TenantOf(tenantID string) string {
+	if tenantID == "" {
+		return DefaultTenant
+	}
+	return tenantID
+}
+
+// Reserve records an image in the usage ledger, or returns a
+// *QuotaExceededError when quotas are enforced and it would exceed one.
+// Reserving an image already in the ledger, such as a retried submission
+// with the same Idempotency-Key, succeeds without counting it again.
+func (s *QuotaService) Reserve(ctx context.Context, record *models.UsageRecord) error {
+	record.TenantID = TenantOf(record.TenantID)
+
+	unlock := s.tenantLocks.Lock(record.TenantID)
+	defer unlock()
+
+	_, err := s.usageStore.Get(ctx, record.ImageID)
+	if err == nil {
+		return nil
+	}
+	if !errors.Is(err, store.ErrNotFound) {
+		return fmt.Errorf("failed to get usage of %s: %w", record.ImageID, err)
+	}
+
+	if s.enforced {
+		periods, err := s.periods(ctx, record.TenantID, record.CreatedAt)
+		if err != nil {
+			return err
+		}
+
+		for _, p := range periods {
+			if p.ImageLimit > 0 && p.Images+1 > p.ImageLimit {
+				return &QuotaExceededError{Period: p.Period, Resource: "image", Limit: p.ImageLimit, Used: p.Images, ResetsAt: p.ResetsAt}
+			}
+			if p.BytesLimit > 0 && p.Bytes+record.Bytes > p.BytesLimit {
+				return &QuotaExceededError{Period: p.Period, Resource: "byte", Limit: p.BytesLimit, Used: p.Bytes, ResetsAt: p.ResetsAt}
+			}
+		}
+	}
+
+	return s.usageStore.Record(ctx, record)
+}
+
+// Release removes the reservation of an image that was not accepted after
+// all
+func (s *QuotaService) Release(ctx context.Context, imageID string) {
+	if err := s.usageStore.Delete(ctx, imageID); err != nil && !errors.Is(err, store.ErrNotFound) {
+		logrus.Errorf("Failed to release usage of %s: %v", imageID, err)
+	}
+}
+
+// Report returns the current consumption of a tenant against its limits
+func (s *QuotaService) Report(ctx context.Context, tenantID string) (*models.UsageReport, error) {
+	tenantID = TenantOf(tenantID)
+
+	periods, err := s.periods(ctx, tenantID, time.Now().UTC())
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.UsageReport{
+		TenantID: tenantID,
+		Enforced: s.enforced,
+		Periods:  periods,
+	}, nil
+}
+
+func (s *QuotaService) periods(ctx context.Context, tenantID string, now time.Time) ([]models.UsagePeriod, error) {
+	limits, ok := s.tenants[tenantID]
+	if !ok {
+		limits = s.defaults
+	}
+
+	now = now.UTC()
+	dayStart := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	monthStart := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+
+	periods := []models.UsagePeriod{
+		{
+			Period:     models.UsagePeriodDaily,
+			Start:      dayStart,
+			ResetsAt:   dayStart.AddDate(0, 0, 1),
+			ImageLimit: limits.DailyImages,
+			BytesLimit: limits.DailyBytes,
+		},
+		{
+			Period:     models.UsagePeriodMonthly,
+			Start:      monthStart,
+			ResetsAt:   monthStart.AddDate(0, 1, 0),
+			ImageLimit: limits.MonthlyImages,
+			BytesLimit: limits.MonthlyBytes,
+		},
+	}
+
+	for i := range periods {
+		totals, err := s.usageStore.Totals(ctx, tenantID, periods[i].Start)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get %s usage: %w", periods[i].Period, err)
+		}
+		periods[i].Images = totals.Images
+		periods[i].Bytes = totals.Bytes
+	}
+
+	return periods, nil
+}
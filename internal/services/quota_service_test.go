@@ -0,0 +1,152 @@
+package services
+
+import (
+	"ai-image-microservice/api-gateway/internal/config"
+	"ai-image-microservice/api-gateway/internal/models"
+	"ai-image-microservice/api-gateway/internal/store"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func newTestQuotaService(defaults models.QuotaLimits, tenants map[string]models.QuotaLimits) *QuotaService {
+	if tenants == nil {
+		tenants = make(map[string]models.QuotaLimits)
+	}
+	return &QuotaService{
+		usageStore: store.NewMemoryUsageStore(),
+		enforced:   true,
+		defaults:   defaults,
+		tenants:    tenants,
+	}
+}
+
+func usage(imageID, tenantID string, bytes int64) *models.UsageRecord {
+	return &models.UsageRecord{ImageID: imageID, TenantID: tenantID, Bytes: bytes, CreatedAt: time.Now().UTC()}
+}
+
+func TestQuotaReserveEnforcesLimits(t *testing.T) {
+	ctx := context.Background()
+	s := newTestQuotaService(models.QuotaLimits{DailyImages: 2, MonthlyBytes: 1000}, map[string]models.QuotaLimits{
+		"big": {DailyImages: 10},
+	})
+
+	for i := 0; i < 2; i++ {
+		if err := s.Reserve(ctx, usage(fmt.Sprint("a", i), "acme", 100)); err != nil {
+			t.Fatalf("Reserve %d: %v", i, err)
+		}
+	}
+
+	var exceeded *QuotaExceededError
+	err := s.Reserve(ctx, usage("a2", "acme", 100))
+	if !errors.As(err, &exceeded) || exceeded.Period != models.UsagePeriodDaily || exceeded.Resource != "image" {
+		t.Fatalf("third Reserve = %v, want daily image quota exceeded", err)
+	}
+
+	if err := s.Reserve(ctx, usage("b0", "other", 1001)); !errors.As(err, &exceeded) || exceeded.Resource != "byte" {
+		t.Fatalf("oversized Reserve = %v, want byte quota exceeded", err)
+	}
+
+	// Per-tenant overrides replace the defaults
+	for i := 0; i < 3; i++ {
+		if err := s.Reserve(ctx, usage(fmt.Sprint("c", i), "big", 100)); err != nil {
+			t.Fatalf("Reserve for overridden tenant: %v", err)
+		}
+	}
+}
+
+func TestQuotaReleaseFreesReservation(t *testing.T) {
+	ctx := context.Background()
+	s := newTestQuotaService(models.QuotaLimits{DailyImages: 1}, nil)
+
+	first := usage("a", "", 10)
+	if err := s.Reserve(ctx, first); err != nil {
+		t.Fatalf("Reserve: %v", err)
+	}
+	if first.TenantID != DefaultTenant {
+		t.Fatalf("TenantID = %q, want %q", first.TenantID, DefaultTenant)
+	}
+	if err := s.Reserve(ctx, usage("b", "", 10)); err == nil {
+		t.Fatal("Reserve past the quota succeeded")
+	}
+
+	s.Release(ctx, first.ImageID)
+	if err := s.Reserve(ctx, usage("b", "", 10)); err != nil {
+		t.Fatalf("Reserve after Release: %v", err)
+	}
+}
+
+func TestQuotaReserveConcurrently(t *testing.T) {
+	ctx := context.Background()
+	s := newTestQuotaService(models.QuotaLimits{DailyImages: 5}, nil)
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	accepted := map[string]int{}
+	for _, tenant := range []string{"acme", "other"} {
+		for i := 0; i < 20; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if err := s.Reserve(ctx, usage(fmt.Sprint(tenant, i), tenant, 1)); err == nil {
+					mu.Lock()
+					accepted[tenant]++
+					mu.Unlock()
+				}
+			}()
+		}
+	}
+	wg.Wait()
+
+	for _, tenant := range []string{"acme", "other"} {
+		if accepted[tenant] != 5 {
+			t.Fatalf("accepted %d reservations for %s, want 5", accepted[tenant], tenant)
+		}
+	}
+	if len(s.tenantLocks.locks) != 0 {
+		t.Fatalf("tenant locks left behind: %v", s.tenantLocks.locks)
+	}
+}
+
+func TestQuotaReserveIsIdempotent(t *testing.T) {
+	ctx := context.Background()
+	s := newTestQuotaService(models.QuotaLimits{DailyImages: 1}, nil)
+
+	// A retried submission reserves the same image ID again
+	for i := 0; i < 2; i++ {
+		if err := s.Reserve(ctx, usage("a", "acme", 10)); err != nil {
+			t.Fatalf("Reserve %d: %v", i, err)
+		}
+	}
+
+	report, err := s.Report(ctx, "acme")
+	if err != nil {
+		t.Fatalf("Report: %v", err)
+	}
+	if used := report.Periods[0].Images; used != 1 {
+		t.Fatalf("daily images used = %d, want 1", used)
+	}
+}
+
+func TestNewQuotaServiceReportsBadTenantsFile(t *testing.T) {
+	dir := t.TempDir()
+	invalid := filepath.Join(dir, "tenants.json")
+	if err := os.WriteFile(invalid, []byte("{not json"), 0o600); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+
+	for _, path := range []string{filepath.Join(dir, "missing.json"), invalid} {
+		config.AppConfig = &config.Config{
+			Store: config.StoreConfig{Driver: store.DriverMemory},
+			Quota: config.QuotaConfig{Enabled: true, TenantsFile: path},
+		}
+		if _, err := NewQuotaService(); err == nil {
+			t.Fatalf("NewQuotaService with %s succeeded, want an error", filepath.Base(path))
+		}
+	}
+}
@@ -0,0 +1,142 @@
+package store
+
+import (
+	"ai-image-microservice/api-gateway/internal/models"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// SQLiteAPIKeyStore keeps hashed API keys. It is used whenever API keys are
+// managed in the database, independently of the configured store driver.
+type SQLiteAPIKeyStore struct {
+	db *sql.DB
+}
+
+func NewSQLiteAPIKeyStore() (*SQLiteAPIKeyStore, error) {
+	db, err := getDB()
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = db.Exec(`
+		CREATE TABLE IF NOT EXISTS api_keys (
+			id         TEXT PRIMARY KEY,
+			key_hash   TEXT NOT NULL UNIQUE,
+			tenant_id  TEXT NOT NULL,
+			user_id    TEXT NOT NULL DEFAULT '',
+			name       TEXT NOT NULL DEFAULT '',
+			created_at TIMESTAMP NOT NULL,
+			revoked_at TIMESTAMP
+		)`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create api_keys table: %w", err)
+	}
+
+	return &SQLiteAPIKeyStore{db: db}, nil
+}
+
+func (s *SQLiteAPIKeyStore) Create(ctx context.Context, key *models.APIKey) error {
+	_, err := s.db.ExecContext(ctx, `
+		INSERT INTO api_keys (id, key_hash, tenant_id, user_id, name, created_at)
+		VALUES (?, ?, ?, ?, ?, ?)`,
+		key.ID,
+		key.KeyHash,
+		key.TenantID,
+		key.UserID,
+		key.Name,
+		key.CreatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create api key: %w", err)
+	}
+	return nil
+}
+
+// GetByHash returns the key with the given hash, including revoked keys
+func (s *SQLiteAPIKeyStore) GetByHash(ctx context.Context, keyHash string) (*models.APIKey, error) {
+	return s.get(ctx, "key_hash", keyHash)
+}
+
+func (s *SQLiteAPIKeyStore) Get(ctx context.Context, id string) (*models.APIKey, error) {
+	return s.get(ctx, "id", id)
+}
+
+func (s *SQLiteAPIKeyStore) List(ctx context.Context) ([]models.APIKey, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT id, key_hash, tenant_id, user_id, name, created_at, revoked_at
+		FROM api_keys ORDER BY created_at`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list api keys: %w", err)
+	}
+	defer rows.Close()
+
+	keys := []models.APIKey{}
+	for rows.Next() {
+		key, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, *key)
+	}
+	return keys, rows.Err()
+}
+
+func (s *SQLiteAPIKeyStore) Revoke(ctx context.Context, id string) error {
+	result, err := s.db.ExecContext(ctx, `
+		UPDATE api_keys SET revoked_at = ? WHERE id = ? AND revoked_at IS NULL`,
+		time.Now().UTC(), id)
+	if err != nil {
+		return fmt.Errorf("failed to revoke api key: %w", err)
+	}
+
+	if n, _ := result.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (s *SQLiteAPIKeyStore) get(ctx context.Context, column, value string) (*models.APIKey, error) {
+	row := s.db.QueryRowContext(ctx, `
+		SELECT id, key_hash, tenant_id, user_id, name, created_at, revoked_at
+		FROM api_keys WHERE `+column+` = ?`, value)
+
+	key, err := scanAPIKey(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	return key, err
+}
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanAPIKey(row rowScanner) (*models.APIKey, error) {
+	var (
+		key       models.APIKey
+		revokedAt sql.NullTime
+	)
+
+	if err := row.Scan(
+		&key.ID,
+		&key.KeyHash,
+		&key.TenantID,
+		&key.UserID,
+		&key.Name,
+		&key.CreatedAt,
+		&revokedAt,
+	); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to scan api key: %w", err)
+	}
+
+	if revokedAt.Valid {
+		key.RevokedAt = &revokedAt.Time
+	}
+	return &key, nil
+}
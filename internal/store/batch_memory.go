@@ -0,0 +1,39 @@
+package store
+
+import (
+	"ai-image-microservice/api-gateway/internal/models"
+	"context"
+	"sync"
+)
+
+type MemoryBatchStore struct {
+	mu      sync.RWMutex
+	batches map[string]models.Batch
+}
+
+func NewMemoryBatchStore() *MemoryBatchStore {
+	return &MemoryBatchStore{
+		batches: make(map[string]models.Batch),
+	}
+}
+
+func (s *MemoryBatchStore) Save(ctx context.Context, batch *models.Batch) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	saved := *batch
+	saved.Items = append([]models.BatchItem(nil), batch.Items...)
+	s.batches[batch.ID] = saved
+	return nil
+}
+
+func (s *MemoryBatchStore) Get(ctx context.Context, batchID string) (*models.Batch, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	batch, ok := s.batches[batchID]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &batch, nil
+}
@@ -0,0 +1,93 @@
+package store
+
+import (
+	"ai-image-microservice/api-gateway/internal/models"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+type SQLiteBatchStore struct {
+	db *sql.DB
+}
+
+func NewSQLiteBatchStore() (*SQLiteBatchStore, error) {
+	db, err := getDB()
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = db.Exec(`
+		CREATE TABLE IF NOT EXISTS batches (
+			id         TEXT PRIMARY KEY,
+			tenant_id  TEXT NOT NULL DEFAULT '',
+			user_id    TEXT NOT NULL DEFAULT '',
+			items      TEXT NOT NULL DEFAULT '[]',
+			created_at TIMESTAMP NOT NULL
+		)`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create batches table: %w", err)
+	}
+
+	// Columns added after the table was first released
+	_, err = db.Exec(`ALTER TABLE batches ADD COLUMN tenant_id TEXT NOT NULL DEFAULT ''`)
+	if err != nil && !strings.Contains(err.Error(), "duplicate column") {
+		return nil, fmt.Errorf("failed to migrate batches table: %w", err)
+	}
+
+	return &SQLiteBatchStore{db: db}, nil
+}
+
+func (s *SQLiteBatchStore) Save(ctx context.Context, batch *models.Batch) error {
+	items, err := json.Marshal(batch.Items)
+	if err != nil {
+		return fmt.Errorf("failed to marshal batch items: %w", err)
+	}
+
+	_, err = s.db.ExecContext(ctx, `
+		INSERT INTO batches (id, tenant_id, user_id, items, created_at)
+		VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT(id) DO UPDATE SET
+			items = excluded.items`,
+		batch.ID,
+		batch.TenantID,
+		batch.UserID,
+		string(items),
+		batch.CreatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to save batch: %w", err)
+	}
+	return nil
+}
+
+func (s *SQLiteBatchStore) Get(ctx context.Context, batchID string) (*models.Batch, error) {
+	var (
+		batch models.Batch
+		items string
+	)
+
+	err := s.db.QueryRowContext(ctx, `
+		SELECT id, tenant_id, user_id, items, created_at
+		FROM batches WHERE id = ?`, batchID).Scan(
+		&batch.ID,
+		&batch.TenantID,
+		&batch.UserID,
+		&items,
+		&batch.CreatedAt,
+	)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get batch: %w", err)
+	}
+
+	if err := json.Unmarshal([]byte(items), &batch.Items); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal batch items: %w", err)
+	}
+	return &batch, nil
+}
@@ -0,0 +1,45 @@
+package store
+
+import (
+	"ai-image-microservice/api-gateway/internal/config"
+	"ai-image-microservice/api-gateway/internal/models"
+	"context"
+	"fmt"
+
+	"github.com/sirupsen/logrus"
+)
+
+// BatchStore persists batches and the outcome of each of their items
+type BatchStore interface {
+	Save(ctx context.Context, batch *models.Batch) error
+	Get(ctx context.Context, batchID string) (*models.Batch, error)
+}
+
+var batchStore BatchStore
+
+func InitBatchStore() error {
+	switch config.AppConfig.Store.Driver {
+	case DriverMemory:
+		batchStore = NewMemoryBatchStore()
+	case DriverSQLite:
+		s, err := NewSQLiteBatchStore()
+		if err != nil {
+			return err
+		}
+		batchStore = s
+	default:
+		return fmt.Errorf("unsupported store driver: %s", config.AppConfig.Store.Driver)
+	}
+
+	logrus.Infof("Batch store initialized (%s)", config.AppConfig.Store.Driver)
+	return nil
+}
+
+func GetBatchStore() BatchStore {
+	if batchStore == nil {
+		if err := InitBatchStore(); err != nil {
+			logrus.Fatalf("Failed to initialize batch store: %v", err)
+		}
+	}
+	return batchStore
+}
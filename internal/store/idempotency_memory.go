@@ -0,0 +1,96 @@
+package store
+
+import (
+	"ai-image-microservice/api-gateway/internal/models"
+	"container/heap"
+	"context"
+	"sync"
+	"time"
+)
+
+// MemoryIdempotencyStore keeps records in memory. Expired records are pruned
+// in expiry order from a heap, so a call only touches the records that
+// expired since the previous one.
+type MemoryIdempotencyStore struct {
+	mu      sync.Mutex
+	records map[string]models.IdempotencyRecord
+	expiry  expiryHeap
+}
+
+// expiryEntry schedules a key for pruning. Entries are not updated when a
+// record is completed or released; an entry whose record now expires later,
+// or no longer exists, is skipped when it comes up.
+type expiryEntry struct {
+	key       string
+	expiresAt time.Time
+}
+
+type expiryHeap []expiryEntry
+
+func (h expiryHeap) Len() int            { return len(h) }
+func (h expiryHeap) Less(i, j int) bool  { return h[i].expiresAt.Before(h[j].expiresAt) }
+func (h expiryHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *expiryHeap) Push(x interface{}) { *h = append(*h, x.(expiryEntry)) }
+func (h *expiryHeap) Pop() interface{} {
+	old := *h
+	entry := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return entry
+}
+
+func NewMemoryIdempotencyStore() *MemoryIdempotencyStore {
+	return &MemoryIdempotencyStore{
+		records: make(map[string]models.IdempotencyRecord),
+	}
+}
+
+func (s *MemoryIdempotencyStore) Reserve(ctx context.Context, record *models.IdempotencyRecord) (*models.IdempotencyRecord, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.prune(time.Now())
+
+	if existing, ok := s.records[record.Key]; ok {
+		return &existing, false, nil
+	}
+
+	s.records[record.Key] = *record
+	heap.Push(&s.expiry, expiryEntry{key: record.Key, expiresAt: record.ExpiresAt})
+	return record, true, nil
+}
+
+func (s *MemoryIdempotencyStore) Complete(ctx context.Context, key string, statusCode int, response []byte, expiresAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	record, ok := s.records[key]
+	if !ok {
+		return ErrNotFound
+	}
+
+	record.Completed = true
+	record.StatusCode = statusCode
+	record.Response = append([]byte(nil), response...)
+	record.ExpiresAt = expiresAt
+	s.records[key] = record
+	heap.Push(&s.expiry, expiryEntry{key: key, expiresAt: expiresAt})
+	return nil
+}
+
+func (s *MemoryIdempotencyStore) Release(ctx context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.records, key)
+	return nil
+}
+
+// prune drops the records expired at now. The caller holds s.mu.
+func (s *MemoryIdempotencyStore) prune(now time.Time) {
+	for len(s.expiry) > 0 && now.After(s.expiry[0].expiresAt) {
+		entry := heap.Pop(&s.expiry).(expiryEntry)
+		if record, ok := s.records[entry.key]; ok && now.After(record.ExpiresAt) {
+			delete(s.records, entry.key)
+		}
+	}
+}
@@ -0,0 +1,52 @@
+package store
+
+import (
+	"ai-image-microservice/api-gateway/internal/models"
+	"context"
+	"testing"
+	"time"
+)
+
+func TestMemoryIdempotencyPrunesExpiredRecords(t *testing.T) {
+	ctx := context.Background()
+	s := NewMemoryIdempotencyStore()
+	now := time.Now()
+
+	s.Reserve(ctx, &models.IdempotencyRecord{Key: "expired", ExpiresAt: now.Add(-time.Minute)})
+	s.Reserve(ctx, &models.IdempotencyRecord{Key: "completed", ExpiresAt: now.Add(-time.Second)})
+	// Completing extends the record past its reservation
+	if err := s.Complete(ctx, "completed", 201, []byte("{}"), now.Add(time.Hour)); err != nil {
+		t.Fatalf("Complete: %v", err)
+	}
+
+	_, claimed, err := s.Reserve(ctx, &models.IdempotencyRecord{Key: "expired", ExpiresAt: now.Add(time.Hour)})
+	if err != nil || !claimed {
+		t.Fatalf("Reserve of an expired key = %v, %v; want it claimed", claimed, err)
+	}
+
+	existing, claimed, _ := s.Reserve(ctx, &models.IdempotencyRecord{Key: "completed", ExpiresAt: now.Add(time.Hour)})
+	if claimed || !existing.Completed {
+		t.Fatalf("Reserve of a completed key claimed it, want the stored response")
+	}
+
+	if len(s.records) != 2 {
+		t.Fatalf("%d records kept, want 2", len(s.records))
+	}
+	// Only the entries of unexpired records are left to prune
+	if len(s.expiry) != 2 {
+		t.Fatalf("%d expiry entries left, want 2", len(s.expiry))
+	}
+}
+
+func TestMemoryIdempotencyRelease(t *testing.T) {
+	ctx := context.Background()
+	s := NewMemoryIdempotencyStore()
+	record := &models.IdempotencyRecord{Key: "a", ExpiresAt: time.Now().Add(time.Hour)}
+
+	s.Reserve(ctx, record)
+	s.Release(ctx, "a")
+
+	if _, claimed, _ := s.Reserve(ctx, record); !claimed {
+		t.Fatal("Reserve after Release did not claim the key")
+	}
+}
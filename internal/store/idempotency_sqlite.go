@@ -0,0 +1,111 @@
+package store
+
+import (
+	"ai-image-microservice/api-gateway/internal/models"
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+type SQLiteIdempotencyStore struct {
+	db *sql.DB
+}
+
+func NewSQLiteIdempotencyStore() (*SQLiteIdempotencyStore, error) {
+	db, err := getDB()
+	if err != nil {
+		return nil, err
+	}
+
+	// Times are Unix milliseconds so expiry compares numerically
+	_, err = db.Exec(`
+		CREATE TABLE IF NOT EXISTS idempotency_keys (
+			key         TEXT PRIMARY KEY,
+			fingerprint TEXT NOT NULL,
+			completed   INTEGER NOT NULL DEFAULT 0,
+			status_code INTEGER NOT NULL DEFAULT 0,
+			response    BLOB,
+			created_at  INTEGER NOT NULL,
+			expires_at  INTEGER NOT NULL
+		);
+		CREATE INDEX IF NOT EXISTS idx_idempotency_keys_expires ON idempotency_keys (expires_at)`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create idempotency_keys table: %w", err)
+	}
+
+	return &SQLiteIdempotencyStore{db: db}, nil
+}
+
+func (s *SQLiteIdempotencyStore) Reserve(ctx context.Context, record *models.IdempotencyRecord) (*models.IdempotencyRecord, bool, error) {
+	now := time.Now().UnixMilli()
+
+	if _, err := s.db.ExecContext(ctx, `DELETE FROM idempotency_keys WHERE expires_at < ?`, now); err != nil {
+		return nil, false, fmt.Errorf("failed to prune idempotency keys: %w", err)
+	}
+
+	result, err := s.db.ExecContext(ctx, `
+		INSERT INTO idempotency_keys (key, fingerprint, created_at, expires_at)
+		VALUES (?, ?, ?, ?)
+		ON CONFLICT(key) DO NOTHING`,
+		record.Key,
+		record.Fingerprint,
+		record.CreatedAt.UnixMilli(),
+		record.ExpiresAt.UnixMilli(),
+	)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to reserve idempotency key: %w", err)
+	}
+
+	if n, _ := result.RowsAffected(); n == 1 {
+		return record, true, nil
+	}
+
+	var (
+		existing  models.IdempotencyRecord
+		createdAt int64
+		expiresAt int64
+	)
+	err = s.db.QueryRowContext(ctx, `
+		SELECT key, fingerprint, completed, status_code, response, created_at, expires_at
+		FROM idempotency_keys WHERE key = ?`, record.Key,
+	).Scan(
+		&existing.Key,
+		&existing.Fingerprint,
+		&existing.Completed,
+		&existing.StatusCode,
+		&existing.Response,
+		&createdAt,
+		&expiresAt,
+	)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to get idempotency key: %w", err)
+	}
+
+	existing.CreatedAt = time.UnixMilli(createdAt).UTC()
+	existing.ExpiresAt = time.UnixMilli(expiresAt).UTC()
+	return &existing, false, nil
+}
+
+func (s *SQLiteIdempotencyStore) Complete(ctx context.Context, key string, statusCode int, response []byte, expiresAt time.Time) error {
+	result, err := s.db.ExecContext(ctx, `
+		UPDATE idempotency_keys
+		SET completed = 1, status_code = ?, response = ?, expires_at = ?
+		WHERE key = ?`,
+		statusCode, response, expiresAt.UnixMilli(), key)
+	if err != nil {
+		return fmt.Errorf("failed to complete idempotency key: %w", err)
+	}
+
+	if n, _ := result.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (s *SQLiteIdempotencyStore) Release(ctx context.Context, key string) error {
+	if _, err := s.db.ExecContext(ctx, `DELETE FROM idempotency_keys WHERE key = ?`, key); err != nil {
+		return fmt.Errorf("failed to release idempotency key: %w", err)
+	}
+	return nil
+}
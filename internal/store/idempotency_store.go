@@ -0,0 +1,53 @@
+package store
+
+import (
+	"ai-image-microservice/api-gateway/internal/config"
+	"ai-image-microservice/api-gateway/internal/models"
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// IdempotencyStore keeps the responses of requests made with an
+// Idempotency-Key until they expire
+type IdempotencyStore interface {
+	// Reserve claims the record's key for a request in progress. If an
+	// unexpired record already holds the key, it is returned instead and
+	// the claim fails.
+	Reserve(ctx context.Context, record *models.IdempotencyRecord) (*models.IdempotencyRecord, bool, error)
+	// Complete stores the response of a reserved key, kept until expiresAt
+	Complete(ctx context.Context, key string, statusCode int, response []byte, expiresAt time.Time) error
+	// Release drops a reservation so the request can be retried
+	Release(ctx context.Context, key string) error
+}
+
+var idempotencyStore IdempotencyStore
+
+func InitIdempotencyStore() error {
+	switch config.AppConfig.Store.Driver {
+	case DriverMemory:
+		idempotencyStore = NewMemoryIdempotencyStore()
+	case DriverSQLite:
+		s, err := NewSQLiteIdempotencyStore()
+		if err != nil {
+			return err
+		}
+		idempotencyStore = s
+	default:
+		return fmt.Errorf("unsupported store driver: %s", config.AppConfig.Store.Driver)
+	}
+
+	logrus.Infof("Idempotency store initialized (%s)", config.AppConfig.Store.Driver)
+	return nil
+}
+
+func GetIdempotencyStore() IdempotencyStore {
+	if idempotencyStore == nil {
+		if err := InitIdempotencyStore(); err != nil {
+			logrus.Fatalf("Failed to initialize idempotency store: %v", err)
+		}
+	}
+	return idempotencyStore
+}
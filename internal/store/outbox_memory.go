@@ -0,0 +1,141 @@
+package store
+
+import (
+	"ai-image-microservice/api-gateway/internal/models"
+	"container/list"
+	"context"
+	"sync"
+	"time"
+)
+
+// MemoryOutboxStore holds up to maxPending unsent messages, so an outage
+// cannot grow memory without bound. Zero means unlimited.
+type MemoryOutboxStore struct {
+	mu sync.Mutex
+	// messages indexes every message by seq, sent and dead-lettered ones
+	// included until they are pruned
+	messages map[int64]*models.OutboxMessage
+	// pending holds the unsent messages in seq order, and pendingBySeq their
+	// elements so they can be taken out when sent
+	pending      *list.List
+	pendingBySeq map[int64]*list.Element
+	events       map[string]bool
+	nextSeq      int64
+	maxPending   int
+}
+
+func NewMemoryOutboxStore(maxPending int) *MemoryOutboxStore {
+	return &MemoryOutboxStore{
+		messages:     make(map[int64]*models.OutboxMessage),
+		pending:      list.New(),
+		pendingBySeq: make(map[int64]*list.Element),
+		events:       make(map[string]bool),
+		nextSeq:      1,
+		maxPending:   maxPending,
+	}
+}
+
+func (s *MemoryOutboxStore) Add(ctx context.Context, msg *models.OutboxMessage) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.events[msg.EventID] {
+		return nil
+	}
+	if s.maxPending > 0 && s.pending.Len() >= s.maxPending {
+		return ErrOutboxFull
+	}
+
+	stored := *msg
+	stored.Seq = s.nextSeq
+	s.nextSeq++
+
+	s.messages[stored.Seq] = &stored
+	s.pendingBySeq[stored.Seq] = s.pending.PushBack(&stored)
+	s.events[msg.EventID] = true
+	msg.Seq = stored.Seq
+	return nil
+}
+
+func (s *MemoryOutboxStore) Pending(ctx context.Context, limit int) ([]models.OutboxMessage, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	pending := []models.OutboxMessage{}
+	for e := s.pending.Front(); e != nil && len(pending) != limit; e = e.Next() {
+		pending = append(pending, *e.Value.(*models.OutboxMessage))
+	}
+	return pending, nil
+}
+
+func (s *MemoryOutboxStore) CountPending(ctx context.Context) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.pending.Len(), nil
+}
+
+func (s *MemoryOutboxStore) MarkSent(ctx context.Context, seq int64, sentAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	msg, ok := s.messages[seq]
+	if !ok {
+		return ErrNotFound
+	}
+	s.removePending(seq)
+	msg.SentAt = &sentAt
+	return nil
+}
+
+func (s *MemoryOutboxStore) RecordFailure(ctx context.Context, seq int64, errMsg string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	msg, ok := s.messages[seq]
+	if !ok {
+		return ErrNotFound
+	}
+	msg.Attempts++
+	msg.LastError = errMsg
+	return nil
+}
+
+func (s *MemoryOutboxStore) MarkFailed(ctx context.Context, seq int64, errMsg string, failedAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	msg, ok := s.messages[seq]
+	if !ok {
+		return ErrNotFound
+	}
+	s.removePending(seq)
+	msg.FailedAt = &failedAt
+	msg.LastError = errMsg
+	return nil
+}
+
+func (s *MemoryOutboxStore) Prune(ctx context.Context, before time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for seq, msg := range s.messages {
+		done := msg.SentAt
+		if done == nil {
+			done = msg.FailedAt
+		}
+		if done != nil && done.Before(before) {
+			delete(s.messages, seq)
+			delete(s.events, msg.EventID)
+		}
+	}
+	return nil
+}
+
+// removePending takes the message out of the pending list, if it is there
+func (s *MemoryOutboxStore) removePending(seq int64) {
+	if e, ok := s.pendingBySeq[seq]; ok {
+		s.pending.Remove(e)
+		delete(s.pendingBySeq, seq)
+	}
+}
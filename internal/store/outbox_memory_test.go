@@ -0,0 +1,134 @@
+package store
+
+import (
+	"ai-image-microservice/api-gateway/internal/models"
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func addOutboxMessage(t *testing.T, s OutboxStore, eventID string) *models.OutboxMessage {
+	t.Helper()
+
+	msg := &models.OutboxMessage{
+		EventID:   eventID,
+		Topic:     "image.received",
+		Payload:   []byte(`{}`),
+		CreatedAt: time.Now().UTC(),
+	}
+	if err := s.Add(context.Background(), msg); err != nil {
+		t.Fatalf("Add(%s): %v", eventID, err)
+	}
+	return msg
+}
+
+func TestMemoryOutboxOrderAndDedup(t *testing.T) {
+	ctx := context.Background()
+	s := NewMemoryOutboxStore(0)
+
+	first := addOutboxMessage(t, s, "a")
+	addOutboxMessage(t, s, "b")
+
+	// A duplicate event ID is ignored
+	dup := addOutboxMessage(t, s, "a")
+	if dup.Seq != 0 {
+		t.Fatalf("duplicate got seq %d", dup.Seq)
+	}
+
+	pending, _ := s.Pending(ctx, 10)
+	if len(pending) != 2 || pending[0].EventID != "a" || pending[1].EventID != "b" {
+		t.Fatalf("pending = %+v", pending)
+	}
+
+	if err := s.MarkSent(ctx, first.Seq, time.Now()); err != nil {
+		t.Fatalf("MarkSent: %v", err)
+	}
+	if n, _ := s.CountPending(ctx); n != 1 {
+		t.Fatalf("CountPending = %d, want 1", n)
+	}
+
+	// Sent events are still deduplicated until pruned
+	addOutboxMessage(t, s, "a")
+	if n, _ := s.CountPending(ctx); n != 1 {
+		t.Fatalf("CountPending after re-add = %d, want 1", n)
+	}
+
+	if err := s.Prune(ctx, time.Now().Add(time.Minute)); err != nil {
+		t.Fatalf("Prune: %v", err)
+	}
+	addOutboxMessage(t, s, "a")
+	if n, _ := s.CountPending(ctx); n != 2 {
+		t.Fatalf("CountPending after prune = %d, want 2", n)
+	}
+}
+
+func TestMemoryOutboxCapsPending(t *testing.T) {
+	ctx := context.Background()
+	s := NewMemoryOutboxStore(2)
+
+	first := addOutboxMessage(t, s, "a")
+	addOutboxMessage(t, s, "b")
+
+	err := s.Add(ctx, &models.OutboxMessage{EventID: "c", CreatedAt: time.Now()})
+	if !errors.Is(err, ErrOutboxFull) {
+		t.Fatalf("Add past the cap error = %v, want ErrOutboxFull", err)
+	}
+
+	// Publishing an event frees a slot
+	if err := s.MarkSent(ctx, first.Seq, time.Now()); err != nil {
+		t.Fatalf("MarkSent: %v", err)
+	}
+	addOutboxMessage(t, s, "c")
+}
+
+func TestMemoryOutboxRecordFailure(t *testing.T) {
+	ctx := context.Background()
+	s := NewMemoryOutboxStore(0)
+
+	msg := addOutboxMessage(t, s, "a")
+	if err := s.RecordFailure(ctx, msg.Seq, "broker down"); err != nil {
+		t.Fatalf("RecordFailure: %v", err)
+	}
+
+	pending, _ := s.Pending(ctx, 10)
+	if len(pending) != 1 || pending[0].Attempts != 1 || pending[0].LastError != "broker down" {
+		t.Fatalf("pending = %+v", pending)
+	}
+
+	if err := s.RecordFailure(ctx, 42, "x"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("RecordFailure of unknown seq error = %v, want ErrNotFound", err)
+	}
+}
+
+func TestMemoryOutboxSettlesOutOfOrder(t *testing.T) {
+	ctx := context.Background()
+	s := NewMemoryOutboxStore(0)
+
+	var msgs []*models.OutboxMessage
+	for _, id := range []string{"a", "b", "c", "d"} {
+		msgs = append(msgs, addOutboxMessage(t, s, id))
+	}
+
+	if err := s.MarkFailed(ctx, msgs[2].Seq, "unroutable", time.Now()); err != nil {
+		t.Fatalf("MarkFailed: %v", err)
+	}
+	if err := s.MarkSent(ctx, msgs[0].Seq, time.Now()); err != nil {
+		t.Fatalf("MarkSent: %v", err)
+	}
+	// Settling a message again does not count it twice
+	if err := s.MarkSent(ctx, msgs[0].Seq, time.Now()); err != nil {
+		t.Fatalf("second MarkSent: %v", err)
+	}
+
+	pending, _ := s.Pending(ctx, 10)
+	if len(pending) != 2 || pending[0].EventID != "b" || pending[1].EventID != "d" {
+		t.Fatalf("pending = %+v, want b and d in order", pending)
+	}
+	if n, _ := s.CountPending(ctx); n != 2 {
+		t.Fatalf("CountPending = %d, want 2", n)
+	}
+	if limited, _ := s.Pending(ctx, 1); len(limited) != 1 || limited[0].EventID != "b" {
+		t.Fatalf("Pending(1) = %+v, want b", limited)
+	}
+}
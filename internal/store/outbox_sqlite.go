@@ -0,0 +1,188 @@
+package store
+
+import (
+	"ai-image-microservice/api-gateway/internal/models"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+type SQLiteOutboxStore struct {
+	db *sql.DB
+}
+
+func NewSQLiteOutboxStore() (*SQLiteOutboxStore, error) {
+	db, err := getDB()
+	if err != nil {
+		return nil, err
+	}
+
+	// seq is never reused so messages keep the order they were added in.
+	// Times are Unix milliseconds so pruning compares numerically.
+	_, err = db.Exec(`
+		CREATE TABLE IF NOT EXISTS outbox (
+			seq            INTEGER PRIMARY KEY AUTOINCREMENT,
+			event_id       TEXT NOT NULL UNIQUE,
+			topic          TEXT NOT NULL,
+			payload        BLOB NOT NULL,
+			correlation_id TEXT NOT NULL DEFAULT '',
+			trace_context  TEXT NOT NULL DEFAULT '',
+			attempts       INTEGER NOT NULL DEFAULT 0,
+			last_error     TEXT NOT NULL DEFAULT '',
+			created_at     INTEGER NOT NULL,
+			sent_at        INTEGER,
+			failed_at      INTEGER
+		);
+		CREATE INDEX IF NOT EXISTS idx_outbox_pending ON outbox (sent_at, seq)`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create outbox table: %w", err)
+	}
+
+	// Columns added after the table was first released
+	for _, column := range []string{
+		`correlation_id TEXT NOT NULL DEFAULT ''`,
+		`trace_context TEXT NOT NULL DEFAULT ''`,
+		`failed_at INTEGER`,
+	} {
+		_, err := db.Exec(`ALTER TABLE outbox ADD COLUMN ` + column)
+		if err != nil && !strings.Contains(err.Error(), "duplicate column") {
+			return nil, fmt.Errorf("failed to migrate outbox table: %w", err)
+		}
+	}
+
+	return &SQLiteOutboxStore{db: db}, nil
+}
+
+func (s *SQLiteOutboxStore) Add(ctx context.Context, msg *models.OutboxMessage) error {
+	result, err := s.db.ExecContext(ctx, `
+		INSERT INTO outbox (event_id, topic, payload, correlation_id, trace_context, created_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT(event_id) DO NOTHING`,
+		msg.EventID,
+		msg.Topic,
+		msg.Payload,
+		msg.CorrelationID,
+		encodeTraceContext(msg.TraceContext),
+		msg.CreatedAt.UnixMilli(),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to add outbox message: %w", err)
+	}
+
+	if n, _ := result.RowsAffected(); n == 1 {
+		msg.Seq, _ = result.LastInsertId()
+	}
+	return nil
+}
+
+func (s *SQLiteOutboxStore) Pending(ctx context.Context, limit int) ([]models.OutboxMessage, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT seq, event_id, topic, payload, correlation_id, trace_context, attempts, last_error, created_at
+		FROM outbox WHERE sent_at IS NULL AND failed_at IS NULL ORDER BY seq LIMIT ?`, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list outbox messages: %w", err)
+	}
+	defer rows.Close()
+
+	messages := []models.OutboxMessage{}
+	for rows.Next() {
+		var (
+			msg          models.OutboxMessage
+			traceContext string
+			createdAt    int64
+		)
+		if err := rows.Scan(
+			&msg.Seq,
+			&msg.EventID,
+			&msg.Topic,
+			&msg.Payload,
+			&msg.CorrelationID,
+			&traceContext,
+			&msg.Attempts,
+			&msg.LastError,
+			&createdAt,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan outbox message: %w", err)
+		}
+		msg.CreatedAt = time.UnixMilli(createdAt).UTC()
+		msg.TraceContext = decodeTraceContext(traceContext)
+		messages = append(messages, msg)
+	}
+	return messages, rows.Err()
+}
+
+func (s *SQLiteOutboxStore) CountPending(ctx context.Context) (int, error) {
+	var count int
+	err := s.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM outbox WHERE sent_at IS NULL AND failed_at IS NULL`).Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count outbox messages: %w", err)
+	}
+	return count, nil
+}
+
+func (s *SQLiteOutboxStore) MarkSent(ctx context.Context, seq int64, sentAt time.Time) error {
+	result, err := s.db.ExecContext(ctx, `UPDATE outbox SET sent_at = ? WHERE seq = ?`, sentAt.UnixMilli(), seq)
+	if err != nil {
+		return fmt.Errorf("failed to mark outbox message sent: %w", err)
+	}
+
+	if n, _ := result.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (s *SQLiteOutboxStore) RecordFailure(ctx context.Context, seq int64, errMsg string) error {
+	result, err := s.db.ExecContext(ctx, `
+		UPDATE outbox SET attempts = attempts + 1, last_error = ? WHERE seq = ?`, errMsg, seq)
+	if err != nil {
+		return fmt.Errorf("failed to record outbox failure: %w", err)
+	}
+
+	if n, _ := result.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (s *SQLiteOutboxStore) MarkFailed(ctx context.Context, seq int64, errMsg string, failedAt time.Time) error {
+	result, err := s.db.ExecContext(ctx, `
+		UPDATE outbox SET failed_at = ?, last_error = ? WHERE seq = ?`, failedAt.UnixMilli(), errMsg, seq)
+	if err != nil {
+		return fmt.Errorf("failed to mark outbox message failed: %w", err)
+	}
+
+	if n, _ := result.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (s *SQLiteOutboxStore) Prune(ctx context.Context, before time.Time) error {
+	_, err := s.db.ExecContext(ctx, `
+		DELETE FROM outbox WHERE COALESCE(sent_at, failed_at) < ?`, before.UnixMilli())
+	if err != nil {
+		return fmt.Errorf("failed to prune outbox: %w", err)
+	}
+	return nil
+}
+
+func encodeTraceContext(traceContext map[string]string) string {
+	if len(traceContext) == 0 {
+		return ""
+	}
+	raw, _ := json.Marshal(traceContext)
+	return string(raw)
+}
+
+func decodeTraceContext(raw string) map[string]string {
+	if raw == "" {
+		return nil
+	}
+	var traceContext map[string]string
+	_ = json.Unmarshal([]byte(raw), &traceContext)
+	return traceContext
+}
@@ -0,0 +1,68 @@
+package store
+
+import (
+	"ai-image-microservice/api-gateway/internal/config"
+	"ai-image-microservice/api-gateway/internal/models"
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// ErrOutboxFull is returned by the memory outbox once it holds its maximum
+// of pending messages
+var ErrOutboxFull = errors.New("outbox is full")
+
+// OutboxStore holds events waiting to be published. Only the SQLite driver
+// keeps them across restarts.
+type OutboxStore interface {
+	// Add appends a message and sets its Seq. A message whose event ID is
+	// already in the outbox is ignored.
+	Add(ctx context.Context, msg *models.OutboxMessage) error
+	// Pending returns up to limit unsent messages in the order they were added
+	Pending(ctx context.Context, limit int) ([]models.OutboxMessage, error)
+	// CountPending returns the number of unsent messages
+	CountPending(ctx context.Context) (int, error)
+	MarkSent(ctx context.Context, seq int64, sentAt time.Time) error
+	RecordFailure(ctx context.Context, seq int64, errMsg string) error
+	// MarkFailed dead-letters a message that can never be published; it is
+	// no longer pending but kept for inspection until pruned
+	MarkFailed(ctx context.Context, seq int64, errMsg string, failedAt time.Time) error
+	// Prune deletes messages sent or dead-lettered before the given time.
+	// They are kept until then so their event IDs are still deduplicated.
+	Prune(ctx context.Context, before time.Time) error
+}
+
+var outboxStore OutboxStore
+
+func InitOutboxStore() error {
+	switch config.AppConfig.Store.Driver {
+	case DriverMemory:
+		if config.AppConfig.Outbox.Enabled {
+			logrus.Warn("The outbox uses the memory store: queued events are lost on restart, use STORE_DRIVER=sqlite for durability")
+		}
+		outboxStore = NewMemoryOutboxStore(config.AppConfig.Outbox.MaxPending)
+	case DriverSQLite:
+		s, err := NewSQLiteOutboxStore()
+		if err != nil {
+			return err
+		}
+		outboxStore = s
+	default:
+		return fmt.Errorf("unsupported store driver: %s", config.AppConfig.Store.Driver)
+	}
+
+	logrus.Infof("Outbox store initialized (%s)", config.AppConfig.Store.Driver)
+	return nil
+}
+
+func GetOutboxStore() OutboxStore {
+	if outboxStore == nil {
+		if err := InitOutboxStore(); err != nil {
+			logrus.Fatalf("Failed to initialize outbox store: %v", err)
+		}
+	}
+	return outboxStore
+}
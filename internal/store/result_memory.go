@@ -0,0 +1,37 @@
+package store
+
+import (
+	"ai-image-microservice/api-gateway/internal/models"
+	"context"
+	"sync"
+)
+
+type MemoryResultStore struct {
+	mu      sync.RWMutex
+	results map[string]models.ImageResults
+}
+
+func NewMemoryResultStore() *MemoryResultStore {
+	return &MemoryResultStore{
+		results: make(map[string]models.ImageResults),
+	}
+}
+
+func (s *MemoryResultStore) Save(ctx context.Context, results *models.ImageResults) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.results[results.ImageID] = *results
+	return nil
+}
+
+func (s *MemoryResultStore) Get(ctx context.Context, imageID string) (*models.ImageResults, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	results, ok := s.results[imageID]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &results, nil
+}
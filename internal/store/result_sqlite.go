@@ -0,0 +1,109 @@
+package store
+
+import (
+	"ai-image-microservice/api-gateway/internal/models"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+type SQLiteResultStore struct {
+	db *sql.DB
+}
+
+func NewSQLiteResultStore() (*SQLiteResultStore, error) {
+	db, err := getDB()
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = db.Exec(`
+		CREATE TABLE IF NOT EXISTS image_results (
+			image_id      TEXT PRIMARY KEY,
+			faces_found   INTEGER NOT NULL DEFAULT 0,
+			processing_ms INTEGER NOT NULL DEFAULT 0,
+			results       TEXT NOT NULL DEFAULT '[]',
+			storage_url   TEXT NOT NULL DEFAULT '',
+			recognized_at TIMESTAMP,
+			saved_at      TIMESTAMP,
+			updated_at    TIMESTAMP NOT NULL
+		)`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create image_results table: %w", err)
+	}
+
+	return &SQLiteResultStore{db: db}, nil
+}
+
+func (s *SQLiteResultStore) Save(ctx context.Context, results *models.ImageResults) error {
+	faces, err := json.Marshal(results.Results)
+	if err != nil {
+		return fmt.Errorf("failed to marshal face results: %w", err)
+	}
+
+	_, err = s.db.ExecContext(ctx, `
+		INSERT INTO image_results (image_id, faces_found, processing_ms, results, storage_url, recognized_at, saved_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(image_id) DO UPDATE SET
+			faces_found = excluded.faces_found,
+			processing_ms = excluded.processing_ms,
+			results = excluded.results,
+			storage_url = excluded.storage_url,
+			recognized_at = excluded.recognized_at,
+			saved_at = excluded.saved_at,
+			updated_at = excluded.updated_at`,
+		results.ImageID,
+		results.FacesFound,
+		results.ProcessingMs,
+		string(faces),
+		results.StorageURL,
+		results.RecognizedAt,
+		results.SavedAt,
+		results.UpdatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to save image results: %w", err)
+	}
+	return nil
+}
+
+func (s *SQLiteResultStore) Get(ctx context.Context, imageID string) (*models.ImageResults, error) {
+	var (
+		results      models.ImageResults
+		faces        string
+		recognizedAt sql.NullTime
+		savedAt      sql.NullTime
+	)
+
+	err := s.db.QueryRowContext(ctx, `
+		SELECT image_id, faces_found, processing_ms, results, storage_url, recognized_at, saved_at, updated_at
+		FROM image_results WHERE image_id = ?`, imageID).Scan(
+		&results.ImageID,
+		&results.FacesFound,
+		&results.ProcessingMs,
+		&faces,
+		&results.StorageURL,
+		&recognizedAt,
+		&savedAt,
+		&results.UpdatedAt,
+	)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get image results: %w", err)
+	}
+
+	if err := json.Unmarshal([]byte(faces), &results.Results); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal face results: %w", err)
+	}
+	if recognizedAt.Valid {
+		results.RecognizedAt = &recognizedAt.Time
+	}
+	if savedAt.Valid {
+		results.SavedAt = &savedAt.Time
+	}
+	return &results, nil
+}
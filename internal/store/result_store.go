@@ -0,0 +1,45 @@
+package store
+
+import (
+	"ai-image-microservice/api-gateway/internal/config"
+	"ai-image-microservice/api-gateway/internal/models"
+	"context"
+	"fmt"
+
+	"github.com/sirupsen/logrus"
+)
+
+// ResultStore persists face recognition output and storage locations
+type ResultStore interface {
+	Save(ctx context.Context, results *models.ImageResults) error
+	Get(ctx context.Context, imageID string) (*models.ImageResults, error)
+}
+
+var resultStore ResultStore
+
+func InitResultStore() error {
+	switch config.AppConfig.Store.Driver {
+	case DriverMemory:
+		resultStore = NewMemoryResultStore()
+	case DriverSQLite:
+		s, err := NewSQLiteResultStore()
+		if err != nil {
+			return err
+		}
+		resultStore = s
+	default:
+		return fmt.Errorf("unsupported store driver: %s", config.AppConfig.Store.Driver)
+	}
+
+	logrus.Infof("Result store initialized (%s)", config.AppConfig.Store.Driver)
+	return nil
+}
+
+func GetResultStore() ResultStore {
+	if resultStore == nil {
+		if err := InitResultStore(); err != nil {
+			logrus.Fatalf("Failed to initialize result store: %v", err)
+		}
+	}
+	return resultStore
+}
@@ -0,0 +1,37 @@
+package store
+
+import (
+	"ai-image-microservice/api-gateway/internal/models"
+	"context"
+	"sync"
+)
+
+type MemoryStatusStore struct {
+	mu       sync.RWMutex
+	statuses map[string]models.JobStatus
+}
+
+func NewMemoryStatusStore() *MemoryStatusStore {
+	return &MemoryStatusStore{
+		statuses: make(map[string]models.JobStatus),
+	}
+}
+
+func (s *MemoryStatusStore) Save(ctx context.Context, status *models.JobStatus) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.statuses[status.ImageID] = *status
+	return nil
+}
+
+func (s *MemoryStatusStore) Get(ctx context.Context, imageID string) (*models.JobStatus, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	status, ok := s.statuses[imageID]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &status, nil
+}
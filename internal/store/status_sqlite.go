@@ -0,0 +1,111 @@
+package store
+
+import (
+	"ai-image-microservice/api-gateway/internal/models"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+type SQLiteStatusStore struct {
+	db *sql.DB
+}
+
+func NewSQLiteStatusStore() (*SQLiteStatusStore, error) {
+	db, err := getDB()
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = db.Exec(`
+		CREATE TABLE IF NOT EXISTS job_statuses (
+			image_id     TEXT PRIMARY KEY,
+			tenant_id    TEXT NOT NULL DEFAULT '',
+			user_id      TEXT NOT NULL DEFAULT '',
+			callback_url TEXT NOT NULL DEFAULT '',
+			status       TEXT NOT NULL,
+			message      TEXT NOT NULL DEFAULT '',
+			error        TEXT NOT NULL DEFAULT '',
+			created_at   TIMESTAMP NOT NULL,
+			updated_at   TIMESTAMP NOT NULL,
+			completed_at TIMESTAMP
+		)`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create job_statuses table: %w", err)
+	}
+
+	// Columns added after the table was first released
+	_, err = db.Exec(`ALTER TABLE job_statuses ADD COLUMN tenant_id TEXT NOT NULL DEFAULT ''`)
+	if err != nil && !strings.Contains(err.Error(), "duplicate column") {
+		return nil, fmt.Errorf("failed to migrate job_statuses table: %w", err)
+	}
+
+	return &SQLiteStatusStore{db: db}, nil
+}
+
+func (s *SQLiteStatusStore) Save(ctx context.Context, status *models.JobStatus) error {
+	_, err := s.db.ExecContext(ctx, `
+		INSERT INTO job_statuses (image_id, tenant_id, user_id, callback_url, status, message, error, created_at, updated_at, completed_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(image_id) DO UPDATE SET
+			tenant_id = excluded.tenant_id,
+			user_id = excluded.user_id,
+			callback_url = excluded.callback_url,
+			status = excluded.status,
+			message = excluded.message,
+			error = excluded.error,
+			updated_at = excluded.updated_at,
+			completed_at = excluded.completed_at`,
+		status.ImageID,
+		status.TenantID,
+		status.UserID,
+		status.CallbackURL,
+		string(status.Status),
+		status.Message,
+		status.Error,
+		status.CreatedAt,
+		status.UpdatedAt,
+		status.CompletedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to save job status: %w", err)
+	}
+	return nil
+}
+
+func (s *SQLiteStatusStore) Get(ctx context.Context, imageID string) (*models.JobStatus, error) {
+	var (
+		status      models.JobStatus
+		state       string
+		completedAt sql.NullTime
+	)
+
+	err := s.db.QueryRowContext(ctx, `
+		SELECT image_id, tenant_id, user_id, callback_url, status, message, error, created_at, updated_at, completed_at
+		FROM job_statuses WHERE image_id = ?`, imageID).Scan(
+		&status.ImageID,
+		&status.TenantID,
+		&status.UserID,
+		&status.CallbackURL,
+		&state,
+		&status.Message,
+		&status.Error,
+		&status.CreatedAt,
+		&status.UpdatedAt,
+		&completedAt,
+	)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get job status: %w", err)
+	}
+
+	status.Status = models.JobState(state)
+	if completedAt.Valid {
+		status.CompletedAt = &completedAt.Time
+	}
+	return &status, nil
+}
@@ -0,0 +1,45 @@
+package store
+
+import (
+	"ai-image-microservice/api-gateway/internal/config"
+	"ai-image-microservice/api-gateway/internal/models"
+	"context"
+	"fmt"
+
+	"github.com/sirupsen/logrus"
+)
+
+// StatusStore persists the processing state of submitted images
+type StatusStore interface {
+	Save(ctx context.Context, status *models.JobStatus) error
+	Get(ctx context.Context, imageID string) (*models.JobStatus, error)
+}
+
+var statusStore StatusStore
+
+func InitStatusStore() error {
+	switch config.AppConfig.Store.Driver {
+	case DriverMemory:
+		statusStore = NewMemoryStatusStore()
+	case DriverSQLite:
+		s, err := NewSQLiteStatusStore()
+		if err != nil {
+			return err
+		}
+		statusStore = s
+	default:
+		return fmt.Errorf("unsupported store driver: %s", config.AppConfig.Store.Driver)
+	}
+
+	logrus.Infof("Job status store initialized (%s)", config.AppConfig.Store.Driver)
+	return nil
+}
+
+func GetStatusStore() StatusStore {
+	if statusStore == nil {
+		if err := InitStatusStore(); err != nil {
+			logrus.Fatalf("Failed to initialize status store: %v", err)
+		}
+	}
+	return statusStore
+}
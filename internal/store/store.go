@@ -0,0 +1,102 @@
+package store
+
+import (
+	"ai-image-microservice/api-gateway/internal/config"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	_ "modernc.org/sqlite"
+)
+
+const (
+	DriverMemory = "memory"
+	DriverSQLite = "sqlite"
+)
+
+var ErrNotFound = errors.New("record not found")
+
+var (
+	db   *sql.DB
+	dbMu sync.Mutex
+)
+
+// Init initializes every store using the configured driver
+func Init() error {
+	if err := InitStatusStore(); err != nil {
+		return fmt.Errorf("failed to initialize status store: %w", err)
+	}
+
+	if err := InitResultStore(); err != nil {
+		return fmt.Errorf("failed to initialize result store: %w", err)
+	}
+
+	if err := InitWebhookStore(); err != nil {
+		return fmt.Errorf("failed to initialize webhook store: %w", err)
+	}
+
+	if err := InitBatchStore(); err != nil {
+		return fmt.Errorf("failed to initialize batch store: %w", err)
+	}
+
+	if err := InitUsageStore(); err != nil {
+		return fmt.Errorf("failed to initialize usage store: %w", err)
+	}
+
+	if err := InitIdempotencyStore(); err != nil {
+		return fmt.Errorf("failed to initialize idempotency store: %w", err)
+	}
+
+	if err := InitOutboxStore(); err != nil {
+		return fmt.Errorf("failed to initialize outbox store: %w", err)
+	}
+
+	return nil
+}
+
+// getDB lazily opens the SQLite database shared by all SQLite-backed stores
+func getDB() (*sql.DB, error) {
+	dbMu.Lock()
+	defer dbMu.Unlock()
+
+	if db != nil {
+		return db, nil
+	}
+
+	path := config.AppConfig.Store.SQLitePath
+	if dir := filepath.Dir(path); dir != "" {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return nil, fmt.Errorf("failed to create database directory: %w", err)
+		}
+	}
+
+	conn, err := sql.Open("sqlite", path+"?_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)")
+	if err != nil {
+		return nil, fmt.Errorf("failed to open sqlite database: %w", err)
+	}
+
+	if err := conn.Ping(); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to connect to sqlite database: %w", err)
+	}
+
+	db = conn
+	return db, nil
+}
+
+// Close releases the shared SQLite database, if one was opened
+func Close() error {
+	dbMu.Lock()
+	defer dbMu.Unlock()
+
+	if db == nil {
+		return nil
+	}
+
+	err := db.Close()
+	db = nil
+	return err
+}
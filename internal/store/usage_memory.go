@@ -0,0 +1,131 @@
+package store
+
+import (
+	"ai-image-microservice/api-gateway/internal/models"
+	"context"
+	"sync"
+	"time"
+)
+
+// usageRetentionDays covers the longest quota period; monthly totals never
+// look back further than 31 days
+const usageRetentionDays = 32
+
+// MemoryUsageStore keeps the ledger in memory, grouped per tenant and UTC
+// day with a running total per day, so totals are summed over whole days
+// rather than over every image. Days older than the longest quota period
+// are pruned on write.
+type MemoryUsageStore struct {
+	mu   sync.RWMutex
+	days map[string]map[time.Time]*usageDayEntries
+	// records indexes the ledger by image ID
+	records map[string]*models.UsageRecord
+}
+
+// usageDayEntries holds the images a tenant recorded on one day
+type usageDayEntries struct {
+	totals models.UsageTotals
+	images map[string]struct{}
+}
+
+func NewMemoryUsageStore() *MemoryUsageStore {
+	return &MemoryUsageStore{
+		days:    make(map[string]map[time.Time]*usageDayEntries),
+		records: make(map[string]*models.UsageRecord),
+	}
+}
+
+func usageDay(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+func (s *MemoryUsageStore) Record(ctx context.Context, record *models.UsageRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.records[record.ImageID]; exists {
+		return nil
+	}
+
+	days := s.days[record.TenantID]
+	if days == nil {
+		days = make(map[time.Time]*usageDayEntries)
+		s.days[record.TenantID] = days
+	}
+
+	cutoff := usageDay(time.Now()).AddDate(0, 0, -usageRetentionDays)
+	for day, entries := range days {
+		if day.Before(cutoff) {
+			for imageID := range entries.images {
+				delete(s.records, imageID)
+			}
+			delete(days, day)
+		}
+	}
+
+	day := usageDay(record.CreatedAt)
+	entries := days[day]
+	if entries == nil {
+		entries = &usageDayEntries{images: make(map[string]struct{})}
+		days[day] = entries
+	}
+	entries.images[record.ImageID] = struct{}{}
+	entries.totals.Images++
+	entries.totals.Bytes += record.Bytes
+
+	stored := *record
+	s.records[record.ImageID] = &stored
+	return nil
+}
+
+func (s *MemoryUsageStore) Get(ctx context.Context, imageID string) (*models.UsageRecord, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	record, ok := s.records[imageID]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	copied := *record
+	return &copied, nil
+}
+
+func (s *MemoryUsageStore) Delete(ctx context.Context, imageID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	record, ok := s.records[imageID]
+	if !ok {
+		return ErrNotFound
+	}
+	delete(s.records, imageID)
+
+	day := usageDay(record.CreatedAt)
+	entries := s.days[record.TenantID][day]
+	delete(entries.images, imageID)
+	entries.totals.Images--
+	entries.totals.Bytes -= record.Bytes
+	if len(entries.images) == 0 {
+		delete(s.days[record.TenantID], day)
+		if len(s.days[record.TenantID]) == 0 {
+			delete(s.days, record.TenantID)
+		}
+	}
+	return nil
+}
+
+func (s *MemoryUsageStore) Totals(ctx context.Context, tenantID string, since time.Time) (*models.UsageTotals, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	since = usageDay(since)
+	totals := &models.UsageTotals{}
+	for day, entries := range s.days[tenantID] {
+		if !day.Before(since) {
+			totals.Images += entries.totals.Images
+			totals.Bytes += entries.totals.Bytes
+		}
+	}
+	return totals, nil
+}
@@ -0,0 +1,83 @@
+package store
+
+import (
+	"ai-image-microservice/api-gateway/internal/models"
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestMemoryUsageTotals(t *testing.T) {
+	ctx := context.Background()
+	s := NewMemoryUsageStore()
+
+	now := time.Now().UTC()
+	today := usageDay(now)
+	yesterday := today.AddDate(0, 0, -1)
+
+	records := []*models.UsageRecord{
+		{ImageID: "a", TenantID: "acme", Bytes: 100, CreatedAt: now},
+		{ImageID: "b", TenantID: "acme", Bytes: 50, CreatedAt: now},
+		{ImageID: "c", TenantID: "acme", Bytes: 10, CreatedAt: yesterday.Add(time.Hour)},
+		{ImageID: "d", TenantID: "other", Bytes: 1, CreatedAt: now},
+	}
+	for _, r := range records {
+		if err := s.Record(ctx, r); err != nil {
+			t.Fatalf("Record(%s): %v", r.ImageID, err)
+		}
+	}
+
+	totals, _ := s.Totals(ctx, "acme", today)
+	if totals.Images != 2 || totals.Bytes != 150 {
+		t.Fatalf("today = %+v, want 2 images and 150 bytes", totals)
+	}
+
+	totals, _ = s.Totals(ctx, "acme", yesterday)
+	if totals.Images != 3 || totals.Bytes != 160 {
+		t.Fatalf("since yesterday = %+v, want 3 images and 160 bytes", totals)
+	}
+
+	if err := s.Delete(ctx, records[0].ImageID); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	totals, _ = s.Totals(ctx, "acme", today)
+	if totals.Images != 1 || totals.Bytes != 50 {
+		t.Fatalf("today after delete = %+v, want 1 image and 50 bytes", totals)
+	}
+}
+
+func TestMemoryUsageDeleteUnknown(t *testing.T) {
+	ctx := context.Background()
+	s := NewMemoryUsageStore()
+
+	r := &models.UsageRecord{ImageID: "a", TenantID: "acme", Bytes: 100, CreatedAt: time.Now().UTC()}
+	if err := s.Delete(ctx, r.ImageID); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Delete before Record = %v, want ErrNotFound", err)
+	}
+
+	s.Record(ctx, r)
+	s.Delete(ctx, r.ImageID)
+	if err := s.Delete(ctx, r.ImageID); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("second Delete = %v, want ErrNotFound", err)
+	}
+	if len(s.days) != 0 || len(s.records) != 0 {
+		t.Fatalf("entries left behind: %v, %v", s.days, s.records)
+	}
+}
+
+func TestMemoryUsagePrunesOldDays(t *testing.T) {
+	ctx := context.Background()
+	s := NewMemoryUsageStore()
+
+	old := time.Now().UTC().AddDate(0, 0, -usageRetentionDays-2)
+	s.Record(ctx, &models.UsageRecord{ImageID: "old", TenantID: "acme", Bytes: 1, CreatedAt: old})
+	s.Record(ctx, &models.UsageRecord{ImageID: "new", TenantID: "acme", Bytes: 1, CreatedAt: time.Now().UTC()})
+
+	if n := len(s.days["acme"]); n != 1 {
+		t.Fatalf("kept %d days, want 1", n)
+	}
+	if _, err := s.Get(ctx, "old"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Get of a pruned image = %v, want ErrNotFound", err)
+	}
+}
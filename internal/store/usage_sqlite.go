@@ -0,0 +1,105 @@
+package store
+
+import (
+	"ai-image-microservice/api-gateway/internal/models"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+type SQLiteUsageStore struct {
+	db *sql.DB
+}
+
+func NewSQLiteUsageStore() (*SQLiteUsageStore, error) {
+	db, err := getDB()
+	if err != nil {
+		return nil, err
+	}
+
+	// created_at holds Unix milliseconds so period ranges compare numerically
+	_, err = db.Exec(`
+		CREATE TABLE IF NOT EXISTS usage_ledger (
+			image_id   TEXT PRIMARY KEY,
+			tenant_id  TEXT NOT NULL,
+			user_id    TEXT NOT NULL DEFAULT '',
+			bytes      INTEGER NOT NULL,
+			created_at INTEGER NOT NULL
+		);
+		CREATE INDEX IF NOT EXISTS idx_usage_ledger_tenant ON usage_ledger (tenant_id, created_at)`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create usage_ledger table: %w", err)
+	}
+
+	return &SQLiteUsageStore{db: db}, nil
+}
+
+func (s *SQLiteUsageStore) Record(ctx context.Context, record *models.UsageRecord) error {
+	_, err := s.db.ExecContext(ctx, `
+		INSERT INTO usage_ledger (image_id, tenant_id, user_id, bytes, created_at)
+		VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT(image_id) DO NOTHING`,
+		record.ImageID,
+		record.TenantID,
+		record.UserID,
+		record.Bytes,
+		record.CreatedAt.UnixMilli(),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to record usage: %w", err)
+	}
+	return nil
+}
+
+func (s *SQLiteUsageStore) Get(ctx context.Context, imageID string) (*models.UsageRecord, error) {
+	var (
+		record    models.UsageRecord
+		createdAt int64
+	)
+
+	err := s.db.QueryRowContext(ctx, `
+		SELECT image_id, tenant_id, user_id, bytes, created_at
+		FROM usage_ledger WHERE image_id = ?`, imageID).Scan(
+		&record.ImageID,
+		&record.TenantID,
+		&record.UserID,
+		&record.Bytes,
+		&createdAt,
+	)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get usage: %w", err)
+	}
+
+	record.CreatedAt = time.UnixMilli(createdAt).UTC()
+	return &record, nil
+}
+
+func (s *SQLiteUsageStore) Delete(ctx context.Context, imageID string) error {
+	result, err := s.db.ExecContext(ctx, `DELETE FROM usage_ledger WHERE image_id = ?`, imageID)
+	if err != nil {
+		return fmt.Errorf("failed to delete usage: %w", err)
+	}
+
+	if n, _ := result.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (s *SQLiteUsageStore) Totals(ctx context.Context, tenantID string, since time.Time) (*models.UsageTotals, error) {
+	totals := &models.UsageTotals{}
+	err := s.db.QueryRowContext(ctx, `
+		SELECT COUNT(*), COALESCE(SUM(bytes), 0)
+		FROM usage_ledger WHERE tenant_id = ? AND created_at >= ?`,
+		tenantID, since.UnixMilli(),
+	).Scan(&totals.Images, &totals.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get usage totals: %w", err)
+	}
+	return totals, nil
+}
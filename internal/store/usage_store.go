@@ -0,0 +1,53 @@
+package store
+
+import (
+	"ai-image-microservice/api-gateway/internal/config"
+	"ai-image-microservice/api-gateway/internal/models"
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// UsageStore is the ledger of accepted images used for tenant quotas. Each
+// image is recorded at most once.
+type UsageStore interface {
+	// Record adds an image to the ledger; recording an image that is
+	// already in it is a no-op
+	Record(ctx context.Context, record *models.UsageRecord) error
+	// Get returns the ledger entry of an image, or ErrNotFound
+	Get(ctx context.Context, imageID string) (*models.UsageRecord, error)
+	// Delete removes the usage of an image, or returns ErrNotFound
+	Delete(ctx context.Context, imageID string) error
+	Totals(ctx context.Context, tenantID string, since time.Time) (*models.UsageTotals, error)
+}
+
+var usageStore UsageStore
+
+func InitUsageStore() error {
+	switch config.AppConfig.Store.Driver {
+	case DriverMemory:
+		usageStore = NewMemoryUsageStore()
+	case DriverSQLite:
+		s, err := NewSQLiteUsageStore()
+		if err != nil {
+			return err
+		}
+		usageStore = s
+	default:
+		return fmt.Errorf("unsupported store driver: %s", config.AppConfig.Store.Driver)
+	}
+
+	logrus.Infof("Usage store initialized (%s)", config.AppConfig.Store.Driver)
+	return nil
+}
+
+func GetUsageStore() UsageStore {
+	if usageStore == nil {
+		if err := InitUsageStore(); err != nil {
+			logrus.Fatalf("Failed to initialize usage store: %v", err)
+		}
+	}
+	return usageStore
+}
@@ -0,0 +1,90 @@
+package store
+
+import (
+	"ai-image-microservice/api-gateway/internal/config"
+	"ai-image-microservice/api-gateway/internal/models"
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// openTestSQLite points the shared database at a fresh file for the test
+func openTestSQLite(t *testing.T) {
+	t.Helper()
+
+	config.AppConfig = &config.Config{Store: config.StoreConfig{
+		Driver:     DriverSQLite,
+		SQLitePath: filepath.Join(t.TempDir(), "gateway.db"),
+	}}
+	t.Cleanup(func() { Close() })
+}
+
+func usageStores(t *testing.T) map[string]UsageStore {
+	t.Helper()
+
+	openTestSQLite(t)
+	sqlite, err := NewSQLiteUsageStore()
+	if err != nil {
+		t.Fatalf("NewSQLiteUsageStore: %v", err)
+	}
+	return map[string]UsageStore{DriverMemory: NewMemoryUsageStore(), DriverSQLite: sqlite}
+}
+
+func TestUsageStoreRecordsEachImageOnce(t *testing.T) {
+	ctx := context.Background()
+	today := usageDay(time.Now())
+
+	for driver, s := range usageStores(t) {
+		t.Run(driver, func(t *testing.T) {
+			record := &models.UsageRecord{ImageID: "a", TenantID: "acme", Bytes: 100, CreatedAt: time.Now().UTC()}
+			for i := 0; i < 2; i++ {
+				if err := s.Record(ctx, record); err != nil {
+					t.Fatalf("Record %d: %v", i, err)
+				}
+			}
+
+			totals, _ := s.Totals(ctx, "acme", today)
+			if totals.Images != 1 || totals.Bytes != 100 {
+				t.Fatalf("totals = %+v, want the image counted once", totals)
+			}
+
+			got, err := s.Get(ctx, "a")
+			if err != nil || got.TenantID != "acme" || got.Bytes != 100 {
+				t.Fatalf("Get = %+v, %v; want the recorded image", got, err)
+			}
+		})
+	}
+}
+
+func TestUsageStoreDeletesByImage(t *testing.T) {
+	ctx := context.Background()
+	today := usageDay(time.Now())
+
+	for driver, s := range usageStores(t) {
+		t.Run(driver, func(t *testing.T) {
+			s.Record(ctx, &models.UsageRecord{ImageID: "a", TenantID: "acme", Bytes: 100, CreatedAt: time.Now().UTC()})
+			s.Record(ctx, &models.UsageRecord{ImageID: "b", TenantID: "acme", Bytes: 50, CreatedAt: time.Now().UTC()})
+
+			if err := s.Delete(ctx, "a"); err != nil {
+				t.Fatalf("Delete: %v", err)
+			}
+			// Releasing twice, or an image never recorded, leaves the
+			// usage of the other images alone
+			for _, imageID := range []string{"a", "unknown"} {
+				if err := s.Delete(ctx, imageID); !errors.Is(err, ErrNotFound) {
+					t.Fatalf("Delete(%s) = %v, want ErrNotFound", imageID, err)
+				}
+			}
+
+			totals, _ := s.Totals(ctx, "acme", today)
+			if totals.Images != 1 || totals.Bytes != 50 {
+				t.Fatalf("totals = %+v, want only image b", totals)
+			}
+			if _, err := s.Get(ctx, "a"); !errors.Is(err, ErrNotFound) {
+				t.Fatalf("Get after Delete = %v, want ErrNotFound", err)
+			}
+		})
+	}
+}
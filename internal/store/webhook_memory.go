@@ -0,0 +1,64 @@
+package store
+
+import (
+	"ai-image-microservice/api-gateway/internal/models"
+	"context"
+	"sort"
+	"sync"
+)
+
+type MemoryWebhookStore struct {
+	mu         sync.RWMutex
+	deliveries map[string]models.WebhookDelivery
+}
+
+func NewMemoryWebhookStore() *MemoryWebhookStore {
+	return &MemoryWebhookStore{
+		deliveries: make(map[string]models.WebhookDelivery),
+	}
+}
+
+func (s *MemoryWebhookStore) Save(ctx context.Context, delivery *models.WebhookDelivery) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	saved := *delivery
+	saved.Attempts = append([]models.WebhookAttempt(nil), delivery.Attempts...)
+	saved.Payload = append([]byte(nil), delivery.Payload...)
+	s.deliveries[delivery.ID] = saved
+	return nil
+}
+
+func (s *MemoryWebhookStore) ListByImage(ctx context.Context, imageID string) ([]models.WebhookDelivery, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	deliveries := []models.WebhookDelivery{}
+	for _, delivery := range s.deliveries {
+		if delivery.ImageID == imageID {
+			deliveries = append(deliveries, delivery)
+		}
+	}
+
+	sort.Slice(deliveries, func(i, j int) bool {
+		return deliveries[i].CreatedAt.Before(deliveries[j].CreatedAt)
+	})
+	return deliveries, nil
+}
+
+func (s *MemoryWebhookStore) ListPending(ctx context.Context) ([]models.WebhookDelivery, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	deliveries := []models.WebhookDelivery{}
+	for _, delivery := range s.deliveries {
+		if delivery.State == models.WebhookDeliveryPending {
+			deliveries = append(deliveries, delivery)
+		}
+	}
+
+	sort.Slice(deliveries, func(i, j int) bool {
+		return deliveries[i].CreatedAt.Before(deliveries[j].CreatedAt)
+	})
+	return deliveries, nil
+}
@@ -0,0 +1,126 @@
+package store
+
+import (
+	"ai-image-microservice/api-gateway/internal/models"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+type SQLiteWebhookStore struct {
+	db *sql.DB
+}
+
+func NewSQLiteWebhookStore() (*SQLiteWebhookStore, error) {
+	db, err := getDB()
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = db.Exec(`
+		CREATE TABLE IF NOT EXISTS webhook_deliveries (
+			id         TEXT PRIMARY KEY,
+			image_id   TEXT NOT NULL,
+			url        TEXT NOT NULL,
+			event      TEXT NOT NULL,
+			state      TEXT NOT NULL,
+			attempts   TEXT NOT NULL DEFAULT '[]',
+			payload    BLOB,
+			created_at TIMESTAMP NOT NULL,
+			updated_at TIMESTAMP NOT NULL
+		);
+		CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_image_id ON webhook_deliveries (image_id);
+		CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_state ON webhook_deliveries (state)`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create webhook_deliveries table: %w", err)
+	}
+
+	// Columns added after the table was first released
+	_, err = db.Exec(`ALTER TABLE webhook_deliveries ADD COLUMN payload BLOB`)
+	if err != nil && !strings.Contains(err.Error(), "duplicate column") {
+		return nil, fmt.Errorf("failed to migrate webhook_deliveries table: %w", err)
+	}
+
+	return &SQLiteWebhookStore{db: db}, nil
+}
+
+func (s *SQLiteWebhookStore) Save(ctx context.Context, delivery *models.WebhookDelivery) error {
+	attempts, err := json.Marshal(delivery.Attempts)
+	if err != nil {
+		return fmt.Errorf("failed to marshal webhook attempts: %w", err)
+	}
+
+	_, err = s.db.ExecContext(ctx, `
+		INSERT INTO webhook_deliveries (id, image_id, url, event, state, attempts, payload, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(id) DO UPDATE SET
+			state = excluded.state,
+			attempts = excluded.attempts,
+			updated_at = excluded.updated_at`,
+		delivery.ID,
+		delivery.ImageID,
+		delivery.URL,
+		delivery.Event,
+		string(delivery.State),
+		string(attempts),
+		delivery.Payload,
+		delivery.CreatedAt,
+		delivery.UpdatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to save webhook delivery: %w", err)
+	}
+	return nil
+}
+
+func (s *SQLiteWebhookStore) ListByImage(ctx context.Context, imageID string) ([]models.WebhookDelivery, error) {
+	return s.list(ctx, `
+		SELECT id, image_id, url, event, state, attempts, payload, created_at, updated_at
+		FROM webhook_deliveries WHERE image_id = ? ORDER BY created_at`, imageID)
+}
+
+func (s *SQLiteWebhookStore) ListPending(ctx context.Context) ([]models.WebhookDelivery, error) {
+	return s.list(ctx, `
+		SELECT id, image_id, url, event, state, attempts, payload, created_at, updated_at
+		FROM webhook_deliveries WHERE state = ? ORDER BY created_at`, string(models.WebhookDeliveryPending))
+}
+
+func (s *SQLiteWebhookStore) list(ctx context.Context, query string, args ...interface{}) ([]models.WebhookDelivery, error) {
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list webhook deliveries: %w", err)
+	}
+	defer rows.Close()
+
+	deliveries := []models.WebhookDelivery{}
+	for rows.Next() {
+		var (
+			delivery models.WebhookDelivery
+			state    string
+			attempts string
+		)
+		if err := rows.Scan(
+			&delivery.ID,
+			&delivery.ImageID,
+			&delivery.URL,
+			&delivery.Event,
+			&state,
+			&attempts,
+			&delivery.Payload,
+			&delivery.CreatedAt,
+			&delivery.UpdatedAt,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan webhook delivery: %w", err)
+		}
+
+		delivery.State = models.WebhookDeliveryState(state)
+		if err := json.Unmarshal([]byte(attempts), &delivery.Attempts); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal webhook attempts: %w", err)
+		}
+		deliveries = append(deliveries, delivery)
+	}
+
+	return deliveries, rows.Err()
+}
@@ -0,0 +1,47 @@
+package store
+
+import (
+	"ai-image-microservice/api-gateway/internal/config"
+	"ai-image-microservice/api-gateway/internal/models"
+	"context"
+	"fmt"
+
+	"github.com/sirupsen/logrus"
+)
+
+// WebhookStore records webhook deliveries and their attempts
+type WebhookStore interface {
+	Save(ctx context.Context, delivery *models.WebhookDelivery) error
+	ListByImage(ctx context.Context, imageID string) ([]models.WebhookDelivery, error)
+	// ListPending returns the deliveries still to be attempted, oldest first
+	ListPending(ctx context.Context) ([]models.WebhookDelivery, error)
+}
+
+var webhookStore WebhookStore
+
+func InitWebhookStore() error {
+	switch config.AppConfig.Store.Driver {
+	case DriverMemory:
+		webhookStore = NewMemoryWebhookStore()
+	case DriverSQLite:
+		s, err := NewSQLiteWebhookStore()
+		if err != nil {
+			return err
+		}
+		webhookStore = s
+	default:
+		return fmt.Errorf("unsupported store driver: %s", config.AppConfig.Store.Driver)
+	}
+
+	logrus.Infof("Webhook store initialized (%s)", config.AppConfig.Store.Driver)
+	return nil
+}
+
+func GetWebhookStore() WebhookStore {
+	if webhookStore == nil {
+		if err := InitWebhookStore(); err != nil {
+			logrus.Fatalf("Failed to initialize webhook store: %v", err)
+		}
+	}
+	return webhookStore
+}
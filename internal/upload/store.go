@@ -0,0 +1,316 @@
+package upload
+
+import (
+	"ai-image-microservice/api-gateway/internal/config"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+)
+
+var (
+	ErrNotFound       = errors.New("upload not found")
+	ErrOffsetMismatch = errors.New("upload offset mismatch")
+	ErrLocked         = errors.New("upload is locked by another request")
+	ErrTooLarge       = errors.New("chunk exceeds upload length")
+)
+
+// Upload describes a resumable upload in progress
+type Upload struct {
+	ID        string            `json:"id"`
+	Length    int64             `json:"length"`
+	Offset    int64             `json:"offset"`
+	Metadata  map[string]string `json:"metadata"`
+	Owner     string            `json:"owner,omitempty"`     // authenticated user that created the upload
+	TenantID  string            `json:"tenant_id,omitempty"` // tenant of the owner
+	CreatedAt time.Time         `json:"created_at"`
+	ExpiresAt time.Time         `json:"expires_at"`
+	ImageID   string            `json:"image_id,omitempty"`
+}
+
+// Complete reports whether every byte of the upload has been received
+func (u *Upload) Complete() bool {
+	return u.Offset == u.Length
+}
+
+// Store assembles upload chunks on disk. Each upload is kept as a data file
+// holding the bytes received so far and an info file with its description.
+type Store struct {
+	dir   string
+	ttl   time.Duration
+	mu    sync.Mutex
+	locks map[string]*uploadLock
+}
+
+// uploadLock is shared by every request touching an upload. It is dropped
+// once no request references it, so all concurrent requests see the same
+// mutex.
+type uploadLock struct {
+	mu   sync.Mutex
+	refs int
+}
+
+var (
+	store     *Store
+	storeOnce sync.Once
+)
+
+func GetStore() *Store {
+	storeOnce.Do(func() {
+		s, err := NewStore(config.AppConfig.Upload.Dir, config.AppConfig.Upload.TTL)
+		if err != nil {
+			logrus.Fatalf("Failed to initialize upload store: %v", err)
+		}
+		store = s
+		go store.cleanupLoop()
+	})
+	return store
+}
+
+func NewStore(dir string, ttl time.Duration) (*Store, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create upload directory: %w", err)
+	}
+
+	return &Store{
+		dir:   dir,
+		ttl:   ttl,
+		locks: make(map[string]*uploadLock),
+	}, nil
+}
+
+func (s *Store) Create(length int64, metadata map[string]string, tenantID, owner string) (*Upload, error) {
+	now := time.Now().UTC()
+	upload := &Upload{
+		ID:        uuid.New().String(),
+		Length:    length,
+		Metadata:  metadata,
+		Owner:     owner,
+		TenantID:  tenantID,
+		CreatedAt: now,
+		ExpiresAt: now.Add(s.ttl),
+	}
+
+	file, err := os.Create(s.dataPath(upload.ID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create upload file: %w", err)
+	}
+	file.Close()
+
+	if err := s.writeInfo(upload); err != nil {
+		os.Remove(s.dataPath(upload.ID))
+		return nil, err
+	}
+
+	return upload, nil
+}
+
+func (s *Store) Get(id string) (*Upload, error) {
+	if !validID(id) {
+		return nil, ErrNotFound
+	}
+
+	raw, err := os.ReadFile(s.infoPath(id))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read upload info: %w", err)
+	}
+
+	var upload Upload
+	if err := json.Unmarshal(raw, &upload); err != nil {
+		return nil, fmt.Errorf("failed to parse upload info: %w", err)
+	}
+
+	// The data file is the source of truth for the offset, so bytes written
+	// before a crash are never acknowledged twice
+	if upload.ImageID == "" {
+		stat, err := os.Stat(s.dataPath(id))
+		if err != nil {
+			return nil, fmt.Errorf("failed to stat upload file: %w", err)
+		}
+		upload.Offset = stat.Size()
+	}
+
+	return &upload, nil
+}
+
+// Lock claims exclusive access to an upload for the duration of a request
+func (s *Store) Lock(id string) (func(), error) {
+	s.mu.Lock()
+	lock, ok := s.locks[id]
+	if !ok {
+		lock = &uploadLock{}
+		s.locks[id] = lock
+	}
+	lock.refs++
+	s.mu.Unlock()
+
+	if !lock.mu.TryLock() {
+		s.release(id, lock)
+		return nil, ErrLocked
+	}
+
+	return func() {
+		lock.mu.Unlock()
+		s.release(id, lock)
+	}, nil
+}
+
+func (s *Store) release(id string, lock *uploadLock) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	lock.refs--
+	if lock.refs == 0 {
+		delete(s.locks, id)
+	}
+}
+
+// WriteChunk appends the chunk at the given offset. The caller must hold the
+// upload lock.
+func (s *Store) WriteChunk(id string, offset int64, r io.Reader) (*Upload, error) {
+	upload, err := s.Get(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if offset != upload.Offset {
+		return upload, ErrOffsetMismatch
+	}
+
+	file, err := os.OpenFile(s.dataPath(id), os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open upload file: %w", err)
+	}
+	defer file.Close()
+
+	remaining := upload.Length - upload.Offset
+	written, err := io.Copy(file, io.LimitReader(r, remaining+1))
+	if written > remaining {
+		// Drop the overflow so the upload stays consistent
+		if truncErr := file.Truncate(upload.Length); truncErr != nil {
+			return nil, fmt.Errorf("failed to truncate upload file: %w", truncErr)
+		}
+		upload.Offset = upload.Length
+		return upload, ErrTooLarge
+	}
+
+	// Keep whatever was received even if the client went away mid-chunk
+	upload.Offset += written
+	if err != nil {
+		return upload, fmt.Errorf("failed to write chunk: %w", err)
+	}
+
+	return upload, nil
+}
+
+// ReadAll returns the assembled content of a complete upload
+func (s *Store) ReadAll(id string) ([]byte, error) {
+	if !validID(id) {
+		return nil, ErrNotFound
+	}
+	return os.ReadFile(s.dataPath(id))
+}
+
+// Finish records the image created from the upload and frees its data
+func (s *Store) Finish(upload *Upload, imageID string) error {
+	upload.ImageID = imageID
+	if err := s.writeInfo(upload); err != nil {
+		return err
+	}
+
+	if err := os.Remove(s.dataPath(upload.ID)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		logrus.Warnf("Failed to remove data of upload %s: %v", upload.ID, err)
+	}
+	return nil
+}
+
+func (s *Store) Delete(id string) error {
+	if !validID(id) {
+		return ErrNotFound
+	}
+
+	for _, path := range []string{s.dataPath(id), s.infoPath(id)} {
+		if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("failed to delete upload: %w", err)
+		}
+	}
+	return nil
+}
+
+func (s *Store) writeInfo(upload *Upload) error {
+	raw, err := json.Marshal(upload)
+	if err != nil {
+		return fmt.Errorf("failed to marshal upload info: %w", err)
+	}
+
+	tmp := s.infoPath(upload.ID) + ".tmp"
+	if err := os.WriteFile(tmp, raw, 0o644); err != nil {
+		return fmt.Errorf("failed to write upload info: %w", err)
+	}
+	if err := os.Rename(tmp, s.infoPath(upload.ID)); err != nil {
+		return fmt.Errorf("failed to write upload info: %w", err)
+	}
+	return nil
+}
+
+// cleanupLoop removes expired uploads
+func (s *Store) cleanupLoop() {
+	ticker := time.NewTicker(time.Hour)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		s.cleanup()
+	}
+}
+
+func (s *Store) cleanup() {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		logrus.Warnf("Failed to list uploads: %v", err)
+		return
+	}
+
+	now := time.Now()
+	for _, entry := range entries {
+		id, ok := strings.CutSuffix(entry.Name(), ".info")
+		if !ok {
+			continue
+		}
+
+		upload, err := s.Get(id)
+		if err != nil || now.Before(upload.ExpiresAt) {
+			continue
+		}
+
+		if err := s.Delete(id); err != nil {
+			logrus.Warnf("Failed to delete expired upload %s: %v", id, err)
+			continue
+		}
+		logrus.Debugf("Deleted expired upload %s", id)
+	}
+}
+
+func (s *Store) dataPath(id string) string {
+	return filepath.Join(s.dir, id+".bin")
+}
+
+func (s *Store) infoPath(id string) string {
+	return filepath.Join(s.dir, id+".info")
+}
+
+// validID guards the filesystem against crafted upload IDs
+func validID(id string) bool {
+	_, err := uuid.Parse(id)
+	return err == nil
+}
@@ -0,0 +1,201 @@
+package upload
+
+import (
+	"bytes"
+	"errors"
+	"runtime"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func newTestStore(t *testing.T) *Store {
+	t.Helper()
+
+	s, err := NewStore(t.TempDir(), time.Hour)
+	if err != nil {
+		t.Fatalf("NewStore: %v", err)
+	}
+	return s
+}
+
+func TestWriteChunks(t *testing.T) {
+	s := newTestStore(t)
+
+	up, err := s.Create(10, map[string]string{"filename": "a.png"}, "tenant-1", "user-1")
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	if up, err = s.WriteChunk(up.ID, 0, strings.NewReader("hello")); err != nil || up.Offset != 5 {
+		t.Fatalf("WriteChunk = %+v, %v", up, err)
+	}
+
+	// A stale offset is refused and the current one reported
+	up, err = s.WriteChunk(up.ID, 0, strings.NewReader("hello"))
+	if !errors.Is(err, ErrOffsetMismatch) || up.Offset != 5 {
+		t.Fatalf("WriteChunk at stale offset = %+v, %v", up, err)
+	}
+
+	if up, err = s.WriteChunk(up.ID, 5, strings.NewReader("world")); err != nil || !up.Complete() {
+		t.Fatalf("WriteChunk = %+v, %v", up, err)
+	}
+
+	content, err := s.ReadAll(up.ID)
+	if err != nil || string(content) != "helloworld" {
+		t.Fatalf("ReadAll = %q, %v", content, err)
+	}
+
+	got, err := s.Get(up.ID)
+	if err != nil || got.Offset != 10 || got.Owner != "user-1" || got.Metadata["filename"] != "a.png" {
+		t.Fatalf("Get = %+v, %v", got, err)
+	}
+}
+
+func TestWriteChunkTooLarge(t *testing.T) {
+	s := newTestStore(t)
+
+	up, err := s.Create(4, nil, "", "")
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	up, err = s.WriteChunk(up.ID, 0, strings.NewReader("too long"))
+	if !errors.Is(err, ErrTooLarge) {
+		t.Fatalf("WriteChunk error = %v, want ErrTooLarge", err)
+	}
+
+	content, _ := s.ReadAll(up.ID)
+	if string(content) != "too " || up.Offset != 4 {
+		t.Fatalf("content = %q, offset = %d", content, up.Offset)
+	}
+}
+
+func TestFinishAndDelete(t *testing.T) {
+	s := newTestStore(t)
+
+	up, _ := s.Create(3, nil, "", "")
+	if _, err := s.WriteChunk(up.ID, 0, strings.NewReader("abc")); err != nil {
+		t.Fatalf("WriteChunk: %v", err)
+	}
+	up, _ = s.Get(up.ID)
+
+	if err := s.Finish(up, "image-1"); err != nil {
+		t.Fatalf("Finish: %v", err)
+	}
+
+	got, err := s.Get(up.ID)
+	if err != nil || got.ImageID != "image-1" || got.Offset != 3 {
+		t.Fatalf("Get after Finish = %+v, %v", got, err)
+	}
+
+	if err := s.Delete(up.ID); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if _, err := s.Get(up.ID); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Get after Delete error = %v, want ErrNotFound", err)
+	}
+}
+
+func TestGetRejectsInvalidID(t *testing.T) {
+	s := newTestStore(t)
+
+	if _, err := s.Get("../../etc/passwd"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Get error = %v, want ErrNotFound", err)
+	}
+}
+
+func TestLockIsExclusive(t *testing.T) {
+	s := newTestStore(t)
+
+	unlock, err := s.Lock("upload-1")
+	if err != nil {
+		t.Fatalf("Lock: %v", err)
+	}
+	if _, err := s.Lock("upload-1"); !errors.Is(err, ErrLocked) {
+		t.Fatalf("second Lock error = %v, want ErrLocked", err)
+	}
+
+	unlock()
+
+	unlock, err = s.Lock("upload-1")
+	if err != nil {
+		t.Fatalf("Lock after unlock: %v", err)
+	}
+	unlock()
+
+	if len(s.locks) != 0 {
+		t.Fatalf("locks = %d, want none left", len(s.locks))
+	}
+}
+
+func TestLockNeverGrantedTwice(t *testing.T) {
+	s := newTestStore(t)
+
+	var (
+		holders atomic.Int32
+		wg      sync.WaitGroup
+	)
+	for i := 0; i < 16; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 500; j++ {
+				unlock, err := s.Lock("upload-1")
+				if err != nil {
+					continue
+				}
+				if n := holders.Add(1); n != 1 {
+					t.Errorf("%d holders of the upload lock", n)
+				}
+				runtime.Gosched()
+				holders.Add(-1)
+				unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if len(s.locks) != 0 {
+		t.Fatalf("locks = %d, want none left", len(s.locks))
+	}
+}
+
+func TestConcurrentChunksKeepOffsetConsistent(t *testing.T) {
+	s := newTestStore(t)
+
+	const chunks = 50
+	up, _ := s.Create(chunks, nil, "", "")
+
+	// Every writer retries until its chunk lands at the current offset, the
+	// way a tus client resumes after a conflict
+	var wg sync.WaitGroup
+	for i := 0; i < chunks; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				unlock, err := s.Lock(up.ID)
+				if err != nil {
+					continue
+				}
+				current, err := s.Get(up.ID)
+				if err == nil {
+					_, err = s.WriteChunk(up.ID, current.Offset, bytes.NewReader([]byte{'x'}))
+				}
+				unlock()
+				if err == nil {
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	got, err := s.Get(up.ID)
+	if err != nil || got.Offset != chunks {
+		t.Fatalf("Get = %+v, %v, want offset %d", got, err, chunks)
+	}
+}
@@ -0,0 +1,364 @@
+package webhook
+
+import (
+	"ai-image-microservice/api-gateway/internal/config"
+	"ai-image-microservice/api-gateway/internal/fetch"
+	"ai-image-microservice/api-gateway/internal/models"
+	"ai-image-microservice/api-gateway/internal/store"
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	HeaderDeliveryID = "X-Webhook-ID"
+	HeaderEvent      = "X-Webhook-Event"
+	HeaderTimestamp  = "X-Webhook-Timestamp"
+	HeaderSignature  = "X-Webhook-Signature"
+)
+
+var (
+	// ErrDisabled is returned for callbacks while no signing secret is set
+	ErrDisabled = errors.New("webhooks are disabled: WEBHOOK_SECRET is not set")
+	// ErrInvalidURL is returned for callback URLs that are not absolute
+	// http or https URLs
+	ErrInvalidURL = errors.New("callback_url must be an absolute http or https URL")
+)
+
+// Dispatcher delivers signed job notifications to client callback URLs,
+// retrying failed deliveries with exponential backoff. Deliveries are
+// recorded with their payload, so the ones still pending when the gateway
+// stops are resumed on the next start. Callbacks cannot reach private or
+// loopback addresses unless allowlisted, and redirects are not followed.
+type Dispatcher struct {
+	cfg    config.WebhookConfig
+	store  store.WebhookStore
+	client *http.Client
+	guard  *fetch.Guard
+	secret []byte
+	// ctx is cancelled on shutdown to stop waiting between attempts
+	ctx       context.Context
+	cancel    context.CancelFunc
+	startOnce sync.Once
+	// mu orders wg.Add against the Wait in Shutdown
+	mu sync.Mutex
+	wg sync.WaitGroup
+}
+
+var (
+	dispatcher     *Dispatcher
+	dispatcherOnce sync.Once
+)
+
+func GetDispatcher() *Dispatcher {
+	dispatcherOnce.Do(func() {
+		if config.AppConfig.Webhook.Secret == "" {
+			logrus.Warn("WEBHOOK_SECRET is not set, submissions with a callback_url will be rejected")
+		}
+		dispatcher = NewDispatcher(config.AppConfig.Webhook, store.GetWebhookStore())
+	})
+	return dispatcher
+}
+
+func NewDispatcher(cfg config.WebhookConfig, webhookStore store.WebhookStore) *Dispatcher {
+	guard := fetch.NewGuard(cfg.Allowlist)
+	ctx, cancel := context.WithCancel(context.Background())
+
+	return &Dispatcher{
+		cfg:   cfg,
+		store: webhookStore,
+		client: &http.Client{
+			Timeout: cfg.Timeout,
+			Transport: &http.Transport{
+				// Proxies would hide the real destination from the guard
+				Proxy:                 nil,
+				DialContext:           guard.DialContext(cfg.Timeout),
+				TLSHandshakeTimeout:   cfg.Timeout,
+				ResponseHeaderTimeout: cfg.Timeout,
+				MaxIdleConns:          10,
+				IdleConnTimeout:       90 * time.Second,
+			},
+			// A redirect is reported as the delivery's response
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+		guard:  guard,
+		secret: []byte(cfg.Secret),
+		ctx:    ctx,
+		cancel: cancel,
+	}
+}
+
+// CheckCallbackURL validates a callback URL at submission time. It fails
+// with ErrDisabled when payloads cannot be signed, ErrInvalidURL for
+// malformed URLs and fetch.ErrBlockedHost for hosts resolving to private or
+// loopback addresses.
+func (d *Dispatcher) CheckCallbackURL(ctx context.Context, rawURL string) error {
+	if len(d.secret) == 0 {
+		return ErrDisabled
+	}
+
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Hostname() == "" {
+		return ErrInvalidURL
+	}
+
+	return d.guard.CheckHost(ctx, u.Hostname())
+}
+
+// Start resumes the deliveries left pending by a previous run
+func (d *Dispatcher) Start() {
+	d.startOnce.Do(func() {
+		deliveries, err := d.store.ListPending(d.ctx)
+		if err != nil {
+			logrus.Errorf("Failed to load pending webhook deliveries: %v", err)
+			return
+		}
+
+		for i := range deliveries {
+			delivery := deliveries[i]
+			if len(delivery.Payload) == 0 {
+				// Recorded before payloads were kept; it cannot be resent
+				delivery.State = models.WebhookDeliveryFailed
+				delivery.UpdatedAt = time.Now().UTC()
+				if err := d.store.Save(d.ctx, &delivery); err != nil {
+					logrus.Errorf("Failed to record webhook delivery %s: %v", delivery.ID, err)
+				}
+				continue
+			}
+			d.schedule(&delivery)
+		}
+
+		if len(deliveries) > 0 {
+			logrus.Infof("Resumed %d pending webhook deliveries", len(deliveries))
+		}
+	})
+}
+
+// Dispatch schedules the delivery of an event to the callback URL
+func (d *Dispatcher) Dispatch(callbackURL, event string, status *models.JobStatus, data interface{}) {
+	if len(d.secret) == 0 {
+		logrus.Errorf("Dropping %s webhook for %s: %v", event, status.ImageID, ErrDisabled)
+		return
+	}
+
+	now := time.Now().UTC()
+	delivery := &models.WebhookDelivery{
+		ID:        uuid.New().String(),
+		ImageID:   status.ImageID,
+		URL:       callbackURL,
+		Event:     event,
+		State:     models.WebhookDeliveryPending,
+		Attempts:  []models.WebhookAttempt{},
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+
+	body, err := json.Marshal(models.WebhookPayload{
+		DeliveryID: delivery.ID,
+		Event:      event,
+		ImageID:    status.ImageID,
+		UserID:     status.UserID,
+		Status:     status.Status,
+		Timestamp:  now,
+		Data:       data,
+	})
+	if err != nil {
+		logrus.Errorf("Failed to marshal webhook payload for %s: %v", status.ImageID, err)
+		return
+	}
+
+	delivery.Payload = body
+
+	if err := d.store.Save(context.Background(), delivery); err != nil {
+		logrus.Errorf("Failed to record webhook delivery for %s: %v", status.ImageID, err)
+	}
+
+	d.schedule(delivery)
+}
+
+func (d *Dispatcher) schedule(delivery *models.WebhookDelivery) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.ctx.Err() != nil {
+		// Shutting down; the delivery stays pending for the next start
+		return
+	}
+
+	d.wg.Add(1)
+	go func() {
+		defer d.wg.Done()
+		d.deliver(delivery)
+	}()
+}
+
+// Shutdown stops waiting between attempts and lets in-flight attempts
+// finish. Deliveries with attempts left stay pending and are resumed by the
+// next Start.
+func (d *Dispatcher) Shutdown(ctx context.Context) error {
+	d.mu.Lock()
+	d.cancel()
+	d.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		d.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (d *Dispatcher) deliver(delivery *models.WebhookDelivery) {
+	cfg := d.cfg
+	backoff := cfg.InitialBackoff
+
+	// A resumed delivery waits out the backoff of its last attempt
+	for i := 1; i < len(delivery.Attempts); i++ {
+		backoff = min(backoff*2, cfg.MaxBackoff)
+	}
+	if len(delivery.Attempts) > 0 && !d.wait(backoff) {
+		return
+	}
+
+	for attempt := len(delivery.Attempts) + 1; attempt <= cfg.MaxAttempts; attempt++ {
+		result, retryable := d.attempt(delivery, attempt)
+
+		delivery.Attempts = append(delivery.Attempts, result)
+		delivery.UpdatedAt = time.Now().UTC()
+
+		switch {
+		case result.Error == "":
+			delivery.State = models.WebhookDeliveryDelivered
+		case !retryable || attempt == cfg.MaxAttempts:
+			delivery.State = models.WebhookDeliveryFailed
+		}
+
+		if err := d.store.Save(context.Background(), delivery); err != nil {
+			logrus.Errorf("Failed to record webhook attempt for %s: %v", delivery.ImageID, err)
+		}
+
+		entry := logrus.WithFields(logrus.Fields{
+			"delivery_id": delivery.ID,
+			"image_id":    delivery.ImageID,
+			"event":       delivery.Event,
+			"attempt":     attempt,
+		})
+
+		if delivery.State == models.WebhookDeliveryDelivered {
+			entry.Info("Webhook delivered")
+			return
+		}
+		if delivery.State == models.WebhookDeliveryFailed {
+			entry.Errorf("Webhook delivery failed: %s", result.Error)
+			return
+		}
+
+		entry.Warnf("Webhook attempt failed, retrying in %s: %s", backoff, result.Error)
+
+		if !d.wait(backoff) {
+			entry.Info("Webhook retries postponed until the next start")
+			return
+		}
+
+		backoff = min(backoff*2, cfg.MaxBackoff)
+	}
+
+	// Only reached by a resumed delivery that had no attempts left
+	delivery.State = models.WebhookDeliveryFailed
+	delivery.UpdatedAt = time.Now().UTC()
+	if err := d.store.Save(context.Background(), delivery); err != nil {
+		logrus.Errorf("Failed to record webhook delivery for %s: %v", delivery.ImageID, err)
+	}
+}
+
+// wait sleeps for delay and reports false if the dispatcher shuts down first
+func (d *Dispatcher) wait(delay time.Duration) bool {
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return true
+	case <-d.ctx.Done():
+		return false
+	}
+}
+
+// attempt performs one delivery and reports whether a failure is worth retrying
+func (d *Dispatcher) attempt(delivery *models.WebhookDelivery, attempt int) (models.WebhookAttempt, bool) {
+	start := time.Now()
+	result := models.WebhookAttempt{
+		Attempt: attempt,
+		At:      start.UTC(),
+	}
+
+	// Not bound to d.ctx: an attempt in flight at shutdown is allowed to
+	// finish, within the client timeout
+	body := delivery.Payload
+	req, err := http.NewRequestWithContext(context.Background(), http.MethodPost, delivery.URL, bytes.NewReader(body))
+	if err != nil {
+		result.Error = err.Error()
+		return result, false
+	}
+
+	timestamp := strconv.FormatInt(start.Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderDeliveryID, delivery.ID)
+	req.Header.Set(HeaderEvent, delivery.Event)
+	req.Header.Set(HeaderTimestamp, timestamp)
+	req.Header.Set(HeaderSignature, "sha256="+Sign(d.secret, timestamp, body))
+
+	resp, err := d.client.Do(req)
+	result.DurationMs = time.Since(start).Milliseconds()
+	if err != nil {
+		result.Error = err.Error()
+		return result, !errors.Is(err, fetch.ErrBlockedHost)
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+
+	result.StatusCode = resp.StatusCode
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return result, true
+	}
+
+	result.Error = fmt.Sprintf("unexpected status code %d", resp.StatusCode)
+
+	// Other client errors will not resolve themselves by retrying
+	retryable := resp.StatusCode >= 500 ||
+		resp.StatusCode == http.StatusRequestTimeout ||
+		resp.StatusCode == http.StatusTooManyRequests
+	return result, retryable
+}
+
+// Sign computes the hex HMAC-SHA256 of "<timestamp>.<body>", which receivers
+// recompute to verify the X-Webhook-Signature header
+func Sign(secret []byte, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
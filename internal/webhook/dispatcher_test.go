@@ -0,0 +1,311 @@
+package webhook
+
+import (
+	"ai-image-microservice/api-gateway/internal/config"
+	"ai-image-microservice/api-gateway/internal/fetch"
+	"ai-image-microservice/api-gateway/internal/models"
+	"ai-image-microservice/api-gateway/internal/store"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+const testSecret = "test-secret"
+
+func testConfig(allowlist ...string) config.WebhookConfig {
+	return config.WebhookConfig{
+		Secret:         testSecret,
+		MaxAttempts:    3,
+		InitialBackoff: 10 * time.Millisecond,
+		MaxBackoff:     50 * time.Millisecond,
+		Timeout:        2 * time.Second,
+		Allowlist:      allowlist,
+	}
+}
+
+// waitForState polls the store until the image's only delivery reaches state
+func waitForState(t *testing.T, s store.WebhookStore, imageID string, state models.WebhookDeliveryState) models.WebhookDelivery {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		deliveries, err := s.ListByImage(context.Background(), imageID)
+		if err != nil {
+			t.Fatalf("ListByImage: %v", err)
+		}
+		if len(deliveries) == 1 && deliveries[0].State == state {
+			return deliveries[0]
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	t.Fatalf("delivery for %s did not reach state %s", imageID, state)
+	return models.WebhookDelivery{}
+}
+
+func TestCheckCallbackURL(t *testing.T) {
+	tests := []struct {
+		name      string
+		secret    string
+		allowlist []string
+		url       string
+		want      error
+	}{
+		{name: "public address", secret: testSecret, url: "https://93.184.216.34/hook"},
+		{name: "no secret", url: "https://93.184.216.34/hook", want: ErrDisabled},
+		{name: "relative URL", secret: testSecret, url: "/hook", want: ErrInvalidURL},
+		{name: "unsupported scheme", secret: testSecret, url: "ftp://93.184.216.34/hook", want: ErrInvalidURL},
+		{name: "loopback", secret: testSecret, url: "http://127.0.0.1:8080/hook", want: fetch.ErrBlockedHost},
+		{name: "private", secret: testSecret, url: "http://10.1.2.3/hook", want: fetch.ErrBlockedHost},
+		{name: "metadata endpoint", secret: testSecret, url: "http://169.254.169.254/latest", want: fetch.ErrBlockedHost},
+		{name: "localhost name", secret: testSecret, url: "http://localhost/hook", want: fetch.ErrBlockedHost},
+		{name: "allowlisted CIDR", secret: testSecret, allowlist: []string{"10.0.0.0/8"}, url: "http://10.1.2.3/hook"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := testConfig(tt.allowlist...)
+			cfg.Secret = tt.secret
+			d := NewDispatcher(cfg, store.NewMemoryWebhookStore())
+
+			err := d.CheckCallbackURL(context.Background(), tt.url)
+			if tt.want == nil && err != nil {
+				t.Fatalf("CheckCallbackURL(%q) = %v, want nil", tt.url, err)
+			}
+			if tt.want != nil && !errors.Is(err, tt.want) {
+				t.Fatalf("CheckCallbackURL(%q) = %v, want %v", tt.url, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestDispatchSignsPayload(t *testing.T) {
+	received := make(chan *http.Request, 1)
+	bodies := make(chan []byte, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		received <- r
+		bodies <- body
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	s := store.NewMemoryWebhookStore()
+	d := NewDispatcher(testConfig("127.0.0.1"), s)
+	defer d.Shutdown(context.Background())
+
+	d.Dispatch(srv.URL, "job.completed", &models.JobStatus{ImageID: "img-1", Status: models.JobStateCompleted}, nil)
+
+	req := <-received
+	body := <-bodies
+	want := "sha256=" + Sign([]byte(testSecret), req.Header.Get(HeaderTimestamp), body)
+	if got := req.Header.Get(HeaderSignature); got != want {
+		t.Fatalf("signature = %q, want %q", got, want)
+	}
+	if req.Header.Get(HeaderEvent) != "job.completed" {
+		t.Fatalf("event header = %q", req.Header.Get(HeaderEvent))
+	}
+
+	delivery := waitForState(t, s, "img-1", models.WebhookDeliveryDelivered)
+	if len(delivery.Attempts) != 1 || delivery.Attempts[0].StatusCode != http.StatusNoContent {
+		t.Fatalf("attempts = %+v", delivery.Attempts)
+	}
+}
+
+func TestDispatchWithoutSecretSendsNothing(t *testing.T) {
+	var hits atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+	}))
+	defer srv.Close()
+
+	cfg := testConfig("127.0.0.1")
+	cfg.Secret = ""
+	s := store.NewMemoryWebhookStore()
+	d := NewDispatcher(cfg, s)
+
+	d.Dispatch(srv.URL, "job.completed", &models.JobStatus{ImageID: "img-1"}, nil)
+	if err := d.Shutdown(context.Background()); err != nil {
+		t.Fatalf("Shutdown: %v", err)
+	}
+
+	if hits.Load() != 0 {
+		t.Fatalf("unsigned webhook was sent")
+	}
+	if deliveries, _ := s.ListByImage(context.Background(), "img-1"); len(deliveries) != 0 {
+		t.Fatalf("deliveries = %+v, want none", deliveries)
+	}
+}
+
+func TestDispatchRefusesLoopbackAtDialTime(t *testing.T) {
+	var hits atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+	}))
+	defer srv.Close()
+
+	s := store.NewMemoryWebhookStore()
+	d := NewDispatcher(testConfig(), s)
+	defer d.Shutdown(context.Background())
+
+	d.Dispatch(srv.URL, "job.completed", &models.JobStatus{ImageID: "img-1"}, nil)
+
+	delivery := waitForState(t, s, "img-1", models.WebhookDeliveryFailed)
+	if hits.Load() != 0 {
+		t.Fatalf("loopback callback was reached")
+	}
+	// A blocked address is not retried
+	if len(delivery.Attempts) != 1 {
+		t.Fatalf("attempts = %d, want 1", len(delivery.Attempts))
+	}
+}
+
+func TestDispatchDoesNotFollowRedirects(t *testing.T) {
+	var redirected atomic.Int32
+	mux := http.NewServeMux()
+	mux.HandleFunc("/hook", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/internal", http.StatusFound)
+	})
+	mux.HandleFunc("/internal", func(w http.ResponseWriter, r *http.Request) {
+		redirected.Add(1)
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	s := store.NewMemoryWebhookStore()
+	d := NewDispatcher(testConfig("127.0.0.1"), s)
+	defer d.Shutdown(context.Background())
+
+	d.Dispatch(srv.URL+"/hook", "job.completed", &models.JobStatus{ImageID: "img-1"}, nil)
+
+	delivery := waitForState(t, s, "img-1", models.WebhookDeliveryFailed)
+	if redirected.Load() != 0 {
+		t.Fatalf("redirect was followed")
+	}
+	if delivery.Attempts[0].StatusCode != http.StatusFound {
+		t.Fatalf("status code = %d, want %d", delivery.Attempts[0].StatusCode, http.StatusFound)
+	}
+}
+
+func TestDispatchRetriesServerErrors(t *testing.T) {
+	var hits atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if hits.Add(1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer srv.Close()
+
+	s := store.NewMemoryWebhookStore()
+	d := NewDispatcher(testConfig("127.0.0.1"), s)
+	defer d.Shutdown(context.Background())
+
+	d.Dispatch(srv.URL, "job.completed", &models.JobStatus{ImageID: "img-1"}, nil)
+
+	delivery := waitForState(t, s, "img-1", models.WebhookDeliveryDelivered)
+	if len(delivery.Attempts) != 3 {
+		t.Fatalf("attempts = %d, want 3", len(delivery.Attempts))
+	}
+}
+
+func TestStartResumesPendingDeliveries(t *testing.T) {
+	received := make(chan []byte, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		received <- body
+	}))
+	defer srv.Close()
+
+	s := store.NewMemoryWebhookStore()
+	now := time.Now().UTC()
+	payload := []byte(`{"delivery_id":"d-1"}`)
+	if err := s.Save(context.Background(), &models.WebhookDelivery{
+		ID:        "d-1",
+		ImageID:   "img-1",
+		URL:       srv.URL,
+		Event:     "job.completed",
+		State:     models.WebhookDeliveryPending,
+		Attempts:  []models.WebhookAttempt{{Attempt: 1, At: now, Error: "connection refused"}},
+		Payload:   payload,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+
+	d := NewDispatcher(testConfig("127.0.0.1"), s)
+	defer d.Shutdown(context.Background())
+	d.Start()
+
+	if body := <-received; string(body) != string(payload) {
+		t.Fatalf("body = %s, want %s", body, payload)
+	}
+
+	delivery := waitForState(t, s, "img-1", models.WebhookDeliveryDelivered)
+	if len(delivery.Attempts) != 2 || delivery.Attempts[1].Attempt != 2 {
+		t.Fatalf("attempts = %+v", delivery.Attempts)
+	}
+}
+
+func TestShutdownLetsInFlightAttemptsFinish(t *testing.T) {
+	started := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		time.Sleep(200 * time.Millisecond)
+	}))
+	defer srv.Close()
+
+	s := store.NewMemoryWebhookStore()
+	d := NewDispatcher(testConfig("127.0.0.1"), s)
+
+	d.Dispatch(srv.URL, "job.completed", &models.JobStatus{ImageID: "img-1"}, nil)
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := d.Shutdown(ctx); err != nil {
+		t.Fatalf("Shutdown: %v", err)
+	}
+
+	waitForState(t, s, "img-1", models.WebhookDeliveryDelivered)
+}
+
+func TestShutdownKeepsRetriesPending(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	cfg := testConfig("127.0.0.1")
+	cfg.InitialBackoff = time.Hour
+	s := store.NewMemoryWebhookStore()
+	d := NewDispatcher(cfg, s)
+
+	d.Dispatch(srv.URL, "job.completed", &models.JobStatus{ImageID: "img-1"}, nil)
+
+	// Wait for the first attempt to be recorded, then stop during the backoff
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		deliveries, _ := s.ListByImage(context.Background(), "img-1")
+		if len(deliveries) == 1 && len(deliveries[0].Attempts) == 1 {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if err := d.Shutdown(context.Background()); err != nil {
+		t.Fatalf("Shutdown: %v", err)
+	}
+
+	pending, err := s.ListPending(context.Background())
+	if err != nil {
+		t.Fatalf("ListPending: %v", err)
+	}
+	if len(pending) != 1 || len(pending[0].Attempts) != 1 || len(pending[0].Payload) == 0 {
+		t.Fatalf("pending = %+v, want one delivery with its payload", pending)
+	}
+}
@@ -1 +1,31 @@
 package logger
+
+import (
+	"context"
+
+	"github.com/sirupsen/logrus"
+)
+
+// FieldRequestID is the log field holding the request ID
+const FieldRequestID = "request_id"
+
+type requestIDKey struct{}
+
+// WithRequestID returns a copy of ctx carrying the request ID
+func WithRequestID(ctx context.Context, requestID string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, requestID)
+}
+
+// RequestIDFromContext returns the request ID carried by ctx, if any
+func RequestIDFromContext(ctx context.Context) string {
+	requestID, _ := ctx.Value(requestIDKey{}).(string)
+	return requestID
+}
+
+// FromContext returns a log entry tagged with the request ID of ctx
+func FromContext(ctx context.Context) *logrus.Entry {
+	if requestID := RequestIDFromContext(ctx); requestID != "" {
+		return logrus.WithField(FieldRequestID, requestID)
+	}
+	return logrus.NewEntry(logrus.StandardLogger())
+}
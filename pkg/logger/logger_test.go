@@ -0,0 +1,24 @@
+package logger
+
+import (
+	"context"
+	"testing"
+)
+
+func TestRequestIDContext(t *testing.T) {
+	ctx := context.Background()
+	if id := RequestIDFromContext(ctx); id != "" {
+		t.Fatalf("RequestIDFromContext = %q, want empty", id)
+	}
+	if _, ok := FromContext(ctx).Data[FieldRequestID]; ok {
+		t.Fatal("log entry has a request ID without one in the context")
+	}
+
+	ctx = WithRequestID(ctx, "req-1")
+	if id := RequestIDFromContext(ctx); id != "req-1" {
+		t.Fatalf("RequestIDFromContext = %q, want req-1", id)
+	}
+	if got := FromContext(ctx).Data[FieldRequestID]; got != "req-1" {
+		t.Fatalf("log entry request ID = %v, want req-1", got)
+	}
+}